@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gavinyap/goreflector/proxy"
+)
+
+// fileConfig is the JSON configuration file loaded with -config. It holds
+// settings that do not fit comfortably on the command line, and is read
+// again on SIGHUP.
+type fileConfig struct {
+	Backends []fileBackend   `json:"backends"`
+	Routes   []fileRoute     `json:"routes"`
+	Paths    []filePathRoute `json:"paths"`
+	// Headers are set on every forwarded request, like -H, and win over
+	// -H for the same name
+	Headers map[string]string `json:"headers"`
+	// Timeout replaces -t when set
+	Timeout string `json:"timeout"`
+}
+
+// fileBackend is a load-balanced backend, like -backend, that can also
+// carry headers of its own.
+type fileBackend struct {
+	URL     string            `json:"url"`
+	Weight  *int              `json:"weight"`
+	Headers map[string]string `json:"headers"`
+}
+
+type fileRoute struct {
+	Host    string            `json:"host"`
+	Target  string            `json:"target"`
+	Headers map[string]string `json:"headers"`
+	Timeout string            `json:"timeout"`
+}
+
+type filePathRoute struct {
+	Prefix      string            `json:"prefix"`
+	Target      string            `json:"target"`
+	StripPrefix bool              `json:"strip_prefix"`
+	Headers     map[string]string `json:"headers"`
+	Timeout     string            `json:"timeout"`
+}
+
+func loadConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config fileConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// applyConfigFile loads the -config file at path into config, adding its
+// routes, backends and headers to those from the command line.
+func applyConfigFile(config *proxy.Config, path string) error {
+	file, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	routes, err := file.routes()
+	if err != nil {
+		return err
+	}
+	pathRoutes, err := file.pathRoutes()
+	if err != nil {
+		return err
+	}
+	backends, err := file.backends()
+	if err != nil {
+		return err
+	}
+	if (config.TargetURL != nil || config.SRVTarget != nil) && len(backends) > 0 {
+		return fmt.Errorf("a target URL cannot be combined with backends from the config file")
+	}
+
+	if file.Timeout != "" {
+		timeout, err := time.ParseDuration(file.Timeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("invalid timeout %q (expected a duration like 30s, 0 disables it)", file.Timeout)
+		}
+		config.Timeout = timeout
+	}
+
+	if len(file.Headers) > 0 {
+		headers := make(map[string]string, len(config.CustomHeaders)+len(file.Headers))
+		for name, value := range config.CustomHeaders {
+			headers[name] = value
+		}
+		for name, value := range file.Headers {
+			for existing := range headers {
+				if strings.EqualFold(existing, name) {
+					delete(headers, existing)
+				}
+			}
+			headers[name] = value
+		}
+		config.CustomHeaders = headers
+	}
+
+	config.Routes = routes
+	config.PathRoutes = pathRoutes
+	config.Backends = append(slices.Clone(config.Backends), backends...)
+	return nil
+}
+
+// reloadConfigFile re-reads the -config file on top of base, the
+// configuration built from the command line alone, and swaps the result
+// into p. On any error p keeps its current configuration.
+func reloadConfigFile(p *proxy.Proxy, base proxy.Config, path string) error {
+	config := base
+	if err := applyConfigFile(&config, path); err != nil {
+		return err
+	}
+	return p.Reload(config)
+}
+
+func (c *fileConfig) backends() ([]proxy.Backend, error) {
+	backends := make([]proxy.Backend, 0, len(c.Backends))
+	for i, b := range c.Backends {
+		backendURL, err := proxy.ParseBackendURL(b.URL)
+		if err != nil {
+			return nil, fmt.Errorf("backend %d: %w", i, err)
+		}
+
+		// A missing weight means 1, as with -backend; 0 drains the backend
+		weight := 1
+		if b.Weight != nil {
+			if *b.Weight < 0 {
+				return nil, fmt.Errorf("backend %d (%s): weight must not be negative", i, b.URL)
+			}
+			weight = *b.Weight
+		}
+
+		backends = append(backends, proxy.Backend{URL: backendURL, Weight: weight, Headers: b.Headers})
+	}
+	return backends, nil
+}
+
+func (c *fileConfig) routes() ([]proxy.Route, error) {
+	routes := make([]proxy.Route, 0, len(c.Routes))
+	for i, r := range c.Routes {
+		if r.Host == "" {
+			return nil, fmt.Errorf("route %d: host cannot be empty", i)
+		}
+
+		target, err := proxy.ParseBackendURL(r.Target)
+		if err != nil {
+			return nil, fmt.Errorf("route %d (%s): %w", i, r.Host, err)
+		}
+
+		timeout, err := parseRouteTimeout(r.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("route %d (%s): %w", i, r.Host, err)
+		}
+
+		routes = append(routes, proxy.Route{Host: r.Host, Target: target, Headers: r.Headers, Timeout: timeout})
+	}
+	return routes, nil
+}
+
+func (c *fileConfig) pathRoutes() ([]proxy.PathRoute, error) {
+	routes := make([]proxy.PathRoute, 0, len(c.Paths))
+	for i, r := range c.Paths {
+		if !strings.HasPrefix(r.Prefix, "/") {
+			return nil, fmt.Errorf("path route %d: prefix must start with /", i)
+		}
+
+		target, err := proxy.ParseBackendURL(r.Target)
+		if err != nil {
+			return nil, fmt.Errorf("path route %d (%s): %w", i, r.Prefix, err)
+		}
+
+		timeout, err := parseRouteTimeout(r.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("path route %d (%s): %w", i, r.Prefix, err)
+		}
+
+		routes = append(routes, proxy.PathRoute{Prefix: r.Prefix, Target: target, StripPrefix: r.StripPrefix, Headers: r.Headers, Timeout: timeout})
+	}
+	return routes, nil
+}
+
+// parseRouteTimeout parses a route's "timeout" as a Go duration such as
+// "500ms" or "2m". An empty value leaves the global -t timeout in charge.
+func parseRouteTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q (expected a positive duration like 30s)", value)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gavinyap/goreflector/proxy"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFileRoutes(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"routes": [
+			{"host": "api.example.com", "target": "http://10.0.0.1:8080"},
+			{"host": "*.example.com", "target": "https://10.0.0.2"}
+		]
+	}`)
+
+	config, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	routes, err := config.routes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %d", len(routes))
+	}
+	if routes[0].Host != "api.example.com" || routes[0].Target.String() != "http://10.0.0.1:8080" {
+		t.Errorf("unexpected first route: %+v", routes[0])
+	}
+	if routes[1].Host != "*.example.com" || routes[1].Target.String() != "https://10.0.0.2" {
+		t.Errorf("unexpected second route: %+v", routes[1])
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	if _, err := loadConfigFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing config file")
+	}
+
+	if _, err := loadConfigFile(writeConfigFile(t, `{"routes": [`)); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"missing host", `{"routes": [{"target": "http://backend"}]}`},
+		{"missing target", `{"routes": [{"host": "example.com"}]}`},
+		{"unsupported scheme", `{"routes": [{"host": "example.com", "target": "ftp://backend"}]}`},
+		{"target without host", `{"routes": [{"host": "example.com", "target": "http://"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadConfigFile(writeConfigFile(t, tt.content))
+			if err != nil {
+				t.Fatalf("unexpected load error: %v", err)
+			}
+			if _, err := config.routes(); err == nil {
+				t.Error("expected route validation error")
+			}
+		})
+	}
+}
+
+func TestLoadConfigFilePathRoutes(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"paths": [
+			{"prefix": "/api", "target": "http://10.0.0.1:8080", "strip_prefix": true},
+			{"prefix": "/static", "target": "http://10.0.0.2"}
+		]
+	}`)
+
+	config, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	routes, err := config.pathRoutes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 path routes, got %d", len(routes))
+	}
+	if routes[0].Prefix != "/api" || routes[0].Target.String() != "http://10.0.0.1:8080" || !routes[0].StripPrefix {
+		t.Errorf("unexpected first path route: %+v", routes[0])
+	}
+	if routes[1].Prefix != "/static" || routes[1].StripPrefix {
+		t.Errorf("unexpected second path route: %+v", routes[1])
+	}
+
+	for _, content := range []string{
+		`{"paths": [{"prefix": "api", "target": "http://backend"}]}`,
+		`{"paths": [{"prefix": "/api"}]}`,
+	} {
+		config, err := loadConfigFile(writeConfigFile(t, content))
+		if err != nil {
+			t.Fatalf("unexpected load error: %v", err)
+		}
+		if _, err := config.pathRoutes(); err == nil {
+			t.Errorf("expected path route validation error for %s", content)
+		}
+	}
+}
+
+func TestLoadConfigFileBackendHeaders(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"backends": [
+			{"url": "http://10.0.0.1:8080", "weight": 3, "headers": {"X-Token": "one"}},
+			{"url": "http://10.0.0.2:8080", "weight": 0},
+			{"url": "http://10.0.0.3:8080"}
+		],
+		"routes": [{"host": "api.example.com", "target": "http://10.0.0.4", "headers": {"X-Token": "route"}}],
+		"paths": [{"prefix": "/api", "target": "http://10.0.0.5", "headers": {"X-Token": "path"}}]
+	}`)
+
+	config, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	backends, err := config.backends()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(backends) != 3 {
+		t.Fatalf("expected 3 backends, got %d", len(backends))
+	}
+	if backends[0].URL.String() != "http://10.0.0.1:8080" || backends[0].Weight != 3 || backends[0].Headers["X-Token"] != "one" {
+		t.Errorf("unexpected first backend: %+v", backends[0])
+	}
+	if backends[1].Weight != 0 || backends[1].Headers != nil {
+		t.Errorf("expected a drained backend without headers, got %+v", backends[1])
+	}
+	if backends[2].Weight != 1 {
+		t.Errorf("expected default weight 1, got %d", backends[2].Weight)
+	}
+
+	routes, _ := config.routes()
+	if len(routes) != 1 || routes[0].Headers["X-Token"] != "route" {
+		t.Errorf("expected route headers, got %+v", routes)
+	}
+	pathRoutes, _ := config.pathRoutes()
+	if len(pathRoutes) != 1 || pathRoutes[0].Headers["X-Token"] != "path" {
+		t.Errorf("expected path route headers, got %+v", pathRoutes)
+	}
+
+	for _, content := range []string{
+		`{"backends": [{"weight": 1}]}`,
+		`{"backends": [{"url": "ftp://backend"}]}`,
+		`{"backends": [{"url": "http://backend", "weight": -1}]}`,
+	} {
+		config, err := loadConfigFile(writeConfigFile(t, content))
+		if err != nil {
+			t.Fatalf("unexpected load error: %v", err)
+		}
+		if _, err := config.backends(); err == nil {
+			t.Errorf("expected backend validation error for %s", content)
+		}
+	}
+}
+
+func TestLoadConfigFileRouteTimeouts(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"routes": [{"host": "reports.example.com", "target": "http://10.0.0.1", "timeout": "2m"}],
+		"paths": [
+			{"prefix": "/export", "target": "http://10.0.0.2", "timeout": "90s"},
+			{"prefix": "/api", "target": "http://10.0.0.3"}
+		]
+	}`)
+
+	config, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	routes, err := config.routes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if routes[0].Timeout != 2*time.Minute {
+		t.Errorf("expected route timeout 2m, got %v", routes[0].Timeout)
+	}
+	pathRoutes, err := config.pathRoutes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pathRoutes[0].Timeout != 90*time.Second || pathRoutes[1].Timeout != 0 {
+		t.Errorf("unexpected path route timeouts: %v, %v", pathRoutes[0].Timeout, pathRoutes[1].Timeout)
+	}
+
+	for _, content := range []string{
+		`{"paths": [{"prefix": "/api", "target": "http://backend", "timeout": "soon"}]}`,
+		`{"paths": [{"prefix": "/api", "target": "http://backend", "timeout": "-5s"}]}`,
+		`{"paths": [{"prefix": "/api", "target": "http://backend", "timeout": "0s"}]}`,
+	} {
+		config, err := loadConfigFile(writeConfigFile(t, content))
+		if err != nil {
+			t.Fatalf("unexpected load error: %v", err)
+		}
+		if _, err := config.pathRoutes(); err == nil {
+			t.Errorf("expected path route timeout error for %s", content)
+		}
+	}
+	config, _ = loadConfigFile(writeConfigFile(t, `{"routes": [{"host": "a.example.com", "target": "http://backend", "timeout": "1x"}]}`))
+	if _, err := config.routes(); err == nil {
+		t.Error("expected route timeout error for an invalid duration")
+	}
+}
+
+func TestReloadConfigFileHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("X-Env")+" "+r.Header.Get("X-Team"))
+	}))
+	defer backend.Close()
+
+	path := writeConfigFile(t, `{"headers": {"X-Env": "staging"}, "paths": [{"prefix": "/", "target": "`+backend.URL+`"}]}`)
+	base := proxy.Config{
+		ListenAddr:    ":8080",
+		Timeout:       30 * time.Second,
+		CustomHeaders: map[string]string{"X-Team": "core", "x-env": "from-flag"},
+		Logger:        log.New(io.Discard, "", 0),
+	}
+	config := base
+	if err := applyConfigFile(&config, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p, err := proxy.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+		return w.Body.String()
+	}
+	if got := get(); got != "staging core" {
+		t.Fatalf("expected file header over -H, got %q", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"headers": {"X-Env": "production"}, "timeout": "5s", "paths": [{"prefix": "/", "target": "`+backend.URL+`"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfigFile(p, base, path); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if got := get(); got != "production core" {
+		t.Errorf("expected reloaded header, got %q", got)
+	}
+
+	for _, content := range []string{
+		`{"headers": {"X-Env": "broken"`,
+		`{"headers": {"X-Env": "broken"}, "timeout": "soon", "paths": [{"prefix": "/", "target": "` + backend.URL + `"}]}`,
+		`{"headers": {"X-Env": "broken"}, "paths": [{"prefix": "api", "target": "` + backend.URL + `"}]}`,
+		`{"headers": {"X-Env": "broken"}}`,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := reloadConfigFile(p, base, path); err == nil {
+			t.Errorf("expected reload error for %s", content)
+		}
+	}
+	if got := get(); got != "production core" {
+		t.Errorf("expected rejected reloads to keep the previous config, got %q", got)
+	}
+}
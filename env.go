@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix is prepended to the upper-cased flag name (dashes become
+// underscores) to form the environment variable for each option, e.g.
+// GOREFLECTOR_PORT or GOREFLECTOR_MAX_CONCURRENT.
+const envPrefix = "GOREFLECTOR_"
+
+// envTargetURL names the environment variable used when no target URL is
+// given on the command line.
+const envTargetURL = envPrefix + "TARGET_URL"
+
+// envAliases gives short and deprecated flags the environment name of their
+// long form so -p and -port share GOREFLECTOR_PORT. -H and -H-add have no
+// long form.
+var envAliases = map[string]string{
+	"p":             "port",
+	"t":             "timeout",
+	"v":             "verbose",
+	"H":             "headers",
+	"H-add":         "add-headers",
+	"retry-backoff": "retry-base-backoff",
+}
+
+// envFallbacks are older variable names still read when the current one is
+// unset, from before their flag was renamed.
+var envFallbacks = map[string]string{
+	envPrefix + "RETRY_BASE_BACKOFF": envPrefix + "RETRY_BACKOFF",
+}
+
+func envName(flagName string) string {
+	if alias, ok := envAliases[flagName]; ok {
+		flagName = alias
+	}
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv fills every flag not given on the command line from its
+// environment variable, so flags take precedence over the environment and
+// the environment over defaults. Repeatable flags take a comma-separated list.
+func applyEnv(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[envName(f.Name)] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envName(f.Name)
+		if err != nil || set[name] || f.Name == "version" {
+			return
+		}
+		value, ok := os.LookupEnv(name)
+		if old, found := envFallbacks[name]; !ok && found {
+			value, ok = os.LookupEnv(old)
+		}
+		if !ok {
+			return
+		}
+		// Aliases share one variable; apply it once
+		set[name] = true
+
+		values := []string{value}
+		if isRepeatable(f.Value) {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if setErr := f.Value.Set(strings.TrimSpace(v)); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", v, name, setErr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+func isRepeatable(value flag.Value) bool {
+	switch value.(type) {
+	case *headerFlags, *stringFlags:
+		return true
+	}
+	return false
+}
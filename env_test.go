@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+)
+
+func parseFlagsWithArgs(t *testing.T, args ...string) (*Options, error) {
+	t.Helper()
+
+	oldArgs := os.Args
+	t.Cleanup(func() {
+		os.Args = oldArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	})
+
+	os.Args = append([]string{"goreflector"}, args...)
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	return parseFlags()
+}
+
+func TestParseFlagsFromEnv(t *testing.T) {
+	t.Setenv("GOREFLECTOR_PORT", "9090")
+	t.Setenv("GOREFLECTOR_TARGET_URL", "https://env.example.com")
+	t.Setenv("GOREFLECTOR_TIMEOUT", "45")
+	t.Setenv("GOREFLECTOR_VERBOSE", "true")
+	t.Setenv("GOREFLECTOR_HEADERS", "X-API-Key: abc, Authorization: Bearer token")
+	t.Setenv("GOREFLECTOR_RETRY_BACKOFF", "250ms")
+
+	opts, err := parseFlagsWithArgs(t)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.Port != 9090 {
+		t.Errorf("expected port 9090, got %d", opts.Port)
+	}
+	if opts.TargetURL != "https://env.example.com" {
+		t.Errorf("expected target URL from env, got %q", opts.TargetURL)
+	}
+	if opts.Timeout != 45 {
+		t.Errorf("expected timeout 45, got %d", opts.Timeout)
+	}
+	if !opts.Verbose {
+		t.Error("expected verbose to be enabled")
+	}
+	if len(opts.Headers) != 2 || opts.Headers[0] != "X-API-Key: abc" || opts.Headers[1] != "Authorization: Bearer token" {
+		t.Errorf("expected 2 headers from env, got %q", opts.Headers)
+	}
+	if opts.RetryBackoff != 250*time.Millisecond {
+		t.Errorf("expected retry backoff 250ms, got %v", opts.RetryBackoff)
+	}
+}
+
+func TestParseFlagsFlagOverridesEnv(t *testing.T) {
+	t.Setenv("GOREFLECTOR_PORT", "9090")
+	t.Setenv("GOREFLECTOR_TARGET_URL", "https://env.example.com")
+	t.Setenv("GOREFLECTOR_HEADERS", "X-From: env")
+
+	opts, err := parseFlagsWithArgs(t, "-p", "7070", "-H", "X-From: flag", "https://flag.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.Port != 7070 {
+		t.Errorf("expected flag port 7070 to win, got %d", opts.Port)
+	}
+	if opts.TargetURL != "https://flag.example.com" {
+		t.Errorf("expected target URL argument to win, got %q", opts.TargetURL)
+	}
+	if len(opts.Headers) != 1 || opts.Headers[0] != "X-From: flag" {
+		t.Errorf("expected only the flag header, got %q", opts.Headers)
+	}
+}
+
+func TestParseFlagsRetryBackoffAlias(t *testing.T) {
+	t.Setenv("GOREFLECTOR_RETRY_BASE_BACKOFF", "250ms")
+
+	opts, err := parseFlagsWithArgs(t, "https://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.RetryBackoff != 250*time.Millisecond {
+		t.Errorf("expected retry backoff 250ms from env, got %v", opts.RetryBackoff)
+	}
+
+	// The deprecated flag still beats the environment
+	opts, err = parseFlagsWithArgs(t, "-retry-backoff", "1s", "https://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.RetryBackoff != time.Second {
+		t.Errorf("expected -retry-backoff 1s to win over the environment, got %v", opts.RetryBackoff)
+	}
+}
+
+func TestParseFlagsDefaultsWithoutEnv(t *testing.T) {
+	opts, err := parseFlagsWithArgs(t, "https://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.Port != 8080 || opts.Timeout != 30 || opts.Verbose {
+		t.Errorf("expected defaults, got port %d, timeout %d, verbose %v", opts.Port, opts.Timeout, opts.Verbose)
+	}
+}
+
+func TestParseFlagsInvalidEnv(t *testing.T) {
+	t.Setenv("GOREFLECTOR_PORT", "not-a-number")
+
+	if _, err := parseFlagsWithArgs(t, "https://example.com"); err == nil {
+		t.Error("expected error for invalid GOREFLECTOR_PORT")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"p":                       "GOREFLECTOR_PORT",
+		"port":                    "GOREFLECTOR_PORT",
+		"H":                       "GOREFLECTOR_HEADERS",
+		"H-add":                   "GOREFLECTOR_ADD_HEADERS",
+		"retry-backoff":           "GOREFLECTOR_RETRY_BASE_BACKOFF",
+		"max-idle-conns-per-host": "GOREFLECTOR_MAX_IDLE_CONNS_PER_HOST",
+	}
+
+	for flagName, expected := range tests {
+		if got := envName(flagName); got != expected {
+			t.Errorf("envName(%q) = %q, expected %q", flagName, got, expected)
+		}
+	}
+}
@@ -1,25 +1,143 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
-)
 
-const version = "1.0.0"
+	"github.com/gavinyap/goreflector/proxy"
+)
 
 type Options struct {
-	Port        int
-	TargetURL   string
-	Timeout     int
-	Verbose     bool
-	ShowVersion bool
-	Headers     []string
+	Port                  int
+	Listen                []string
+	TargetURL             string
+	Timeout               int
+	Verbose               bool
+	Debug                 bool
+	DebugShowSecrets      bool
+	ShowVersion           bool
+	Headers               []string
+	AddHeaders            []string
+	ResponseHeaders       []string
+	RewriteRedirects      bool
+	Compress              bool
+	RateLimit             float64
+	RateBurst             int
+	HealthInterval        time.Duration
+	HealthPath            string
+	BasicAuth             []string
+	ForwardAuth           bool
+	AllowCIDRs            []string
+	DenyCIDRs             []string
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ExpectContinueTimeout time.Duration
+	ResponseHeaderTimeout time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	IdleConnTimeout       time.Duration
+	DNSCacheTTL           time.Duration
+	MaxConnsPerHost       int
+	InsecureSkipVerify    bool
+	ClientCertFile        string
+	ClientKeyFile         string
+	CACertFile            string
+	TLSMinVersion         string
+	TLSCipherSuites       []string
+	TLSCurves             []string
+	HTTP2                 bool
+	H2C                   bool
+	SelfHealthPath        string
+	ConfigFile            string
+	MaxResponseSize       int64
+	MaxHeaderBytes        int
+	BufferResponse        bool
+	TimingHeader          bool
+	PreserveHeaderCase    bool
+	NoForwardedHeaders    bool
+	NoXFF                 bool
+	NoXFH                 bool
+	NoXFP                 bool
+	ForwardedRFC7239      bool
+	CheckBackend          bool
+	CheckBackendFatal     bool
+	RequestIDHeader       string
+	Backends              []string
+	LoadBalancing         string
+	HashKey               string
+	SRVRefresh            time.Duration
+	BreakerThreshold      int
+	BreakerCooldown       time.Duration
+	BreakerCount5xx       bool
+	AccessLogPath         string
+	ProxyProtocol         bool
+	TrustedProxies        []string
+	XFFTrustedHops        int
+	OTelEndpoint          string
+	StripHeaders          []string
+	StripResponseHeaders  []string
+	PreserveHost          bool
+	PreserveRawPath       bool
+	CacheSize             int64
+	CacheTTL              time.Duration
+	ForwardProxy          bool
+	Reflect               bool
+	ErrorPages            []string
+	ErrorPageType         string
+	MaintenanceFile       string
+	MaxConcurrent         int
+	MaxConnsPerIP         int
+	ConcurrencyPolicy     string
+	QueueTimeout          time.Duration
+	TLSCertFile           string
+	TLSKeyFile            string
+	AutocertDomains       []string
+	AutocertCacheDir      string
+	HTTP3                 bool
+	Rewrites              []string
+	BodyReplace           []string
+	BodyReplaceRegex      []string
+	BodyReplaceTypes      []string
+	MirrorURL             string
+	MirrorPercent         float64
+	Retries               int
+	RetryBackoff          time.Duration
+	RetryMaxBackoff       time.Duration
+	RetryJitter           float64
+	MaxRetryAfter         time.Duration
+	ForwardTrailers       bool
+	AdminPort             int
+	AdminHost             string
+	Pprof                 bool
+	UpstreamProxy         string
+	SlowThreshold         time.Duration
+	SetQuery              []string
+	FallbackURL           string
+	FallbackStatuses      []string
+	RemapStatus           []string
+	AllowStatus           []string
+	AllowMethods          []string
+	AllowContentTypes     []string
+	StrictContentType     bool
+	RequireJSONFields     []string
+	InjectLatency         time.Duration
+	InjectLatencyJitter   time.Duration
+	InjectErrorRate       float64
+	InjectErrorStatus     int
+	InjectSeed            uint64
+	RemoveQuery           []string
 }
 
 // headerFlags implements flag.Value to support multiple -H flags
@@ -34,74 +152,563 @@ func (h *headerFlags) Set(value string) error {
 	return nil
 }
 
+// stringFlags implements flag.Value for repeatable string flags
+type stringFlags []string
+
+func (s *stringFlags) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *stringFlags) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 func parseFlags() (*Options, error) {
 	opts := &Options{}
 	var headers headerFlags
+	var addHeaders headerFlags
+	var responseHeaders headerFlags
+	var basicAuth stringFlags
+	var allowCIDRs stringFlags
+	var denyCIDRs stringFlags
+	var backends stringFlags
+	var trustedProxies stringFlags
+	var stripHeaders stringFlags
+	var stripResponseHeaders stringFlags
+	var errorPages stringFlags
+	var autocertDomains stringFlags
+	var rewrites stringFlags
+	var bodyReplace stringFlags
+	var bodyReplaceRegex stringFlags
+	var bodyReplaceTypes stringFlags
+	var listen stringFlags
+	var setQuery stringFlags
+	var removeQuery stringFlags
+	var fallbackStatuses stringFlags
+	var remapStatus stringFlags
+	var allowStatus stringFlags
+	var allowMethods stringFlags
+	var allowContentTypes stringFlags
+	var requireJSONFields stringFlags
+	var tlsCipherSuites stringFlags
+	var tlsCurves stringFlags
 
 	flag.IntVar(&opts.Port, "p", 8080, "Port to listen on")
 	flag.IntVar(&opts.Port, "port", 8080, "Port to listen on")
-	flag.IntVar(&opts.Timeout, "t", 30, "Request timeout in seconds")
-	flag.IntVar(&opts.Timeout, "timeout", 30, "Request timeout in seconds")
+	flag.Var(&listen, "listen", "Address to listen on, e.g. 10.0.0.5:8080 (can be used multiple times or comma-separated; replaces -p)")
+	flag.IntVar(&opts.Timeout, "t", 30, "Request timeout in seconds (0 disables the deadline)")
+	flag.IntVar(&opts.Timeout, "timeout", 30, "Request timeout in seconds (0 disables the deadline)")
 	flag.BoolVar(&opts.Verbose, "v", false, "Verbose logging")
 	flag.BoolVar(&opts.Verbose, "verbose", false, "Verbose logging")
+	flag.BoolVar(&opts.Debug, "debug", false, "Log every header sent to and received from the backend (implies -v; credentials are redacted)")
+	flag.BoolVar(&opts.DebugShowSecrets, "debug-show-secrets", false, "With -debug, log Authorization, Cookie and other credential headers unredacted")
 	flag.BoolVar(&opts.ShowVersion, "version", false, "Show version")
 	flag.Var(&headers, "H", "Custom header (can be used multiple times, format: 'Name: Value')")
+	flag.Var(&addHeaders, "H-add", "Custom header added alongside any value the client sent, instead of replacing it like -H (can be used multiple times, format: 'Name: Value')")
+	flag.BoolVar(&opts.RewriteRedirects, "rewrite-redirects", false, "Rewrite Location headers pointing at the target host to the proxy host")
+	flag.BoolVar(&opts.Compress, "compress", false, "Gzip-compress responses for clients that accept it")
+	flag.Float64Var(&opts.RateLimit, "rate-limit", 0, "Requests per second allowed per client IP (0 disables rate limiting)")
+	flag.IntVar(&opts.RateBurst, "rate-burst", 0, "Burst size for per-client rate limiting (default: rate limit rounded up)")
+	flag.DurationVar(&opts.HealthInterval, "health-interval", 0, "Interval between backend health checks, e.g. 10s (0 disables health checking)")
+	flag.StringVar(&opts.HealthPath, "health-path", "/", "Path requested on each backend by the health checker")
+	flag.Var(&basicAuth, "basic-auth", "Require HTTP Basic Auth credentials (can be used multiple times, format: 'user:password')")
+	flag.BoolVar(&opts.ForwardAuth, "forward-auth", false, "Forward the client's Basic Auth credentials to the backend")
+	flag.Var(&allowCIDRs, "allow-cidr", "Only allow clients from this CIDR (can be used multiple times)")
+	flag.Var(&denyCIDRs, "deny-cidr", "Reject clients from this CIDR (can be used multiple times)")
+	flag.DurationVar(&opts.DialTimeout, "dial-timeout", 10*time.Second, "Timeout for establishing backend connections")
+	flag.DurationVar(&opts.KeepAlive, "keep-alive", 30*time.Second, "Keep-alive period for backend connections")
+	flag.DurationVar(&opts.TLSHandshakeTimeout, "tls-handshake-timeout", 10*time.Second, "Timeout for the backend TLS handshake")
+	flag.DurationVar(&opts.ResponseHeaderTimeout, "response-header-timeout", 0, "Maximum wait for the backend's response headers, separate from -t which also covers the body (0 means no separate limit)")
+	flag.DurationVar(&opts.ExpectContinueTimeout, "expect-continue-timeout", proxy.DefaultExpectContinueTimeout, "How long to wait for the backend's 100 Continue before sending the request body")
+	flag.IntVar(&opts.MaxIdleConns, "max-idle-conns", proxy.DefaultMaxIdleConns, "Maximum idle backend connections kept across all backends")
+	flag.IntVar(&opts.MaxIdleConnsPerHost, "max-idle-conns-per-host", proxy.DefaultMaxIdleConnsPerHost, "Maximum idle connections kept per backend host")
+	flag.DurationVar(&opts.IdleConnTimeout, "idle-conn-timeout", proxy.DefaultIdleConnTimeout, "How long an idle backend connection is kept for reuse; set it below the backend's own idle timeout")
+	flag.DurationVar(&opts.DNSCacheTTL, "dns-cache-ttl", 0, "Cache resolved backend addresses for this long and rotate new connections over them (0 disables)")
+	flag.IntVar(&opts.MaxConnsPerHost, "max-conns-per-host", 0, "Maximum connections (active and idle) per backend host; requests wait for a free one (0 means unlimited)")
+	flag.BoolVar(&opts.InsecureSkipVerify, "insecure-skip-verify", false, "Skip TLS certificate verification for the backend (development only)")
+	flag.StringVar(&opts.ClientCertFile, "client-cert", "", "Client certificate file (PEM) for mutual TLS with the backend")
+	flag.StringVar(&opts.ClientKeyFile, "client-key", "", "Client private key file (PEM) for mutual TLS with the backend")
+	flag.StringVar(&opts.CACertFile, "ca-cert", "", "CA certificate file (PEM) used to verify the backend")
+	flag.StringVar(&opts.TLSMinVersion, "tls-min-version", "1.2", "Oldest TLS version used with HTTPS backends: 1.0, 1.1, 1.2 or 1.3")
+	flag.Var(&tlsCipherSuites, "tls-cipher-suites", "TLS 1.0-1.2 cipher suites offered to HTTPS backends, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 (can be used multiple times or comma-separated; default: Go's secure set)")
+	flag.Var(&tlsCurves, "tls-curves", "Key exchanges offered to HTTPS backends in order of preference: X25519, X25519MLKEM768, P-256, P-384, P-521 (can be used multiple times or comma-separated)")
+	flag.BoolVar(&opts.HTTP2, "http2", false, "Negotiate HTTP/2 with TLS backends")
+	flag.BoolVar(&opts.H2C, "h2c", false, "Use cleartext HTTP/2 (h2c) with http:// backends")
+	flag.StringVar(&opts.SelfHealthPath, "self-health-path", "/healthz", "Path answered by the proxy itself for liveness probes (empty disables)")
+	flag.StringVar(&opts.ConfigFile, "config", "", "JSON config file with backends, host and path routes, headers and timeout (reloaded on SIGHUP)")
+	flag.Int64Var(&opts.MaxResponseSize, "max-response-size", 0, "Maximum response body bytes relayed from the backend (0 means unlimited)")
+	flag.BoolVar(&opts.PreserveHeaderCase, "preserve-header-case", false, "Send -H, -H-add and per-backend header names with the exact casing given instead of canonicalizing them (client header names are always canonicalized)")
+	flag.BoolVar(&opts.NoForwardedHeaders, "no-forwarded-headers", false, "Do not add X-Forwarded-For, X-Forwarded-Host, X-Forwarded-Proto, X-Forwarded-Port or Via to forwarded requests")
+	flag.BoolVar(&opts.NoXFF, "no-xff", false, "Do not add X-Forwarded-For to forwarded requests")
+	flag.BoolVar(&opts.NoXFH, "no-xfh", false, "Do not add X-Forwarded-Host to forwarded requests")
+	flag.BoolVar(&opts.NoXFP, "no-xfp", false, "Do not add X-Forwarded-Proto to forwarded requests")
+	flag.BoolVar(&opts.ForwardedRFC7239, "forwarded-rfc7239", false, "Also add the standard RFC 7239 Forwarded header (for, host, proto), appending to any existing value")
+	flag.IntVar(&opts.MaxHeaderBytes, "max-header-bytes", proxy.DefaultMaxHeaderBytes, "Maximum size of client request headers; larger requests get 431 (clamped to 4KB-16MB)")
+	flag.BoolVar(&opts.BufferResponse, "buffer-response", false, "Read each backend response fully before relaying it, so clients get a Content-Length instead of chunked encoding (uses more memory, adds latency)")
+	flag.BoolVar(&opts.TimingHeader, "timing-header", false, "Add an X-Proxy-Duration-Ms response header with the time the backend took, including its body (responses are buffered to measure it)")
+	flag.StringVar(&opts.RequestIDHeader, "request-id-header", proxy.DefaultRequestIDHeader, "Header used to carry the request ID")
+	flag.Var(&backends, "backend", "Load-balanced backend URL with optional weight (can be used multiple times, format: 'URL' or 'URL=weight', weight 0 drains)")
+	flag.StringVar(&opts.LoadBalancing, "lb-algorithm", "", "How requests are spread over -backend: 'round-robin' (weighted, the default; also 'roundrobin' or 'weighted'), 'random' (weighted), 'least-conn' (fewest requests in flight; also 'leastconn'), 'p2c' (the less busy of two random picks) or 'consistent-hash' (see -hash-key)")
+	flag.StringVar(&opts.HashKey, "hash-key", "", "Send requests with the same key to the same backend via consistent hashing: 'ip', 'header:NAME' or 'cookie:NAME' (implies -lb-algorithm consistent-hash; missing keys fall back to the client IP)")
+	flag.DurationVar(&opts.SRVRefresh, "srv-refresh", proxy.DefaultSRVRefresh, "How often the SRV records of an srv:// target are looked up again")
+	flag.IntVar(&opts.BreakerThreshold, "breaker-threshold", 0, "Consecutive backend failures that open the circuit breaker (0 disables)")
+	flag.DurationVar(&opts.BreakerCooldown, "breaker-cooldown", proxy.DefaultBreakerCooldown, "Time an open circuit breaker rejects requests before a trial request")
+	flag.BoolVar(&opts.BreakerCount5xx, "breaker-count-5xx", false, "Count 5xx backend responses as circuit breaker failures")
+	flag.StringVar(&opts.AccessLogPath, "access-log", "", "Append JSON access log lines to this file (reopened on SIGHUP)")
+	flag.BoolVar(&opts.ProxyProtocol, "proxy-protocol", false, "Expect a PROXY protocol v1 header on incoming connections and use its client address")
+	flag.Var(&trustedProxies, "trusted-proxy", "Trust X-Forwarded-For and X-Real-IP from peers in this CIDR (can be used multiple times)")
+	flag.IntVar(&opts.XFFTrustedHops, "xff-trusted-hops", 0, "Number of trusted proxies in front of goreflector; the client IP is the X-Forwarded-For entry this many positions from the right (0 skips trusted entries instead)")
+	flag.StringVar(&opts.OTelEndpoint, "otel-endpoint", "", "OTLP/HTTP collector address for OpenTelemetry traces, e.g. localhost:4318 (empty disables tracing)")
+	flag.Var(&stripHeaders, "strip-header", "Remove this request header before forwarding (can be used multiple times)")
+	flag.Var(&stripResponseHeaders, "strip-response-header", "Remove this backend response header before replying, e.g. Server (can be used multiple times)")
+	flag.BoolVar(&opts.PreserveHost, "preserve-host", false, "Forward the client's original Host header instead of the target host")
+	flag.BoolVar(&opts.PreserveRawPath, "preserve-raw-path", false, "Forward the request path encoded as the client sent it, keeping e.g. %2F instead of decoding it to /")
+	flag.Int64Var(&opts.CacheSize, "cache-size", 0, "Maximum bytes of GET/HEAD responses kept in the in-memory cache (0 disables caching)")
+	flag.DurationVar(&opts.CacheTTL, "cache-ttl", proxy.DefaultCacheTTL, "How long cached responses without max-age stay fresh")
+	flag.BoolVar(&opts.ForwardProxy, "forward-proxy", false, "Act as a forward proxy: tunnel CONNECT requests and forward absolute-URI requests to their own host")
+	flag.BoolVar(&opts.Reflect, "reflect", false, "Answer every request with a JSON description of the request itself instead of forwarding it")
+	flag.IntVar(&opts.MaxConcurrent, "max-concurrent", 0, "Maximum number of requests proxied at once (0 means unlimited)")
+	flag.IntVar(&opts.MaxConnsPerIP, "max-conns-per-ip", 0, "Maximum requests a single client IP may have in flight at once; more get 429 (0 means unlimited)")
+	flag.StringVar(&opts.ConcurrencyPolicy, "concurrency-policy", proxy.ConcurrencyPolicyReject, "What to do at the concurrency limit: 'reject' with 503 or 'queue' for up to -queue-timeout")
+	flag.DurationVar(&opts.QueueTimeout, "queue-timeout", proxy.DefaultQueueTimeout, "How long a queued request waits for a free slot before getting 503")
+	flag.Var(&errorPages, "error-page", "Serve this file when the proxy itself returns the status code (can be used multiple times, format: 'CODE:PATH')")
+	flag.StringVar(&opts.ErrorPageType, "error-page-content-type", "", "Content-Type for -error-page files (default: derived from the file extension)")
+	flag.StringVar(&opts.MaintenanceFile, "maintenance-file", "", "Start in maintenance mode, answering every request with this page, 503 and Retry-After instead of forwarding (toggle with the admin API)")
+	flag.StringVar(&opts.TLSCertFile, "tls-cert", "", "Certificate file (PEM) for serving HTTPS to clients")
+	flag.StringVar(&opts.TLSKeyFile, "tls-key", "", "Private key file (PEM) for serving HTTPS to clients")
+	flag.Var(&autocertDomains, "autocert-domain", "Obtain HTTPS certificates from Let's Encrypt for this domain, serving on :443 and :80 (can be used multiple times)")
+	flag.StringVar(&opts.AutocertCacheDir, "autocert-cache", "", "Directory where automatic certificates are stored (empty keeps them in memory only)")
+	flag.BoolVar(&opts.HTTP3, "http3", false, "Also serve HTTP/3 (QUIC) on the same UDP port(s), advertised with Alt-Svc (needs -tls-cert or -autocert-domain and a build with -tags http3)")
+	flag.Var(&rewrites, "rewrite", "Rewrite the request path with a regular expression before forwarding (can be used multiple times, applied in order, format: 'pattern=replacement', $1 refers to capture groups)")
+	flag.Var(&bodyReplace, "body-replace", "Replace literal text in HTML, CSS and JavaScript response bodies (can be used multiple times, applied in order, format: 'old=new')")
+	flag.Var(&bodyReplaceRegex, "body-replace-regex", "Replace a regular expression in HTML, CSS and JavaScript response bodies, after -body-replace (can be used multiple times, applied in order, format: 'pattern=replacement', $1 refers to capture groups)")
+	flag.Var(&bodyReplaceTypes, "body-replace-type", "Media type whose response bodies -body-replace applies to, instead of text/html, text/css and application/javascript (can be used multiple times or comma-separated)")
+	flag.StringVar(&opts.MirrorURL, "mirror-url", "", "Send a copy of each request to this backend in the background and discard its response")
+	flag.Float64Var(&opts.MirrorPercent, "mirror-percent", 100, "Percentage of requests copied to -mirror-url")
+	flag.IntVar(&opts.Retries, "retries", 0, "Retry idempotent requests this many times after connection errors or 429/503 responses (0 disables)")
+	flag.DurationVar(&opts.RetryBackoff, "retry-base-backoff", proxy.DefaultRetryBackoff, "Delay before the first retry when the backend sends no Retry-After, doubling with each further retry")
+	flag.DurationVar(&opts.RetryBackoff, "retry-backoff", proxy.DefaultRetryBackoff, "Deprecated alias for -retry-base-backoff")
+	flag.DurationVar(&opts.RetryMaxBackoff, "retry-max-backoff", 0, "Longest delay between retries as the backoff doubles (0 means 2s, or -retry-base-backoff if that is longer)")
+	flag.Float64Var(&opts.RetryJitter, "retry-jitter", 1, "Fraction of each retry delay that is randomised, so clients do not retry in lockstep (0 disables, 1 is full jitter)")
+	flag.DurationVar(&opts.MaxRetryAfter, "max-retry-after", proxy.DefaultMaxRetryAfter, "Longest backend Retry-After delay honored before a retry")
+	flag.BoolVar(&opts.ForwardTrailers, "forward-trailers", false, "Forward response trailers declared by the backend (e.g. gRPC-Web status); responses with trailers are sent chunked")
+	flag.IntVar(&opts.AdminPort, "admin-port", 0, "Serve the admin API (/config, /stats, /drain, /undrain, /maintenance/on, /maintenance/off) on this separate port (0 disables)")
+	flag.StringVar(&opts.AdminHost, "admin-host", "127.0.0.1", "Interface the admin API listens on; it has no authentication, so only widen this (empty means all interfaces) on a private network")
+	flag.BoolVar(&opts.Pprof, "pprof", false, "Serve Go profiling endpoints (/debug/pprof/) on the admin port")
+	flag.StringVar(&opts.UpstreamProxy, "upstream-proxy", "", "Reach backends through this proxy: socks5://host:port or http(s)://host:port (empty dials directly)")
+	flag.DurationVar(&opts.SlowThreshold, "slow-threshold", 0, "Log a warning, even without -v, for requests taking longer than this, e.g. 2s (0 disables)")
+	flag.Var(&setQuery, "set-query", "Set a query parameter on forwarded requests, replacing any client value (can be used multiple times, format: 'key=value')")
+	flag.Var(&removeQuery, "remove-query", "Remove every value of this query parameter before forwarding (can be used multiple times)")
+	flag.StringVar(&opts.FallbackURL, "fallback-url", "", "Retry failed requests against this backend when the primary is unreachable or answers a -fallback-status")
+	flag.Var(&fallbackStatuses, "fallback-status", "Backend status that triggers -fallback-url (can be used multiple times or comma-separated, default 502,503,504)")
+	flag.Var(&remapStatus, "remap-status", "Rewrite a backend status code before relaying the response, body unchanged (can be used multiple times or comma-separated, format: 'FROM=TO', e.g. 418=200)")
+	flag.Var(&allowStatus, "allow-status", "Only relay these backend statuses, answering 502 for any other (can be used multiple times or comma-separated, e.g. 200,201,204,4xx or 200-299)")
+	flag.Var(&allowMethods, "allow-method", "Only forward requests with this HTTP method, answering others with 405 (can be used multiple times or comma-separated, default all)")
+	flag.DurationVar(&opts.InjectLatency, "inject-latency", 0, "Delay every request by this long before forwarding, for testing clients (not for production)")
+	flag.DurationVar(&opts.InjectLatencyJitter, "inject-latency-jitter", 0, "Randomize -inject-latency by up to plus or minus this much")
+	flag.Float64Var(&opts.InjectErrorRate, "inject-error-rate", 0, "Fraction of requests (0.0-1.0) answered with -inject-error-status without contacting the backend, for testing clients (not for production)")
+	flag.IntVar(&opts.InjectErrorStatus, "inject-error-status", 503, "Status code returned for injected errors")
+	flag.Uint64Var(&opts.InjectSeed, "inject-seed", 0, "Seed for injected latency jitter and errors, making them reproducible (0 picks a random seed)")
+	flag.Var(&allowContentTypes, "allow-content-type", "Only forward request bodies with this media type, answering others with 415 (can be used multiple times or comma-separated, e.g. application/json or text/*)")
+	flag.Var(&requireJSONFields, "require-json-field", "Reject JSON request bodies missing this field with 400 (dot path like user.id, can be used multiple times or comma-separated)")
+	flag.BoolVar(&opts.StrictContentType, "strict-content-type", false, "With -allow-content-type, also reject request bodies that have no Content-Type")
+	flag.BoolVar(&opts.CheckBackend, "check-backend", false, "Send one GET to each backend (at -health-path) at startup and log whether it is reachable")
+	flag.BoolVar(&opts.CheckBackendFatal, "check-backend-fatal", false, "Like -check-backend, but exit with an error if a backend is unreachable")
+	flag.Var(&responseHeaders, "response-header", "Override response header (can be used multiple times, format: 'Name: Value', empty value removes the header)")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "goreflector v%s - HTTP reverse proxy\n\n", version)
+		fmt.Fprintf(os.Stderr, "goreflector v%s - HTTP reverse proxy\n\n", proxy.Version)
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] <target-url>\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nEvery option can also be set with a %s<NAME> environment variable\n", envPrefix)
+		fmt.Fprintf(os.Stderr, "(e.g. %sPORT, %sMAX_CONCURRENT, %s); flags take precedence.\n", envPrefix, envPrefix, envTargetURL)
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  %s -p 8080 https://example.com\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -H \"Host: example.com\" https://1.2.3.4/\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -H \"Authorization: Bearer token\" -H \"X-API-Key: key123\" https://api.example.com\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -response-header \"Cache-Control: no-store\" -response-header \"Server:\" https://example.com\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -backend http://10.0.0.1:8080=3 -backend http://10.0.0.2:8080=1\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s srv://_http._tcp.api.service.consul\n", os.Args[0])
 	}
 
 	flag.Parse()
+	if err := applyEnv(flag.CommandLine); err != nil {
+		return nil, err
+	}
 
 	if opts.ShowVersion {
-		fmt.Printf("goreflector version %s\n", version)
+		fmt.Printf("goreflector version %s\n", proxy.Version)
 		os.Exit(0)
 	}
 
-	if flag.NArg() < 1 {
+	opts.TargetURL = flag.Arg(0)
+	if opts.TargetURL == "" {
+		opts.TargetURL = os.Getenv(envTargetURL)
+	}
+
+	if opts.TargetURL == "" && opts.ConfigFile == "" && len(backends) == 0 && !opts.ForwardProxy && !opts.Reflect {
 		return nil, fmt.Errorf("target URL is required")
 	}
 
-	opts.TargetURL = flag.Arg(0)
 	opts.Headers = headers
+	opts.AddHeaders = addHeaders
+	opts.ResponseHeaders = responseHeaders
+	opts.BasicAuth = basicAuth
+	opts.AllowCIDRs = allowCIDRs
+	opts.DenyCIDRs = denyCIDRs
+	opts.Backends = backends
+	opts.TrustedProxies = trustedProxies
+	opts.StripHeaders = stripHeaders
+	opts.StripResponseHeaders = stripResponseHeaders
+	opts.ErrorPages = errorPages
+	opts.AutocertDomains = autocertDomains
+	opts.Rewrites = rewrites
+	opts.BodyReplace = bodyReplace
+	opts.BodyReplaceRegex = bodyReplaceRegex
+	for _, value := range bodyReplaceTypes {
+		for _, mediaType := range strings.Split(value, ",") {
+			if mediaType = strings.TrimSpace(mediaType); mediaType != "" {
+				opts.BodyReplaceTypes = append(opts.BodyReplaceTypes, strings.ToLower(mediaType))
+			}
+		}
+	}
+	opts.SetQuery = setQuery
+	opts.RemoveQuery = removeQuery
+	opts.FallbackStatuses = fallbackStatuses
+	opts.RemapStatus = remapStatus
+	opts.AllowStatus = allowStatus
+	for _, value := range allowMethods {
+		for _, method := range strings.Split(value, ",") {
+			if method = strings.TrimSpace(method); method != "" {
+				opts.AllowMethods = append(opts.AllowMethods, strings.ToUpper(method))
+			}
+		}
+	}
+	for _, value := range allowContentTypes {
+		for _, contentType := range strings.Split(value, ",") {
+			if contentType = strings.TrimSpace(contentType); contentType != "" {
+				opts.AllowContentTypes = append(opts.AllowContentTypes, contentType)
+			}
+		}
+	}
+	for _, value := range requireJSONFields {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				opts.RequireJSONFields = append(opts.RequireJSONFields, field)
+			}
+		}
+	}
+	for _, value := range listen {
+		for _, addr := range strings.Split(value, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				opts.Listen = append(opts.Listen, addr)
+			}
+		}
+	}
+	for _, value := range tlsCipherSuites {
+		for _, suite := range strings.Split(value, ",") {
+			if suite = strings.TrimSpace(suite); suite != "" {
+				opts.TLSCipherSuites = append(opts.TLSCipherSuites, suite)
+			}
+		}
+	}
+	for _, value := range tlsCurves {
+		for _, curve := range strings.Split(value, ",") {
+			if curve = strings.TrimSpace(curve); curve != "" {
+				opts.TLSCurves = append(opts.TLSCurves, curve)
+			}
+		}
+	}
 
 	return opts, nil
 }
 
+// parseHeaders parses -H values, where a later value for a name replaces an
+// earlier one.
 func parseHeaders(headers []string) (map[string]string, error) {
 	result := make(map[string]string)
 	for _, header := range headers {
-		parts := strings.SplitN(header, ":", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid header format: %q (expected 'Name: Value')", header)
-		}
-		name := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		if name == "" {
-			return nil, fmt.Errorf("invalid header format: %q (header name cannot be empty)", header)
+		name, value, err := parseHeader(header)
+		if err != nil {
+			return nil, err
 		}
 		result[name] = value
 	}
 	return result, nil
 }
 
+// parseAddHeaders parses -H-add values, where every value for a name is
+// kept so they can all be added alongside the client's.
+func parseAddHeaders(headers []string) (map[string][]string, error) {
+	result := make(map[string][]string)
+	for _, header := range headers {
+		name, value, err := parseHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(name, "Host") {
+			return nil, fmt.Errorf("invalid header %q: Host can only be set with -H", header)
+		}
+		result[name] = append(result[name], value)
+	}
+	return result, nil
+}
+
+func parseHeader(header string) (string, string, error) {
+	parts := strings.SplitN(header, ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid header format: %q (expected 'Name: Value')", header)
+	}
+	name := strings.TrimSpace(parts[0])
+	value := strings.TrimSpace(parts[1])
+	if name == "" {
+		return "", "", fmt.Errorf("invalid header format: %q (header name cannot be empty)", header)
+	}
+	return name, value, nil
+}
+
+func parseCredentials(credentials []string) (map[string]string, error) {
+	if len(credentials) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]string)
+	for _, credential := range credentials {
+		username, password, ok := strings.Cut(credential, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid credentials format (expected 'user:password')")
+		}
+		if username == "" {
+			return nil, fmt.Errorf("invalid credentials format (username cannot be empty)")
+		}
+		result[username] = password
+	}
+	return result, nil
+}
+
+// adminAddrClashes reports whether the admin API would share an address with
+// the proxy: the proxy port, which listens on every interface, or any -listen
+// address on the same port and an overlapping interface.
+func adminAddrClashes(opts *Options) bool {
+	if len(opts.Listen) == 0 {
+		return opts.AdminPort == opts.Port
+	}
+	for _, addr := range opts.Listen {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || port != strconv.Itoa(opts.AdminPort) {
+			continue
+		}
+		if host == opts.AdminHost || isWildcardHost(host) || isWildcardHost(opts.AdminHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWildcardHost reports whether a listen host means every interface.
+func isWildcardHost(host string) bool {
+	return host == "" || host == "0.0.0.0" || host == "::"
+}
+
 func validateOptions(opts *Options) error {
 	if opts.Port < 1 || opts.Port > 65535 {
 		return fmt.Errorf("invalid port: %d (must be between 1 and 65535)", opts.Port)
 	}
 
-	if opts.Timeout < 1 {
-		return fmt.Errorf("invalid timeout: %d (must be positive)", opts.Timeout)
+	for _, addr := range opts.Listen {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid listen address %q (expected host:port or :port)", addr)
+		}
+	}
+
+	if opts.AdminPort < 0 || opts.AdminPort > 65535 {
+		return fmt.Errorf("invalid admin port: %d (must be between 1 and 65535, or 0 to disable)", opts.AdminPort)
+	}
+
+	if opts.AdminPort != 0 && adminAddrClashes(opts) {
+		return fmt.Errorf("admin port must differ from the proxy port and -listen addresses")
+	}
+
+	if opts.Timeout < 0 {
+		return fmt.Errorf("invalid timeout: %d (must not be negative)", opts.Timeout)
+	}
+
+	if opts.RateLimit < 0 {
+		return fmt.Errorf("invalid rate limit: %v (must not be negative)", opts.RateLimit)
+	}
+
+	if opts.RateBurst < 0 {
+		return fmt.Errorf("invalid rate burst: %d (must not be negative)", opts.RateBurst)
+	}
+
+	if opts.HealthInterval < 0 {
+		return fmt.Errorf("invalid health interval: %v (must not be negative)", opts.HealthInterval)
+	}
+
+	if opts.DialTimeout < 0 {
+		return fmt.Errorf("invalid dial timeout: %v (must not be negative)", opts.DialTimeout)
+	}
+
+	if opts.KeepAlive < 0 {
+		return fmt.Errorf("invalid keep-alive: %v (must not be negative)", opts.KeepAlive)
+	}
+
+	if opts.TLSHandshakeTimeout < 0 {
+		return fmt.Errorf("invalid TLS handshake timeout: %v (must not be negative)", opts.TLSHandshakeTimeout)
+	}
+
+	if opts.ResponseHeaderTimeout < 0 {
+		return fmt.Errorf("invalid response header timeout: %v (must not be negative)", opts.ResponseHeaderTimeout)
+	}
+
+	if opts.ExpectContinueTimeout < 0 {
+		return fmt.Errorf("invalid expect-continue timeout: %v (must not be negative)", opts.ExpectContinueTimeout)
+	}
+
+	if opts.MaxIdleConns < 0 || opts.MaxIdleConnsPerHost < 0 || opts.MaxConnsPerHost < 0 {
+		return fmt.Errorf("invalid connection pool limits (must not be negative)")
+	}
+
+	if opts.IdleConnTimeout < 0 {
+		return fmt.Errorf("invalid idle connection timeout: %v (must not be negative)", opts.IdleConnTimeout)
+	}
+
+	if opts.XFFTrustedHops < 0 {
+		return fmt.Errorf("invalid X-Forwarded-For trusted hops: %d (must not be negative)", opts.XFFTrustedHops)
+	}
+
+	if opts.XFFTrustedHops > 0 && len(opts.TrustedProxies) == 0 {
+		return fmt.Errorf("-xff-trusted-hops requires -trusted-proxy")
+	}
+
+	if opts.Pprof && opts.AdminPort == 0 {
+		return fmt.Errorf("-pprof requires -admin-port")
+	}
+
+	if opts.DNSCacheTTL < 0 {
+		return fmt.Errorf("invalid DNS cache TTL: %v (must not be negative)", opts.DNSCacheTTL)
+	}
+
+	if opts.SRVRefresh < 0 {
+		return fmt.Errorf("invalid SRV refresh interval: %v (must not be negative)", opts.SRVRefresh)
+	}
+
+	if opts.MirrorURL != "" && (opts.MirrorPercent <= 0 || opts.MirrorPercent > 100) {
+		return fmt.Errorf("invalid mirror percentage: %v (must be greater than 0 and at most 100)", opts.MirrorPercent)
+	}
+
+	if opts.Retries < 0 {
+		return fmt.Errorf("invalid retries: %d (must not be negative)", opts.Retries)
+	}
+
+	if opts.RetryBackoff < 0 || opts.RetryMaxBackoff < 0 || opts.MaxRetryAfter < 0 {
+		return fmt.Errorf("invalid retry delay (must not be negative)")
+	}
+
+	if opts.RetryMaxBackoff > 0 && opts.RetryMaxBackoff < opts.RetryBackoff {
+		return fmt.Errorf("invalid -retry-max-backoff: %v (must not be below -retry-base-backoff)", opts.RetryMaxBackoff)
+	}
+
+	if opts.RetryJitter < 0 || opts.RetryJitter > 1 {
+		return fmt.Errorf("invalid retry jitter: %v (must be between 0 and 1)", opts.RetryJitter)
+	}
+
+	if opts.InjectLatency < 0 || opts.InjectLatencyJitter < 0 {
+		return fmt.Errorf("invalid injected latency (must not be negative)")
+	}
+
+	if opts.InjectErrorRate < 0 || opts.InjectErrorRate > 1 {
+		return fmt.Errorf("invalid injected error rate: %v (must be between 0 and 1)", opts.InjectErrorRate)
+	}
+
+	if opts.InjectErrorRate > 0 && (opts.InjectErrorStatus < 100 || opts.InjectErrorStatus > 599) {
+		return fmt.Errorf("invalid injected error status: %d (must be between 100 and 599)", opts.InjectErrorStatus)
+	}
+
+	if opts.SlowThreshold < 0 {
+		return fmt.Errorf("invalid slow threshold: %v (must not be negative)", opts.SlowThreshold)
+	}
+
+	if opts.BreakerThreshold < 0 {
+		return fmt.Errorf("invalid breaker threshold: %d (must not be negative)", opts.BreakerThreshold)
+	}
+
+	if opts.BreakerCooldown < 0 {
+		return fmt.Errorf("invalid breaker cooldown: %v (must not be negative)", opts.BreakerCooldown)
+	}
+
+	if opts.CacheSize < 0 {
+		return fmt.Errorf("invalid cache size: %d (must not be negative)", opts.CacheSize)
+	}
+
+	if opts.CacheTTL < 0 {
+		return fmt.Errorf("invalid cache TTL: %v (must not be negative)", opts.CacheTTL)
+	}
+
+	if opts.MaxConcurrent < 0 {
+		return fmt.Errorf("invalid max concurrent: %d (must not be negative)", opts.MaxConcurrent)
+	}
+
+	if opts.MaxConnsPerIP < 0 {
+		return fmt.Errorf("invalid max conns per IP: %d (must not be negative)", opts.MaxConnsPerIP)
+	}
+
+	if opts.ConcurrencyPolicy != "" && opts.ConcurrencyPolicy != proxy.ConcurrencyPolicyReject && opts.ConcurrencyPolicy != proxy.ConcurrencyPolicyQueue {
+		return fmt.Errorf("invalid concurrency policy: %q (must be 'queue' or 'reject')", opts.ConcurrencyPolicy)
+	}
+
+	loadBalancing := proxy.CanonicalLoadBalancing(opts.LoadBalancing)
+	switch loadBalancing {
+	case "", proxy.LoadBalancingRoundRobin, proxy.LoadBalancingRandom, proxy.LoadBalancingLeastConn, proxy.LoadBalancingP2C, proxy.LoadBalancingConsistentHash:
+	default:
+		return fmt.Errorf("invalid load balancing algorithm: %q (must be 'round-robin', 'random', 'least-conn', 'p2c' or 'consistent-hash')", opts.LoadBalancing)
+	}
+
+	if opts.HashKey != "" && loadBalancing != "" && loadBalancing != proxy.LoadBalancingConsistentHash {
+		return fmt.Errorf("-hash-key requires -lb-algorithm consistent-hash")
+	}
+
+	if opts.QueueTimeout < 0 {
+		return fmt.Errorf("invalid queue timeout: %v (must not be negative)", opts.QueueTimeout)
+	}
+
+	if opts.MaxHeaderBytes < 0 {
+		return fmt.Errorf("invalid max header bytes: %d (must not be negative)", opts.MaxHeaderBytes)
 	}
 
+	if opts.MaxResponseSize < 0 {
+		return fmt.Errorf("invalid max response size: %d (must not be negative)", opts.MaxResponseSize)
+	}
+
+	if (opts.ClientCertFile == "") != (opts.ClientKeyFile == "") {
+		return fmt.Errorf("-client-cert and -client-key must be provided together")
+	}
+
+	if (opts.TLSCertFile == "") != (opts.TLSKeyFile == "") {
+		return fmt.Errorf("-tls-cert and -tls-key must be provided together")
+	}
+
+	if len(opts.AutocertDomains) > 0 && opts.TLSCertFile != "" {
+		return fmt.Errorf("-autocert-domain cannot be combined with -tls-cert and -tls-key")
+	}
+
+	if opts.HTTP3 && opts.TLSCertFile == "" && len(opts.AutocertDomains) == 0 {
+		return fmt.Errorf("-http3 requires -tls-cert and -tls-key or -autocert-domain")
+	}
+
+	if opts.TargetURL != "" && len(opts.Backends) > 0 {
+		return fmt.Errorf("a target URL cannot be combined with -backend")
+	}
+
+	// With a config file, a backend pool or forward-proxy mode the
+	// positional target is optional, since those already say where requests
+	// go; in reflect mode they go nowhere
 	if opts.TargetURL == "" {
+		if opts.ConfigFile != "" || len(opts.Backends) > 0 || opts.ForwardProxy || opts.Reflect {
+			return nil
+		}
 		return fmt.Errorf("target URL cannot be empty")
 	}
 
@@ -127,19 +734,156 @@ func main() {
 		os.Exit(1)
 	}
 
-	targetURL, err := url.Parse(opts.TargetURL)
+	var targetURL, srvTarget *url.URL
+	if strings.HasPrefix(opts.TargetURL, "srv://") {
+		srvTarget, err = proxy.ParseSRVTarget(opts.TargetURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing target URL: %v\n", err)
+			os.Exit(1)
+		}
+	} else if opts.TargetURL != "" {
+		targetURL, err = url.Parse(opts.TargetURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing target URL: %v\n", err)
+			os.Exit(1)
+		}
+
+		if targetURL.Scheme != "http" && targetURL.Scheme != "https" {
+			fmt.Fprintf(os.Stderr, "Error: target URL must use http or https scheme\n")
+			os.Exit(1)
+		}
+	}
+
+	trustedProxies, err := proxy.ParseCIDRs(opts.TrustedProxies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing trusted proxies: %v\n", err)
+		os.Exit(1)
+	}
+
+	errorPages, err := proxy.ParseErrorPages(opts.ErrorPages, opts.ErrorPageType)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing target URL: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error loading error pages: %v\n", err)
 		os.Exit(1)
 	}
 
-	if targetURL.Scheme != "http" && targetURL.Scheme != "https" {
-		fmt.Fprintf(os.Stderr, "Error: target URL must use http or https scheme\n")
+	var maintenancePage *proxy.ErrorPage
+	if opts.MaintenanceFile != "" {
+		maintenancePage, err = proxy.LoadMaintenancePage(opts.MaintenanceFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading maintenance page: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	rewrites, err := proxy.ParseRewriteRules(opts.Rewrites)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing rewrite rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	bodyReplacements, err := proxy.ParseBodyReplacements(opts.BodyReplace, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing body replacements: %v\n", err)
+		os.Exit(1)
+	}
+	bodyRegexReplacements, err := proxy.ParseBodyReplacements(opts.BodyReplaceRegex, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing body replacements: %v\n", err)
 		os.Exit(1)
 	}
+	bodyReplacements = append(bodyReplacements, bodyRegexReplacements...)
+
+	setQuery, err := proxy.ParseQueryParams(opts.SetQuery)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing query parameters: %v\n", err)
+		os.Exit(1)
+	}
+
+	tlsMinVersion, err := proxy.ParseTLSVersion(opts.TLSMinVersion)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing TLS minimum version: %v\n", err)
+		os.Exit(1)
+	}
+
+	tlsCipherSuites, err := proxy.ParseCipherSuites(opts.TLSCipherSuites)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing TLS cipher suites: %v\n", err)
+		os.Exit(1)
+	}
+
+	tlsCurves, err := proxy.ParseTLSCurves(opts.TLSCurves)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing TLS curves: %v\n", err)
+		os.Exit(1)
+	}
+
+	var fallbackURL *url.URL
+	if opts.FallbackURL != "" {
+		fallbackURL, err = proxy.ParseBackendURL(opts.FallbackURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing fallback URL: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fallbackStatuses, err := proxy.ParseStatusCodes(opts.FallbackStatuses)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing fallback statuses: %v\n", err)
+		os.Exit(1)
+	}
+
+	statusRemaps, err := proxy.ParseStatusRemaps(opts.RemapStatus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing status remaps: %v\n", err)
+		os.Exit(1)
+	}
+
+	allowedStatuses, err := proxy.ParseStatusRanges(opts.AllowStatus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing allowed statuses: %v\n", err)
+		os.Exit(1)
+	}
+
+	var mirrorURL *url.URL
+	if opts.MirrorURL != "" {
+		mirrorURL, err = proxy.ParseBackendURL(opts.MirrorURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing mirror URL: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var upstreamProxy *url.URL
+	if opts.UpstreamProxy != "" {
+		upstreamProxy, err = proxy.ParseUpstreamProxy(opts.UpstreamProxy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing upstream proxy: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var backends []proxy.Backend
+	for _, value := range opts.Backends {
+		backend, err := proxy.ParseBackend(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing backend: %v\n", err)
+			os.Exit(1)
+		}
+		backends = append(backends, backend)
+	}
+
+	listenAddr := fmt.Sprintf(":%d", opts.Port)
+	if len(opts.Listen) > 0 {
+		listenAddr = opts.Listen[0]
+	}
+
+	var adminAddr string
+	if opts.AdminPort != 0 {
+		adminAddr = net.JoinHostPort(opts.AdminHost, strconv.Itoa(opts.AdminPort))
+	}
 
 	logger := log.New(os.Stdout, "", log.LstdFlags)
-	if !opts.Verbose {
+	if !opts.Verbose && !opts.Debug {
 		logger.SetOutput(io.Discard)
 	}
 
@@ -149,25 +893,291 @@ func main() {
 		os.Exit(1)
 	}
 
-	config := ProxyConfig{
-		ListenAddr:    fmt.Sprintf(":%d", opts.Port),
-		TargetURL:     targetURL,
-		Timeout:       time.Duration(opts.Timeout) * time.Second,
-		CustomHeaders: customHeaders,
+	addHeaders, err := parseAddHeaders(opts.AddHeaders)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing headers: %v\n", err)
+		os.Exit(1)
+	}
+
+	responseHeaders, err := parseHeaders(opts.ResponseHeaders)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing response headers: %v\n", err)
+		os.Exit(1)
+	}
+
+	basicAuth, err := parseCredentials(opts.BasicAuth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing basic auth: %v\n", err)
+		os.Exit(1)
+	}
+
+	allowCIDRs, err := proxy.ParseCIDRs(opts.AllowCIDRs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing allow list: %v\n", err)
+		os.Exit(1)
 	}
 
-	proxy, err := NewProxy(config, logger)
+	denyCIDRs, err := proxy.ParseCIDRs(opts.DenyCIDRs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing deny list: %v\n", err)
+		os.Exit(1)
+	}
+
+	config := proxy.Config{
+		ListenAddr:            listenAddr,
+		ListenAddrs:           opts.Listen,
+		TargetURL:             targetURL,
+		SRVTarget:             srvTarget,
+		SRVRefresh:            opts.SRVRefresh,
+		Timeout:               time.Duration(opts.Timeout) * time.Second,
+		CustomHeaders:         customHeaders,
+		AddHeaders:            addHeaders,
+		ResponseHeaders:       responseHeaders,
+		RewriteRedirects:      opts.RewriteRedirects,
+		Compress:              opts.Compress,
+		RateLimit:             opts.RateLimit,
+		RateBurst:             opts.RateBurst,
+		HealthInterval:        opts.HealthInterval,
+		HealthPath:            opts.HealthPath,
+		BasicAuth:             basicAuth,
+		ForwardAuth:           opts.ForwardAuth,
+		AllowCIDRs:            allowCIDRs,
+		DenyCIDRs:             denyCIDRs,
+		DialTimeout:           opts.DialTimeout,
+		KeepAlive:             opts.KeepAlive,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ExpectContinueTimeout: opts.ExpectContinueTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		DNSCacheTTL:           opts.DNSCacheTTL,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		InsecureSkipVerify:    opts.InsecureSkipVerify,
+		ClientCertFile:        opts.ClientCertFile,
+		ClientKeyFile:         opts.ClientKeyFile,
+		CACertFile:            opts.CACertFile,
+		TLSMinVersion:         tlsMinVersion,
+		TLSCipherSuites:       tlsCipherSuites,
+		TLSCurves:             tlsCurves,
+		HTTP2:                 opts.HTTP2,
+		H2C:                   opts.H2C,
+		SelfHealthPath:        opts.SelfHealthPath,
+		MaxResponseSize:       opts.MaxResponseSize,
+		MaxHeaderBytes:        opts.MaxHeaderBytes,
+		BufferResponse:        opts.BufferResponse,
+		TimingHeader:          opts.TimingHeader,
+		PreserveHeaderCase:    opts.PreserveHeaderCase,
+		NoForwardedHeaders:    opts.NoForwardedHeaders,
+		NoXForwardedFor:       opts.NoXFF,
+		NoXForwardedHost:      opts.NoXFH,
+		NoXForwardedProto:     opts.NoXFP,
+		ForwardedRFC7239:      opts.ForwardedRFC7239,
+		RequestIDHeader:       opts.RequestIDHeader,
+		Backends:              backends,
+		LoadBalancing:         opts.LoadBalancing,
+		HashKey:               opts.HashKey,
+		BreakerThreshold:      opts.BreakerThreshold,
+		BreakerCooldown:       opts.BreakerCooldown,
+		BreakerCount5xx:       opts.BreakerCount5xx,
+		AccessLogPath:         opts.AccessLogPath,
+		ProxyProtocol:         opts.ProxyProtocol,
+		TrustedProxies:        trustedProxies,
+		XFFTrustedHops:        opts.XFFTrustedHops,
+		OTelEndpoint:          opts.OTelEndpoint,
+		StripHeaders:          opts.StripHeaders,
+		StripResponseHeaders:  opts.StripResponseHeaders,
+		PreserveHost:          opts.PreserveHost,
+		PreserveRawPath:       opts.PreserveRawPath,
+		CacheSize:             opts.CacheSize,
+		CacheTTL:              opts.CacheTTL,
+		ForwardProxy:          opts.ForwardProxy,
+		Reflect:               opts.Reflect,
+		ErrorPages:            errorPages,
+		Maintenance:           maintenancePage != nil,
+		MaintenancePage:       maintenancePage,
+		StatusRemaps:          statusRemaps,
+		AllowedStatuses:       allowedStatuses,
+		MaxConcurrent:         opts.MaxConcurrent,
+		MaxConnsPerIP:         opts.MaxConnsPerIP,
+		ConcurrencyPolicy:     opts.ConcurrencyPolicy,
+		QueueTimeout:          opts.QueueTimeout,
+		TLSCertFile:           opts.TLSCertFile,
+		TLSKeyFile:            opts.TLSKeyFile,
+		AutocertDomains:       opts.AutocertDomains,
+		AutocertCacheDir:      opts.AutocertCacheDir,
+		HTTP3:                 opts.HTTP3,
+		Rewrites:              rewrites,
+		BodyReplacements:      bodyReplacements,
+		BodyReplaceTypes:      opts.BodyReplaceTypes,
+		MirrorURL:             mirrorURL,
+		MirrorPercent:         opts.MirrorPercent,
+		Retries:               opts.Retries,
+		RetryBackoff:          opts.RetryBackoff,
+		RetryMaxBackoff:       opts.RetryMaxBackoff,
+		RetryJitter:           opts.RetryJitter,
+		MaxRetryAfter:         opts.MaxRetryAfter,
+		ForwardTrailers:       opts.ForwardTrailers,
+		AdminAddr:             adminAddr,
+		UpstreamProxy:         upstreamProxy,
+		SlowThreshold:         opts.SlowThreshold,
+		SetQuery:              setQuery,
+		RemoveQuery:           opts.RemoveQuery,
+		FallbackURL:           fallbackURL,
+		FallbackStatuses:      fallbackStatuses,
+		AllowedMethods:        opts.AllowMethods,
+		AllowedContentTypes:   opts.AllowContentTypes,
+		StrictContentType:     opts.StrictContentType,
+		RequiredJSONFields:    opts.RequireJSONFields,
+		InjectLatency:         opts.InjectLatency,
+		InjectLatencyJitter:   opts.InjectLatencyJitter,
+		InjectErrorRate:       opts.InjectErrorRate,
+		InjectErrorStatus:     opts.InjectErrorStatus,
+		InjectSeed:            opts.InjectSeed,
+		Debug:                 opts.Debug,
+		DebugShowSecrets:      opts.DebugShowSecrets,
+		Logger:                logger,
+		SlowLogger:            log.New(os.Stdout, "", log.LstdFlags),
+	}
+
+	if opts.Pprof {
+		config.PprofHandler = pprofHandler()
+	}
+
+	// Kept so a SIGHUP can apply the config file afresh
+	baseConfig := config
+	if opts.ConfigFile != "" {
+		if err := applyConfigFile(&config, opts.ConfigFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	p, err := proxy.New(config)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating proxy: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Starting goreflector v%s\n", version)
-	fmt.Printf("Listening on: http://0.0.0.0:%d\n", opts.Port)
-	fmt.Printf("Proxying to:  %s\n", targetURL.String())
+	fmt.Printf("Starting goreflector v%s\n", proxy.Version)
+	scheme := "http"
+	if opts.TLSCertFile != "" {
+		scheme = "https"
+	}
+	if len(opts.AutocertDomains) > 0 {
+		fmt.Printf("Listening on: https://0.0.0.0:443 (automatic certificates for %s)\n", strings.Join(opts.AutocertDomains, ", "))
+	} else if len(opts.Listen) > 0 {
+		for _, addr := range opts.Listen {
+			fmt.Printf("Listening on: %s://%s\n", scheme, addr)
+		}
+	} else {
+		fmt.Printf("Listening on: %s://0.0.0.0:%d\n", scheme, opts.Port)
+	}
+	if opts.HTTP3 {
+		fmt.Printf("HTTP/3:       enabled on the same UDP port(s)\n")
+	}
+	if opts.Reflect {
+		fmt.Printf("Mode:         reflect (requests are echoed back as JSON)\n")
+	}
+	if opts.ForwardProxy {
+		fmt.Printf("Mode:         forward proxy (CONNECT and absolute URIs)\n")
+	}
+	if targetURL != nil {
+		fmt.Printf("Proxying to:  %s\n", proxy.StripUserinfo(targetURL))
+	}
+	if srvTarget != nil {
+		fmt.Printf("Proxying to:  %s (refreshed every %v)\n", srvTarget, opts.SRVRefresh)
+	}
+	for _, backend := range config.Backends {
+		fmt.Printf("Proxying to:  %s (weight %d)\n", proxy.StripUserinfo(backend.URL), backend.Weight)
+	}
+	if upstreamProxy != nil {
+		fmt.Printf("Via proxy:    %s\n", upstreamProxy.Redacted())
+	}
+	if adminAddr != "" {
+		shownAddr := adminAddr
+		if opts.AdminHost == "" {
+			shownAddr = "0.0.0.0" + adminAddr
+		}
+		fmt.Printf("Admin API:    http://%s (/config, /stats, /drain, /undrain, /maintenance/on, /maintenance/off)\n", shownAddr)
+		if opts.Pprof {
+			fmt.Printf("Profiling:    http://%s/debug/pprof/\n", shownAddr)
+		}
+	}
+	if maintenancePage != nil {
+		fmt.Printf("Maintenance:  on, serving %s\n", opts.MaintenanceFile)
+	}
+	if fallbackURL != nil {
+		fmt.Printf("Fallback:     %s\n", proxy.StripUserinfo(fallbackURL))
+	}
+	if mirrorURL != nil {
+		fmt.Printf("Mirroring:    %.4g%% of requests to %s\n", opts.MirrorPercent, proxy.StripUserinfo(mirrorURL))
+	}
+	for _, route := range config.Routes {
+		fmt.Printf("Routing:      %s -> %s\n", route.Host, route.Target.String())
+	}
+	for _, route := range config.PathRoutes {
+		fmt.Printf("Routing:      %s* -> %s\n", route.Prefix, route.Target.String())
+	}
+	if opts.InjectLatency > 0 || opts.InjectLatencyJitter > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: injecting %v (+/- %v) of latency into every request. This is a testing feature, do not use it in production!\n", opts.InjectLatency, opts.InjectLatencyJitter)
+	}
+	if opts.InjectErrorRate > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: failing %.4g%% of requests with %d. This is a testing feature, do not use it in production!\n", opts.InjectErrorRate*100, opts.InjectErrorStatus)
+	}
+	if opts.InsecureSkipVerify {
+		fmt.Fprintf(os.Stderr, "WARNING: TLS certificate verification is DISABLED for the backend. Do not use -insecure-skip-verify in production!\n")
+	}
+
+	if opts.CheckBackend || opts.CheckBackendFatal {
+		if err := p.CheckBackends(context.Background()); err != nil {
+			if opts.CheckBackendFatal {
+				fmt.Fprintf(os.Stderr, "Error: backend check failed: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "WARNING: backend check failed: %v\n", err)
+		} else {
+			fmt.Printf("Backend check: all backends reachable\n")
+		}
+	}
 
-	if err := proxy.Start(); err != nil {
+	handleSignals(p, logger, opts.ConfigFile, baseConfig)
+
+	if err := p.Start(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error starting proxy: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// handleSignals reopens the access log on SIGHUP, so logrotate can move the
+// file aside, and reloads the -config file if there is one. SIGINT or
+// SIGTERM close the proxy cleanly before exiting.
+func handleSignals(p *proxy.Proxy, logger *log.Logger, configFile string, base proxy.Config) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				if err := p.ReopenAccessLog(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error reopening access log: %v\n", err)
+				} else {
+					logger.Printf("Reopened access log")
+				}
+				if configFile != "" {
+					if err := reloadConfigFile(p, base, configFile); err != nil {
+						fmt.Fprintf(os.Stderr, "Error reloading config file, keeping the current configuration: %v\n", err)
+					} else {
+						logger.Printf("Reloaded config file %s", configFile)
+					}
+				}
+				continue
+			}
+
+			if err := p.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing proxy: %v\n", err)
+			}
+			os.Exit(0)
+		}
+	}()
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -8,8 +11,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/gavinyap/goreflector/proxy"
 )
 
 func TestRunFunctionEnd2End(t *testing.T) {
@@ -45,20 +53,21 @@ func TestRunFunctionEnd2End(t *testing.T) {
 		logger.SetOutput(io.Writer(io.Discard))
 	}
 
-	config := ProxyConfig{
+	config := proxy.Config{
 		ListenAddr: fmt.Sprintf(":%d", opts.Port),
 		TargetURL:  targetURL,
 		Timeout:    time.Duration(opts.Timeout) * time.Second,
+		Logger:     logger,
 	}
 
-	proxy, err := NewProxy(config, logger)
+	p, err := proxy.New(config)
 	if err != nil {
 		t.Fatalf("error creating proxy: %v", err)
 	}
 
 	server := &http.Server{
 		Addr:         config.ListenAddr,
-		Handler:      proxy,
+		Handler:      p,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -125,3 +134,38 @@ func getFreePort(t *testing.T) int {
 	addr := listener.Addr().(*net.TCPAddr)
 	return addr.Port
 }
+
+// TestCheckBackendFatalExitsNonZero runs main in a child process, since a
+// failed fatal check ends it with os.Exit.
+func TestCheckBackendFatalExitsNonZero(t *testing.T) {
+	if target := os.Getenv("CHECK_BACKEND_TARGET"); target != "" {
+		os.Args = []string{"goreflector", "-check-backend-fatal", "-t", "2", "-p", os.Getenv("CHECK_BACKEND_PORT"), target}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		main()
+		return
+	}
+
+	// Nothing listens on a port that was just released
+	backendPort := getFreePort(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestCheckBackendFatalExitsNonZero$")
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("CHECK_BACKEND_TARGET=http://127.0.0.1:%d", backendPort),
+		fmt.Sprintf("CHECK_BACKEND_PORT=%d", getFreePort(t)),
+	)
+	output, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() == 0 {
+		t.Fatalf("expected a non-zero exit, got %v\n%s", err, output)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("expected the process to exit before serving, but it was still running\n%s", output)
+	}
+	if !strings.Contains(string(output), "backend check failed") {
+		t.Errorf("expected a backend check error, got:\n%s", output)
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidateOptions(t *testing.T) {
@@ -53,14 +55,262 @@ func TestValidateOptions(t *testing.T) {
 			errorContains: "invalid timeout",
 		},
 		{
-			name: "zero timeout",
+			name: "target URL combined with backends",
 			opts: &Options{
 				Port:      8080,
 				TargetURL: "https://example.com",
-				Timeout:   0,
+				Timeout:   30,
+				Backends:  []string{"http://a:8080"},
 			},
 			expectError:   true,
-			errorContains: "invalid timeout",
+			errorContains: "cannot be combined",
+		},
+		{
+			name: "unknown load balancing algorithm",
+			opts: &Options{
+				Port:          8080,
+				Timeout:       30,
+				Backends:      []string{"http://a:8080"},
+				LoadBalancing: "fastest",
+			},
+			expectError:   true,
+			errorContains: "invalid load balancing algorithm",
+		},
+		{
+			name: "hash key with least-conn",
+			opts: &Options{
+				Port:          8080,
+				Timeout:       30,
+				Backends:      []string{"http://a:8080"},
+				LoadBalancing: "least-conn",
+				HashKey:       "ip",
+			},
+			expectError:   true,
+			errorContains: "-hash-key requires",
+		},
+		{
+			name: "retry jitter above 1",
+			opts: &Options{
+				Port:        8080,
+				TargetURL:   "https://example.com",
+				Timeout:     30,
+				RetryJitter: 1.5,
+			},
+			expectError:   true,
+			errorContains: "invalid retry jitter",
+		},
+		{
+			name: "xff trusted hops without trusted proxy",
+			opts: &Options{
+				Port:           8080,
+				TargetURL:      "https://example.com",
+				Timeout:        30,
+				XFFTrustedHops: 2,
+			},
+			expectError:   true,
+			errorContains: "-xff-trusted-hops requires",
+		},
+		{
+			name: "pprof without admin port",
+			opts: &Options{
+				Port:      8080,
+				TargetURL: "https://example.com",
+				Timeout:   30,
+				Pprof:     true,
+			},
+			expectError:   true,
+			errorContains: "-pprof requires -admin-port",
+		},
+		{
+			name: "negative SRV refresh",
+			opts: &Options{
+				Port:       8080,
+				TargetURL:  "srv://_http._tcp.api.service.consul",
+				Timeout:    30,
+				SRVRefresh: -time.Second,
+			},
+			expectError:   true,
+			errorContains: "invalid SRV refresh interval",
+		},
+		{
+			name: "negative rate limit",
+			opts: &Options{
+				Port:      8080,
+				TargetURL: "https://example.com",
+				Timeout:   30,
+				RateLimit: -1,
+			},
+			expectError:   true,
+			errorContains: "invalid rate limit",
+		},
+		{
+			name: "negative rate burst",
+			opts: &Options{
+				Port:      8080,
+				TargetURL: "https://example.com",
+				Timeout:   30,
+				RateLimit: 5,
+				RateBurst: -1,
+			},
+			expectError:   true,
+			errorContains: "invalid rate burst",
+		},
+		{
+			name: "negative dial timeout",
+			opts: &Options{
+				Port:        8080,
+				TargetURL:   "https://example.com",
+				Timeout:     30,
+				DialTimeout: -time.Second,
+			},
+			expectError:   true,
+			errorContains: "invalid dial timeout",
+		},
+		{
+			name: "negative breaker threshold",
+			opts: &Options{
+				Port:             8080,
+				TargetURL:        "https://example.com",
+				Timeout:          30,
+				BreakerThreshold: -1,
+			},
+			expectError:   true,
+			errorContains: "invalid breaker threshold",
+		},
+		{
+			name: "listen address without port",
+			opts: &Options{
+				Port:      8080,
+				Listen:    []string{"10.0.0.5"},
+				TargetURL: "https://example.com",
+				Timeout:   30,
+			},
+			expectError:   true,
+			errorContains: "invalid listen address",
+		},
+		{
+			name: "negative slow threshold",
+			opts: &Options{
+				Port:          8080,
+				TargetURL:     "https://example.com",
+				Timeout:       30,
+				SlowThreshold: -time.Second,
+			},
+			expectError:   true,
+			errorContains: "invalid slow threshold",
+		},
+		{
+			name: "client cert without key",
+			opts: &Options{
+				Port:           8080,
+				TargetURL:      "https://example.com",
+				Timeout:        30,
+				ClientCertFile: "client.crt",
+			},
+			expectError:   true,
+			errorContains: "must be provided together",
+		},
+		{
+			name: "negative max conns per host",
+			opts: &Options{
+				Port:            8080,
+				TargetURL:       "https://example.com",
+				Timeout:         30,
+				MaxConnsPerHost: -1,
+			},
+			expectError:   true,
+			errorContains: "invalid connection pool limits",
+		},
+		{
+			name: "admin port equals proxy port",
+			opts: &Options{
+				Port:      8080,
+				TargetURL: "https://example.com",
+				Timeout:   30,
+				AdminPort: 8080,
+			},
+			expectError:   true,
+			errorContains: "admin port must differ",
+		},
+		{
+			name: "admin port equals a listen address port",
+			opts: &Options{
+				Port:      8080,
+				Listen:    []string{":9000"},
+				TargetURL: "https://example.com",
+				Timeout:   30,
+				AdminPort: 9000,
+				AdminHost: "127.0.0.1",
+			},
+			expectError:   true,
+			errorContains: "admin port must differ",
+		},
+		{
+			name: "admin port on the same listen interface",
+			opts: &Options{
+				Port:      8080,
+				Listen:    []string{"10.0.0.1:8443", "127.0.0.1:9000"},
+				TargetURL: "https://example.com",
+				Timeout:   30,
+				AdminPort: 9000,
+				AdminHost: "127.0.0.1",
+			},
+			expectError:   true,
+			errorContains: "admin port must differ",
+		},
+		{
+			name: "admin port shared with another listen interface",
+			opts: &Options{
+				Port:      8080,
+				Listen:    []string{"10.0.0.1:9000"},
+				TargetURL: "https://example.com",
+				Timeout:   30,
+				AdminPort: 9000,
+				AdminHost: "127.0.0.1",
+			},
+			expectError: false,
+		},
+		{
+			name: "reflect mode without target",
+			opts: &Options{
+				Port:    8080,
+				Timeout: 30,
+				Reflect: true,
+			},
+			expectError: false,
+		},
+		{
+			name: "tls key without cert",
+			opts: &Options{
+				Port:       8080,
+				TargetURL:  "https://example.com",
+				Timeout:    30,
+				TLSKeyFile: "server.key",
+			},
+			expectError:   true,
+			errorContains: "-tls-cert and -tls-key must be provided together",
+		},
+		{
+			name: "autocert with manual certificate",
+			opts: &Options{
+				Port:            8080,
+				TargetURL:       "https://example.com",
+				Timeout:         30,
+				TLSCertFile:     "server.crt",
+				TLSKeyFile:      "server.key",
+				AutocertDomains: []string{"example.com"},
+			},
+			expectError:   true,
+			errorContains: "cannot be combined with -tls-cert",
+		},
+		{
+			name: "zero timeout disables deadline",
+			opts: &Options{
+				Port:      8080,
+				TargetURL: "https://example.com",
+				Timeout:   0,
+			},
+			expectError: false,
 		},
 		{
 			name: "empty target URL",
@@ -103,6 +353,21 @@ func TestValidateOptions(t *testing.T) {
 	}
 }
 
+func TestValidateOptionsLoadBalancingNames(t *testing.T) {
+	names := []string{"round-robin", "roundrobin", "weighted", "random", "least-conn", "leastconn", "p2c", "consistent-hash"}
+	for _, name := range names {
+		opts := &Options{Port: 8080, Timeout: 30, Backends: []string{"http://a:8080"}, LoadBalancing: name}
+		if err := validateOptions(opts); err != nil {
+			t.Errorf("expected -lb-algorithm %s to be accepted, got %v", name, err)
+		}
+	}
+
+	opts := &Options{Port: 8080, Timeout: 30, Backends: []string{"http://a:8080"}, LoadBalancing: "leastconn", HashKey: "ip"}
+	if err := validateOptions(opts); err == nil || !strings.Contains(err.Error(), "-hash-key requires") {
+		t.Errorf("expected -hash-key to be refused with an alias of least-conn, got %v", err)
+	}
+}
+
 func TestParseFlagsNoArgs(t *testing.T) {
 	oldArgs := os.Args
 	defer func() {
@@ -470,3 +735,297 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestParseFlagsWithResponseHeaders(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	os.Args = []string{"goreflector", "-response-header", "Cache-Control: no-store", "-response-header", "Server:", "https://example.com"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(opts.ResponseHeaders) != 2 {
+		t.Fatalf("expected 2 response headers, got %d", len(opts.ResponseHeaders))
+	}
+
+	headers, err := parseHeaders(opts.ResponseHeaders)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if headers["Cache-Control"] != "no-store" {
+		t.Errorf("expected Cache-Control no-store, got %q", headers["Cache-Control"])
+	}
+	if value, ok := headers["Server"]; !ok || value != "" {
+		t.Errorf("expected Server with empty value, got %q (present: %v)", value, ok)
+	}
+}
+
+func TestParseCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials []string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name:        "no credentials",
+			credentials: nil,
+			expected:    nil,
+		},
+		{
+			name:        "multiple credentials",
+			credentials: []string{"alice:secret", "bob:pa:ss"},
+			expected:    map[string]string{"alice": "secret", "bob": "pa:ss"},
+		},
+		{
+			name:        "missing separator",
+			credentials: []string{"alice"},
+			expectError: true,
+		},
+		{
+			name:        "empty username",
+			credentials: []string{":secret"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseCredentials(tt.credentials)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(result) != len(tt.expected) {
+				t.Fatalf("expected %d credentials, got %d", len(tt.expected), len(result))
+			}
+			for user, pass := range tt.expected {
+				if result[user] != pass {
+					t.Errorf("expected password %q for %s, got %q", pass, user, result[user])
+				}
+			}
+		})
+	}
+}
+
+func TestParseFlagsWithConfigFileOnly(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	os.Args = []string{"goreflector", "-config", "routes.json"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.ConfigFile != "routes.json" {
+		t.Errorf("expected config file routes.json, got %s", opts.ConfigFile)
+	}
+	if err := validateOptions(opts); err != nil {
+		t.Errorf("expected target URL to be optional with a config file, got %v", err)
+	}
+}
+
+func TestParseFlagsWithBackends(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	os.Args = []string{"goreflector", "-backend", "http://a:8080=3", "-backend", "http://b:8080"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(opts.Backends) != 2 || opts.Backends[0] != "http://a:8080=3" {
+		t.Errorf("expected 2 backends, got %v", opts.Backends)
+	}
+	if err := validateOptions(opts); err != nil {
+		t.Errorf("expected target URL to be optional with backends, got %v", err)
+	}
+}
+
+func TestParseFlagsWithRewrites(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	os.Args = []string{"goreflector", "-rewrite", "^/api/(.*)$=/$1", "-rewrite", "^/old/=/new/", "https://example.com"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(opts.Rewrites) != 2 || opts.Rewrites[0] != "^/api/(.*)$=/$1" {
+		t.Errorf("expected 2 rewrite rules in order, got %v", opts.Rewrites)
+	}
+}
+
+func TestParseFlagsWithListenAddresses(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	os.Args = []string{"goreflector", "-listen", "10.0.0.5:8080", "-listen", "127.0.0.1:9000, :9001", "https://example.com"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"10.0.0.5:8080", "127.0.0.1:9000", ":9001"}
+	if strings.Join(opts.Listen, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected listen addresses %v, got %v", expected, opts.Listen)
+	}
+}
+
+func TestParseFlagsAdminAPIDefaultsToLoopback(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	os.Args = []string{"goreflector", "-admin-port", "9090", "https://example.com"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.AdminHost != "127.0.0.1" {
+		t.Errorf("expected the admin API on 127.0.0.1 by default, got %q", opts.AdminHost)
+	}
+}
+
+func TestParseFlagsWithAllowMethods(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	os.Args = []string{"goreflector", "-allow-method", "get", "-allow-method", "HEAD,options", "https://example.com"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(opts.AllowMethods, ",") != "GET,HEAD,OPTIONS" {
+		t.Errorf("expected upper-cased methods GET,HEAD,OPTIONS, got %v", opts.AllowMethods)
+	}
+}
+
+func TestParseFlagsWithAllowContentTypes(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	os.Args = []string{"goreflector", "-allow-content-type", "application/json", "-allow-content-type", "text/*, application/xml", "-strict-content-type", "https://example.com"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(opts.AllowContentTypes, ",") != "application/json,text/*,application/xml" {
+		t.Errorf("expected application/json,text/*,application/xml, got %v", opts.AllowContentTypes)
+	}
+	if !opts.StrictContentType {
+		t.Error("expected strict content type checking")
+	}
+}
+
+func TestParseAddHeaders(t *testing.T) {
+	headers, err := parseAddHeaders([]string{"X-Tags: beta", "X-Tags: canary", "X-Team:core"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(headers["X-Tags"], ",") != "beta,canary" || strings.Join(headers["X-Team"], ",") != "core" {
+		t.Errorf("expected every value to be kept, got %v", headers)
+	}
+
+	for _, header := range []string{"X-Tags", ": beta", "host: example.com"} {
+		if _, err := parseAddHeaders([]string{header}); err == nil {
+			t.Errorf("expected error for %q", header)
+		}
+	}
+}
+
+func TestParseFlagsWithNoForwardedHeaders(t *testing.T) {
+	opts, err := parseFlagsWithArgs(t, "-no-xff", "-no-xfp", "https://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.NoXFF || opts.NoXFH || !opts.NoXFP || opts.NoForwardedHeaders {
+		t.Errorf("unexpected forwarded header options: %+v", opts)
+	}
+
+	opts, err = parseFlagsWithArgs(t, "-no-forwarded-headers", "https://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.NoForwardedHeaders {
+		t.Error("expected -no-forwarded-headers to be set")
+	}
+}
+
+func TestParseFlagsWithRemapStatus(t *testing.T) {
+	opts, err := parseFlagsWithArgs(t, "-remap-status", "418=200", "-remap-status", "404=410,500=502", "https://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(opts.RemapStatus, "|") != "418=200|404=410,500=502" {
+		t.Errorf("expected both -remap-status values, got %v", opts.RemapStatus)
+	}
+}
+
+func TestParseFlagsWithAllowStatus(t *testing.T) {
+	opts, err := parseFlagsWithArgs(t, "-allow-status", "200,201", "-allow-status", "4xx", "https://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(opts.AllowStatus, "|") != "200,201|4xx" {
+		t.Errorf("expected both -allow-status values, got %v", opts.AllowStatus)
+	}
+}
+
+func TestParseFlagsWithAddHeaders(t *testing.T) {
+	opts, err := parseFlagsWithArgs(t, "-H", "X-Env: prod", "-H-add", "X-Tags: beta", "-H-add", "X-Tags: canary", "https://example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(opts.Headers) != 1 || opts.Headers[0] != "X-Env: prod" {
+		t.Errorf("expected -H to stay separate, got %v", opts.Headers)
+	}
+	if strings.Join(opts.AddHeaders, "|") != "X-Tags: beta|X-Tags: canary" {
+		t.Errorf("expected both -H-add values, got %v", opts.AddHeaders)
+	}
+}
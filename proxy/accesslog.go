@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// accessLogEntry is one JSON line in the access log.
+type accessLogEntry struct {
+	Time       string `json:"time"`
+	RequestID  string `json:"request_id"`
+	ClientIP   string `json:"client_ip"`
+	Method     string `json:"method"`
+	Host       string `json:"host"`
+	Path       string `json:"path"`
+	Status     int    `json:"status"`
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	UserAgent  string `json:"user_agent,omitempty"`
+
+	// Requests a backend answered: from sending the request until its
+	// response headers arrived, and until the body was relayed
+	TTFBMs  *int64 `json:"ttfb_ms,omitempty"`
+	TotalMs *int64 `json:"total_ms,omitempty"`
+
+	// WebSocket tunnels only
+	WebSocketRequestedProtocol string `json:"websocket_requested_protocol,omitempty"`
+	WebSocketProtocol          string `json:"websocket_protocol,omitempty"`
+	WebSocketBytesIn           int64  `json:"websocket_bytes_in,omitempty"`
+	WebSocketBytesOut          int64  `json:"websocket_bytes_out,omitempty"`
+}
+
+// accessLog appends JSON lines to a file. The file can be reopened in place so
+// external tools such as logrotate can move it aside and signal the proxy.
+type accessLog struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openAccessLog(path string) (*accessLog, error) {
+	file, err := openAccessLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &accessLog{path: path, file: file}, nil
+}
+
+func openAccessLogFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644) // #nosec G302 G304 -- path is supplied by the operator, log shippers need read access
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access log: %w", err)
+	}
+	return file, nil
+}
+
+func (a *accessLog) write(entry accessLogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return os.ErrClosed
+	}
+	_, err = a.file.Write(line)
+	return err
+}
+
+// reopen swaps in a freshly opened handle for the configured path, syncing
+// and closing the previous one.
+func (a *accessLog) reopen() error {
+	file, err := openAccessLogFile(a.path)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	old := a.file
+	a.file = file
+	a.mu.Unlock()
+
+	if old == nil {
+		return nil
+	}
+	_ = old.Sync()
+	return old.Close()
+}
+
+func (a *accessLog) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+	_ = a.file.Sync()
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+func newAccessLogEntry(r *http.Request, rw *responseWriter, clientIP, requestID string, start time.Time) accessLogEntry {
+	entry := accessLogEntry{
+		Time:       start.UTC().Format(time.RFC3339Nano),
+		RequestID:  requestID,
+		ClientIP:   clientIP,
+		Method:     r.Method,
+		Host:       r.Host,
+		Path:       r.URL.Path,
+		Status:     rw.statusCode,
+		Bytes:      rw.bytesWritten,
+		DurationMs: time.Since(start).Milliseconds(),
+		UserAgent:  r.UserAgent(),
+	}
+	if backend := rw.backend; backend != nil {
+		ttfb, total := backend.TTFB.Milliseconds(), backend.Total.Milliseconds()
+		entry.TTFBMs, entry.TotalMs = &ttfb, &total
+	}
+	if ws := rw.webSocket; ws != nil {
+		entry.WebSocketRequestedProtocol = ws.RequestedProtocol
+		entry.WebSocketProtocol = ws.Protocol
+		entry.WebSocketBytesIn = ws.BytesIn
+		entry.WebSocketBytesOut = ws.BytesOut
+	}
+	return entry
+}
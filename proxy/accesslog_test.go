@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readAccessLog(t *testing.T, path string) []accessLogEntry {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open access log: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []accessLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry accessLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid access log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestServeHTTPWritesAccessLog(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer backend.Close()
+
+	path := filepath.Join(t.TempDir(), "logs", "access.log")
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := New(Config{
+		ListenAddr:     ":8080",
+		TargetURL:      mustParseURL(backend.URL),
+		SelfHealthPath: "/healthz",
+		AccessLogPath:  path,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "http://proxy.local/items?x=1", nil)
+	req.Header.Set("X-Request-ID", "abc123")
+	req.Header.Set("User-Agent", "test-agent")
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Health probes are not access logged
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	if err := proxy.Close(); err != nil {
+		t.Fatalf("unexpected error closing proxy: %v", err)
+	}
+
+	entries := readAccessLog(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 access log line, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Method != "POST" || entry.Path != "/items" || entry.Host != "proxy.local" {
+		t.Errorf("unexpected request fields: %+v", entry)
+	}
+	if entry.Status != http.StatusCreated || entry.Bytes != 5 {
+		t.Errorf("expected status 201 and 5 bytes, got %d and %d", entry.Status, entry.Bytes)
+	}
+	if entry.RequestID != "abc123" || entry.UserAgent != "test-agent" || entry.Time == "" {
+		t.Errorf("unexpected metadata fields: %+v", entry)
+	}
+}
+
+func TestAccessLogReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+
+	accessLog, err := openAccessLog(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = accessLog.close() }()
+
+	if err := accessLog.write(accessLogEntry{Path: "/before"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Simulate logrotate moving the file aside before signalling
+	rotated := filepath.Join(dir, "access.log.1")
+	if err := os.Rename(path, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if err := accessLog.reopen(); err != nil {
+		t.Fatalf("unexpected error reopening: %v", err)
+	}
+	if err := accessLog.write(accessLogEntry{Path: "/after"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if entries := readAccessLog(t, rotated); len(entries) != 1 || entries[0].Path != "/before" {
+		t.Errorf("expected rotated file to hold the earlier line, got %+v", entries)
+	}
+	if entries := readAccessLog(t, path); len(entries) != 1 || entries[0].Path != "/after" {
+		t.Errorf("expected new file to hold the later line, got %+v", entries)
+	}
+}
+
+func TestAccessLogWriteAfterClose(t *testing.T) {
+	accessLog, err := openAccessLog(filepath.Join(t.TempDir(), "access.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := accessLog.close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := accessLog.write(accessLogEntry{}); err == nil {
+		t.Error("expected error writing to a closed access log")
+	}
+}
+
+func TestNewAccessLogError(t *testing.T) {
+	_, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL("http://backend"),
+		AccessLogPath: filepath.Join(t.TempDir(), "missing", "access.log"),
+	})
+	if err == nil {
+		t.Error("expected error for an access log in a missing directory")
+	}
+}
+
+func TestServeHTTPAccessLogBackendTiming(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		http.NewResponseController(w).Flush()
+		time.Sleep(30 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	}))
+	defer backend.Close()
+
+	allow, _ := ParseCIDRs([]string{"192.0.2.0/24"})
+	path := filepath.Join(t.TempDir(), "access.log")
+	proxy, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		AllowCIDRs:    allow,
+		AccessLogPath: path,
+		Logger:        log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	allowed := httptest.NewRequest("GET", "http://proxy.local/report", nil)
+	allowed.RemoteAddr = "192.0.2.10:1234"
+	proxy.ServeHTTP(httptest.NewRecorder(), allowed)
+
+	// Answered by the proxy itself, so there is no backend timing
+	rejected := httptest.NewRequest("GET", "http://proxy.local/report", nil)
+	rejected.RemoteAddr = "203.0.113.5:1234"
+	proxy.ServeHTTP(httptest.NewRecorder(), rejected)
+	_ = proxy.Close()
+
+	entries := readAccessLog(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 access log lines, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.TTFBMs == nil || entry.TotalMs == nil {
+		t.Fatalf("expected ttfb_ms and total_ms, got %+v", entry)
+	}
+	if *entry.TTFBMs < 30 || *entry.TotalMs < 60 {
+		t.Errorf("expected ttfb >= 30ms and total >= 60ms, got %d and %d", *entry.TTFBMs, *entry.TotalMs)
+	}
+	if *entry.TTFBMs > *entry.TotalMs {
+		t.Errorf("expected ttfb <= total, got %d > %d", *entry.TTFBMs, *entry.TotalMs)
+	}
+
+	if rejected := entries[1]; rejected.Status != http.StatusForbidden || rejected.TTFBMs != nil || rejected.TotalMs != nil {
+		t.Errorf("expected a 403 without backend timing, got %+v", rejected)
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseCIDRs parses CIDR notation into networks. Bare IP addresses are
+// accepted as single-host networks.
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid CIDR: %q", value)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %q", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipAllowed applies the deny list first, then the allow list if one is set.
+func (p *Proxy) ipAllowed(clientIP string) bool {
+	if len(p.config.AllowCIDRs) == 0 && len(p.config.DenyCIDRs) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	if containsIP(p.config.DenyCIDRs, ip) {
+		return false
+	}
+
+	if len(p.config.AllowCIDRs) > 0 {
+		return containsIP(p.config.AllowCIDRs, ip)
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []string
+		expectCount int
+		expectError bool
+	}{
+		{"ipv4 cidr", []string{"10.0.0.0/8"}, 1, false},
+		{"ipv6 cidr", []string{"2001:db8::/32"}, 1, false},
+		{"bare ipv4 address", []string{"192.168.1.1"}, 1, false},
+		{"bare ipv6 address", []string{"::1"}, 1, false},
+		{"mixed", []string{"10.0.0.0/8", "fd00::/8", "127.0.0.1"}, 3, false},
+		{"malformed cidr", []string{"10.0.0.0/33"}, 0, true},
+		{"garbage", []string{"not-a-cidr"}, 0, true},
+		{"empty", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networks, err := ParseCIDRs(tt.values)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(networks) != tt.expectCount {
+				t.Errorf("expected %d networks, got %d", tt.expectCount, len(networks))
+			}
+		})
+	}
+}
+
+func TestServeHTTPIPFiltering(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	mustParseCIDRs := func(values ...string) []*net.IPNet {
+		networks, err := ParseCIDRs(values)
+		if err != nil {
+			t.Fatalf("failed to parse CIDRs: %v", err)
+		}
+		return networks
+	}
+
+	tests := []struct {
+		name         string
+		allow        []*net.IPNet
+		deny         []*net.IPNet
+		remoteAddr   string
+		expectStatus int
+	}{
+		{"no rules", nil, nil, "203.0.113.5:1234", http.StatusOK},
+		{"allowed ipv4", mustParseCIDRs("10.0.0.0/8"), nil, "10.1.2.3:1234", http.StatusOK},
+		{"not in allow list", mustParseCIDRs("10.0.0.0/8"), nil, "192.168.1.1:1234", http.StatusForbidden},
+		{"denied ipv4", nil, mustParseCIDRs("192.168.0.0/16"), "192.168.1.1:1234", http.StatusForbidden},
+		{"not in deny list", nil, mustParseCIDRs("192.168.0.0/16"), "10.0.0.1:1234", http.StatusOK},
+		{"deny overrides overlapping allow", mustParseCIDRs("10.0.0.0/8"), mustParseCIDRs("10.1.0.0/16"), "10.1.2.3:1234", http.StatusForbidden},
+		{"allow outside overlapping deny", mustParseCIDRs("10.0.0.0/8"), mustParseCIDRs("10.1.0.0/16"), "10.2.0.1:1234", http.StatusOK},
+		{"allowed ipv6", mustParseCIDRs("2001:db8::/32"), nil, "[2001:db8::1]:1234", http.StatusOK},
+		{"denied ipv6", nil, mustParseCIDRs("2001:db8::/32"), "[2001:db8::1]:1234", http.StatusForbidden},
+		{"ipv4 not matched by ipv6 allow list", mustParseCIDRs("2001:db8::/32"), nil, "10.0.0.1:1234", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL(backend.URL),
+				AllowCIDRs: tt.allow,
+				DenyCIDRs:  tt.deny,
+			}
+			proxy, _ := New(config)
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+			req.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != tt.expectStatus {
+				t.Errorf("expected status %d, got %d", tt.expectStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// stats counts proxied requests by final status code for the admin API.
+type stats struct {
+	start    time.Time
+	total    atomic.Uint64
+	mu       sync.Mutex
+	statuses map[int]uint64
+}
+
+func newStats() *stats {
+	return &stats{start: time.Now(), statuses: make(map[int]uint64)}
+}
+
+func (s *stats) record(status int) {
+	s.total.Add(1)
+	s.mu.Lock()
+	s.statuses[status]++
+	s.mu.Unlock()
+}
+
+// statsSnapshot is the JSON document served at /stats.
+type statsSnapshot struct {
+	UptimeSeconds float64           `json:"uptime_seconds"`
+	TotalRequests uint64            `json:"total_requests"`
+	StatusCounts  map[string]uint64 `json:"status_counts"`
+}
+
+func (s *stats) snapshot(now time.Time) statsSnapshot {
+	snapshot := statsSnapshot{
+		UptimeSeconds: now.Sub(s.start).Seconds(),
+		TotalRequests: s.total.Load(),
+		StatusCounts:  make(map[string]uint64),
+	}
+	s.mu.Lock()
+	for status, count := range s.statuses {
+		snapshot.StatusCounts[strconv.Itoa(status)] = count
+	}
+	s.mu.Unlock()
+	return snapshot
+}
+
+// AdminHandler returns the admin API: /config with the effective
+// configuration (secrets redacted), /stats with uptime and request counts,
+// POST /drain and /undrain to switch draining mode, and POST
+// /maintenance/on and /maintenance/off to switch maintenance mode. With
+// Config.PprofHandler it also serves that handler under /debug/pprof/. It is
+// meant for a separate listener and is never served on the proxy path.
+func (p *Proxy) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.effectiveConfig())
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.stats.snapshot(time.Now()))
+	})
+	mux.HandleFunc("POST /drain", func(w http.ResponseWriter, r *http.Request) {
+		p.Drain()
+		writeJSON(w, map[string]bool{"draining": true})
+	})
+	mux.HandleFunc("POST /undrain", func(w http.ResponseWriter, r *http.Request) {
+		p.Undrain()
+		writeJSON(w, map[string]bool{"draining": false})
+	})
+	mux.HandleFunc("POST /maintenance/on", func(w http.ResponseWriter, r *http.Request) {
+		p.EnterMaintenance()
+		writeJSON(w, map[string]bool{"maintenance": true})
+	})
+	mux.HandleFunc("POST /maintenance/off", func(w http.ResponseWriter, r *http.Request) {
+		p.ExitMaintenance()
+		writeJSON(w, map[string]bool{"maintenance": false})
+	})
+	if p.config.PprofHandler != nil {
+		mux.Handle("/debug/pprof/", p.config.PprofHandler)
+	}
+	return mux
+}
+
+// serveAdmin runs the admin API on its own listener until it fails.
+func (p *Proxy) serveAdmin() {
+	writeTimeout := 15 * time.Second
+	if p.config.PprofHandler != nil {
+		// CPU profiles and traces stream for ?seconds=, 30 by default
+		writeTimeout = 0
+	}
+	server := &http.Server{
+		Addr:         p.config.AdminAddr,
+		Handler:      p.AdminHandler(),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  60 * time.Second,
+	}
+	if !p.track([]*http.Server{server}, nil) {
+		return
+	}
+	p.logger.Printf("Starting admin API on %s", p.config.AdminAddr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		p.logger.Printf("Admin API on %s stopped: %v", p.config.AdminAddr, err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(value)
+}
+
+// effectiveConfig renders the configuration after defaults were applied as
+// plain JSON values. Passwords and credential-like header values are
+// redacted; loggers and other runtime hooks are left out.
+func (p *Proxy) effectiveConfig() map[string]any {
+	current := p.config
+	p.live.Load().apply(&current)
+	config := reflect.ValueOf(current)
+	result := make(map[string]any, config.NumField())
+	for i := 0; i < config.NumField(); i++ {
+		name := config.Type().Field(i).Name
+		switch name {
+		case "Logger", "SlowLogger", "TracerProvider":
+			continue
+		case "BasicAuth":
+			users := make(map[string]string, len(p.config.BasicAuth))
+			for user := range p.config.BasicAuth {
+				users[user] = redacted
+			}
+			result[name] = users
+		case "CustomHeaders", "ResponseHeaders":
+			result[name] = redactHeaders(config.Field(i).Interface().(map[string]string))
+		case "AddHeaders":
+			headers := make(map[string][]string, len(current.AddHeaders))
+			for header, values := range current.AddHeaders {
+				if isSensitiveHeader(header) {
+					values = []string{redacted}
+				}
+				headers[header] = values
+			}
+			result[name] = headers
+		default:
+			result[name] = configValue(config.Field(i))
+		}
+	}
+	return result
+}
+
+// configValue converts a configuration value to something encoding/json
+// renders readably: URLs (without passwords), durations, networks and
+// patterns become strings, and byte slices only report their size.
+func configValue(v reflect.Value) any {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil
+	}
+	if u, ok := v.Interface().(*url.URL); ok {
+		return u.Redacted()
+	}
+	if stringer, ok := v.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return fmt.Sprintf("%d bytes", v.Len())
+		}
+		values := make([]any, v.Len())
+		for i := range values {
+			values[i] = configValue(v.Index(i))
+		}
+		return values
+	case reflect.Map:
+		values := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			values[fmt.Sprint(iter.Key().Interface())] = configValue(iter.Value())
+		}
+		return values
+	case reflect.Struct:
+		values := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			switch {
+			case !field.IsExported():
+			case field.Name == "Headers":
+				// Per-backend headers often carry that backend's credentials
+				values[field.Name] = redactHeaders(v.Field(i).Interface().(map[string]string))
+			default:
+				values[field.Name] = configValue(v.Field(i))
+			}
+		}
+		return values
+	case reflect.Func, reflect.Interface:
+		return nil
+	}
+	return v.Interface()
+}
+
+// redactHeaders copies headers with credential-like values replaced.
+func redactHeaders(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for header, value := range headers {
+		if isSensitiveHeader(header) {
+			value = redacted
+		}
+		result[header] = value
+	}
+	return result
+}
+
+// isSensitiveHeader reports whether a header value likely carries a
+// credential and must not be shown.
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, marker := range []string{"authorization", "cookie", "token", "secret", "password", "key"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusRange is an inclusive range of HTTP status codes.
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// ParseStatusRanges parses -allow-status values, each a status code ("204"),
+// a class ("4xx"), an inclusive range ("200-299") or a comma-separated list
+// of them.
+func ParseStatusRanges(values []string) ([]StatusRange, error) {
+	var ranges []StatusRange
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			statusRange, err := parseStatusRange(strings.TrimSpace(field))
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, statusRange)
+		}
+	}
+	return ranges, nil
+}
+
+func parseStatusRange(value string) (StatusRange, error) {
+	invalid := fmt.Errorf("invalid status %q (expected a code, a class like 4xx or a range like 200-299, within 100-599)", value)
+
+	if class, ok := strings.CutSuffix(strings.ToLower(value), "xx"); ok {
+		digit, err := strconv.Atoi(class)
+		if err != nil || len(class) != 1 || digit < 1 || digit > 5 {
+			return StatusRange{}, invalid
+		}
+		return StatusRange{Min: digit * 100, Max: digit*100 + 99}, nil
+	}
+
+	rawMin, rawMax, isRange := strings.Cut(value, "-")
+	if !isRange {
+		rawMax = rawMin
+	}
+	minCode, err := strconv.Atoi(strings.TrimSpace(rawMin))
+	if err != nil {
+		return StatusRange{}, invalid
+	}
+	maxCode, err := strconv.Atoi(strings.TrimSpace(rawMax))
+	if err != nil || minCode < 100 || maxCode > 599 || minCode > maxCode {
+		return StatusRange{}, invalid
+	}
+	return StatusRange{Min: minCode, Max: maxCode}, nil
+}
+
+// statusAllowed reports whether a backend status may be relayed to the
+// client. Every status is allowed when Config.AllowedStatuses is empty.
+func (p *Proxy) statusAllowed(status int) bool {
+	if len(p.config.AllowedStatuses) == 0 {
+		return true
+	}
+	for _, statusRange := range p.config.AllowedStatuses {
+		if status >= statusRange.Min && status <= statusRange.Max {
+			return true
+		}
+	}
+	return false
+}
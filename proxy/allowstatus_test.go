@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusRanges(t *testing.T) {
+	ranges, err := ParseStatusRanges([]string{"200,201", " 4XX ", "300-302"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []StatusRange{{200, 200}, {201, 201}, {400, 499}, {300, 302}}
+	if len(ranges) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ranges)
+	}
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Errorf("expected %v at %d, got %v", expected[i], i, ranges[i])
+		}
+	}
+
+	for _, value := range []string{"", "abc", "6xx", "0xx", "45x", "99", "600", "299-200", "200-", "-299", "20xx"} {
+		if _, err := ParseStatusRanges([]string{value}); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestServeHTTPAllowStatus(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, "backend secret details")
+	}))
+	defer backend.Close()
+
+	allowed, _ := ParseStatusRanges([]string{"200,201", "4xx", "301-302"})
+	proxy, err := New(Config{
+		ListenAddr:      ":8080",
+		TargetURL:       mustParseURL(backend.URL),
+		AllowedStatuses: allowed,
+		Logger:          log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		status  int
+		allowed bool
+	}{
+		{"listed code", http.StatusCreated, true},
+		{"class", http.StatusTeapot, true},
+		{"range", http.StatusFound, true},
+		{"outside the range", http.StatusNotModified, false},
+		{"unlisted code", http.StatusAccepted, false},
+		{"unlisted class", http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/"+strconv.Itoa(tt.status), nil))
+
+			if tt.allowed {
+				if w.Code != tt.status {
+					t.Errorf("expected status %d relayed, got %d", tt.status, w.Code)
+				}
+				return
+			}
+			if w.Code != http.StatusBadGateway {
+				t.Errorf("expected 502 for disallowed status %d, got %d", tt.status, w.Code)
+			}
+			if strings.Contains(w.Body.String(), "secret") {
+				t.Errorf("expected the backend body to be withheld, got %q", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+const basicAuthRealm = "goreflector"
+
+// checkBasicAuth validates the request's Basic credentials against every
+// configured user without short-circuiting, so timing does not reveal which
+// usernames exist.
+func (p *Proxy) checkBasicAuth(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	userHash := sha256.Sum256([]byte(username))
+	passHash := sha256.Sum256([]byte(password))
+
+	matched := 0
+	for expectedUser, expectedPass := range p.config.BasicAuth {
+		expectedUserHash := sha256.Sum256([]byte(expectedUser))
+		expectedPassHash := sha256.Sum256([]byte(expectedPass))
+
+		userMatch := subtle.ConstantTimeCompare(userHash[:], expectedUserHash[:])
+		passMatch := subtle.ConstantTimeCompare(passHash[:], expectedPassHash[:])
+		matched |= userMatch & passMatch
+	}
+
+	return matched == 1
+}
+
+func (p *Proxy) requireBasicAuth(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+basicAuthRealm+`", charset="UTF-8"`)
+	p.writeError(w, "Unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPBasicAuth(t *testing.T) {
+	var receivedAuth string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name          string
+		username      string
+		password      string
+		setAuth       bool
+		forwardAuth   bool
+		expectStatus  int
+		expectForward bool
+	}{
+		{"missing credentials", "", "", false, false, http.StatusUnauthorized, false},
+		{"wrong password", "alice", "wrong", true, false, http.StatusUnauthorized, false},
+		{"unknown user", "mallory", "secret1", true, false, http.StatusUnauthorized, false},
+		{"valid credentials", "alice", "secret1", true, false, http.StatusOK, false},
+		{"second user", "bob", "secret2", true, false, http.StatusOK, false},
+		{"valid credentials forwarded", "alice", "secret1", true, true, http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receivedAuth = ""
+			config := Config{
+				ListenAddr:  ":8080",
+				TargetURL:   mustParseURL(backend.URL),
+				BasicAuth:   map[string]string{"alice": "secret1", "bob": "secret2"},
+				ForwardAuth: tt.forwardAuth,
+			}
+			proxy, _ := New(config)
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+			if tt.setAuth {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			w := httptest.NewRecorder()
+
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != tt.expectStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectStatus, w.Code)
+			}
+			if tt.expectStatus == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header on 401")
+			}
+			if tt.expectStatus == http.StatusOK && (receivedAuth != "") != tt.expectForward {
+				t.Errorf("expected credentials forwarded=%v, backend received %q", tt.expectForward, receivedAuth)
+			}
+		})
+	}
+}
+
+func TestBasicAuthCustomHeaderStillApplied(t *testing.T) {
+	var receivedAuth string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedAuth = r.Header.Get("Authorization")
+	}))
+	defer backend.Close()
+
+	config := Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		BasicAuth:     map[string]string{"alice": "secret1"},
+		CustomHeaders: map[string]string{"Authorization": "Bearer backend-token"},
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	req.SetBasicAuth("alice", "secret1")
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	if receivedAuth != "Bearer backend-token" {
+		t.Errorf("expected custom Authorization header, got %q", receivedAuth)
+	}
+}
@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	autocertHTTPSAddr     = ":443"
+	autocertChallengeAddr = ":80"
+)
+
+// newAutocertManager returns a Let's Encrypt certificate manager limited to
+// domains. Certificates are kept in cacheDir when set, otherwise only in
+// memory, which means they are requested again after every restart.
+func newAutocertManager(domains []string, cacheDir string) *autocert.Manager {
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+	}
+	if cacheDir != "" {
+		manager.Cache = autocert.DirCache(cacheDir)
+	}
+	return manager
+}
+
+// serveACMEChallenges answers HTTP-01 challenges on :80 and redirects every
+// other plain HTTP request to HTTPS.
+func (p *Proxy) serveACMEChallenges() {
+	server := &http.Server{
+		Addr:         autocertChallengeAddr,
+		Handler:      p.autocert.HTTPHandler(nil),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	if !p.track([]*http.Server{server}, nil) {
+		return
+	}
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		p.logger.Printf("ACME challenge server on %s stopped: %v", autocertChallengeAddr, err)
+	}
+}
@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewAutocertManagerHostPolicy(t *testing.T) {
+	manager := newAutocertManager([]string{"example.com", "www.example.com"}, "")
+
+	if err := manager.HostPolicy(context.Background(), "www.example.com"); err != nil {
+		t.Errorf("expected configured domain to be allowed, got %v", err)
+	}
+	if err := manager.HostPolicy(context.Background(), "evil.example.net"); err == nil {
+		t.Error("expected unconfigured domain to be rejected")
+	}
+	if manager.Cache != nil {
+		t.Error("expected no certificate cache without a directory")
+	}
+}
+
+func TestNewAutocertManagerCacheDir(t *testing.T) {
+	manager := newAutocertManager([]string{"example.com"}, t.TempDir())
+	if manager.Cache == nil {
+		t.Error("expected a directory certificate cache")
+	}
+}
+
+func TestNewAutocert(t *testing.T) {
+	proxy, err := New(Config{
+		ListenAddr:      ":8080",
+		TargetURL:       mustParseURL("http://example.com"),
+		AutocertDomains: []string{"example.com"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if proxy.autocert == nil {
+		t.Fatal("expected autocert manager to be configured")
+	}
+	if proxy.autocert.TLSConfig().GetCertificate == nil {
+		t.Error("expected TLS config to obtain certificates through autocert")
+	}
+}
+
+func TestNewAutocertWithManualCertificate(t *testing.T) {
+	_, err := New(Config{
+		ListenAddr:      ":8080",
+		TargetURL:       mustParseURL("http://example.com"),
+		AutocertDomains: []string{"example.com"},
+		TLSCertFile:     "server.crt",
+		TLSKeyFile:      "server.key",
+	})
+	if err == nil {
+		t.Fatal("expected error combining autocert with a manual certificate")
+	}
+}
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPBackendHeaders(t *testing.T) {
+	type seen struct{ token, global, client string }
+	newBackend := func(name string, got map[string]seen) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got[name] = seen{r.Header.Get("X-Token"), r.Header.Get("X-Global"), r.Header.Get("X-Client")}
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+
+	got := make(map[string]seen)
+	pool := newBackend("pool", got)
+	defer pool.Close()
+	hostRoute := newBackend("host", got)
+	defer hostRoute.Close()
+	pathRoute := newBackend("path", got)
+	defer pathRoute.Close()
+	plain := newBackend("plain", got)
+	defer plain.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:    ":8080",
+		Backends:      []Backend{{URL: mustParseURL(pool.URL), Weight: 1, Headers: map[string]string{"X-Token": "pool-secret"}}},
+		Routes:        []Route{{Host: "admin.example.com", Target: mustParseURL(hostRoute.URL), Headers: map[string]string{"x-token": "host-secret"}}},
+		PathRoutes:    []PathRoute{{Prefix: "/billing", Target: mustParseURL(pathRoute.URL), Headers: map[string]string{"X-Token": "path-secret"}}},
+		CustomHeaders: map[string]string{"X-Global": "everywhere", "X-Token": "global"},
+		Logger:        log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	noHeaders, err := New(Config{
+		ListenAddr:    ":8080",
+		Routes:        []Route{{Host: "admin.example.com", Target: mustParseURL(plain.URL)}},
+		CustomHeaders: map[string]string{"X-Global": "everywhere", "X-Token": "global"},
+		Logger:        log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		proxy   *Proxy
+		host    string
+		path    string
+		backend string
+		expect  seen
+	}{
+		{"default pool", proxy, "www.example.com", "/", "pool", seen{"pool-secret", "everywhere", "client"}},
+		{"host route", proxy, "admin.example.com", "/", "host", seen{"host-secret", "everywhere", "client"}},
+		{"path route", proxy, "www.example.com", "/billing/invoices", "path", seen{"path-secret", "everywhere", "client"}},
+		{"backend without headers", noHeaders, "admin.example.com", "/", "plain", seen{"global", "everywhere", "client"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://"+tt.host+tt.path, nil)
+			req.Header.Set("X-Client", "client")
+			w := httptest.NewRecorder()
+			tt.proxy.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got[tt.backend] != tt.expect {
+				t.Errorf("expected %s backend to see %+v, got %+v", tt.backend, tt.expect, got[tt.backend])
+			}
+		})
+	}
+}
+
+func TestBackendHeadersNotSentToFallback(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+
+	var gotToken, gotGlobal string
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotToken, gotGlobal = r.Header.Get("X-Token"), r.Header.Get("X-Global")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fallback.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:    ":8080",
+		Backends:      []Backend{{URL: mustParseURL(primary.URL), Weight: 1, Headers: map[string]string{"X-Token": "primary-secret"}}},
+		CustomHeaders: map[string]string{"X-Global": "everywhere"},
+		FallbackURL:   mustParseURL(fallback.URL),
+		Logger:        log.New(io.Discard, "", 0),
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the fallback's 200, got %d", w.Code)
+	}
+	if gotToken != "" {
+		t.Errorf("expected the primary's X-Token to stay with it, fallback got %q", gotToken)
+	}
+	if gotGlobal != "everywhere" {
+		t.Errorf("expected global headers to reach the fallback, got %q", gotGlobal)
+	}
+}
+
+func TestServeHTTPTargetURLCredentials(t *testing.T) {
+	var gotUser, gotPassword string
+	var gotOK bool
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, gotPassword, gotOK = r.BasicAuth()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	target := mustParseURL(backend.URL)
+	target.User = url.UserPassword("svc", "s3cret-pass")
+
+	var logs bytes.Buffer
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  target,
+		Debug:      true,
+		Logger:     log.New(&logs, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/items", nil)
+	req.Header.Set("Authorization", "Bearer client-token")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !gotOK || gotUser != "svc" || gotPassword != "s3cret-pass" {
+		t.Errorf("expected basic auth svc/s3cret-pass from the target URL, got %q/%q (ok=%v)", gotUser, gotPassword, gotOK)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("svc:s3cret-pass"))
+	for _, secret := range []string{"s3cret-pass", encoded, "svc@"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("expected logs without credentials, found %q in:\n%s", secret, logs.String())
+		}
+	}
+
+	if got := StripUserinfo(target); got != backend.URL {
+		t.Errorf("expected %q without userinfo, got %q", backend.URL, got)
+	}
+}
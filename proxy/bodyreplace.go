@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultBodyReplaceTypes are the media types whose bodies BodyReplacements
+// apply to unless Config.BodyReplaceTypes says otherwise.
+var DefaultBodyReplaceTypes = []string{"text/html", "text/css", "application/javascript"}
+
+// BodyReplacement replaces every occurrence of Old in a response body with
+// New. When Pattern is set it is matched instead of Old, and New may
+// reference capture groups as $1 or ${name}, as in regexp.Regexp.ReplaceAll.
+type BodyReplacement struct {
+	Old     string
+	Pattern *regexp.Regexp
+	New     string
+}
+
+// ParseBodyReplacements parses -body-replace values of the form "old=new",
+// or with regex set -body-replace-regex values of the form
+// "pattern=replacement". The first "=" separates the two, so the old text
+// cannot contain one.
+func ParseBodyReplacements(values []string, regex bool) ([]BodyReplacement, error) {
+	replacements := make([]BodyReplacement, 0, len(values))
+	for _, value := range values {
+		old, replacement, ok := strings.Cut(value, "=")
+		if !ok || old == "" {
+			return nil, fmt.Errorf("invalid body replacement %q (expected 'old=new')", value)
+		}
+
+		if !regex {
+			replacements = append(replacements, BodyReplacement{Old: old, New: replacement})
+			continue
+		}
+		re, err := regexp.Compile(old)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body replacement pattern %q: %w", old, err)
+		}
+		replacements = append(replacements, BodyReplacement{Pattern: re, New: replacement})
+	}
+	return replacements, nil
+}
+
+// shouldReplaceBody reports whether resp is text the body replacements
+// apply to, in an encoding transformResponseBody can undo.
+func (p *Proxy) shouldReplaceBody(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	types := p.config.BodyReplaceTypes
+	if len(types) == 0 {
+		types = DefaultBodyReplaceTypes
+	}
+	if !slices.ContainsFunc(types, func(t string) bool { return strings.EqualFold(t, mediaType) }) {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity", "gzip", "x-gzip", "deflate":
+		return true
+	}
+	return false
+}
+
+// replaceBody applies every body replacement in order, each one seeing the
+// result of the previous.
+func (p *Proxy) replaceBody(body []byte) ([]byte, error) {
+	for _, replacement := range p.config.BodyReplacements {
+		if replacement.Pattern != nil {
+			body = replacement.Pattern.ReplaceAll(body, []byte(replacement.New))
+		} else {
+			body = bytes.ReplaceAll(body, []byte(replacement.Old), []byte(replacement.New))
+		}
+	}
+	return body, nil
+}
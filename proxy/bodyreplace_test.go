@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestParseBodyReplacements(t *testing.T) {
+	replacements, err := ParseBodyReplacements([]string{"https://backend.internal=https://proxy.example.com", "a=b=c"}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(replacements) != 2 || replacements[0].Old != "https://backend.internal" || replacements[0].New != "https://proxy.example.com" {
+		t.Errorf("unexpected replacements: %+v", replacements)
+	}
+	if replacements[1].Old != "a" || replacements[1].New != "b=c" || replacements[1].Pattern != nil {
+		t.Errorf("expected the first = to split, got %+v", replacements[1])
+	}
+
+	regex, err := ParseBodyReplacements([]string{`http://([a-z]+)\.internal=https://$1.example.com`}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if regex[0].Pattern == nil {
+		t.Error("expected a compiled pattern")
+	}
+
+	for _, value := range []string{"no-separator", "=new"} {
+		if _, err := ParseBodyReplacements([]string{value}, false); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+	if _, err := ParseBodyReplacements([]string{"(unclosed=x"}, true); err == nil {
+		t.Error("expected error for an invalid pattern")
+	}
+}
+
+func TestServeHTTPBodyReplace(t *testing.T) {
+	const page = `<a href="http://app.internal:8080/login">Login</a> <script src="http://cdn.internal/app.js"></script>`
+
+	tests := []struct {
+		name        string
+		contentType string
+		gzip        bool
+		literal     []string
+		regex       []string
+		want        string
+	}{
+		{
+			name:        "literal",
+			contentType: "text/html; charset=utf-8",
+			literal:     []string{"http://app.internal:8080=https://proxy.example.com"},
+			want:        `<a href="https://proxy.example.com/login">Login</a> <script src="http://cdn.internal/app.js"></script>`,
+		},
+		{
+			name:        "regex",
+			contentType: "text/html",
+			regex:       []string{`http://([a-z]+)\.internal(:\d+)?=https://$1.example.com`},
+			want:        `<a href="https://app.example.com/login">Login</a> <script src="https://cdn.example.com/app.js"></script>`,
+		},
+		{
+			name:        "literal then regex",
+			contentType: "text/html",
+			literal:     []string{"http://app.internal:8080=https://proxy.example.com"},
+			regex:       []string{`http://cdn\.internal=https://proxy.example.com/cdn`},
+			want:        `<a href="https://proxy.example.com/login">Login</a> <script src="https://proxy.example.com/cdn/app.js"></script>`,
+		},
+		{
+			name:        "gzip encoded",
+			contentType: "text/html",
+			gzip:        true,
+			literal:     []string{"http://app.internal:8080=https://proxy.example.com"},
+			want:        `<a href="https://proxy.example.com/login">Login</a> <script src="http://cdn.internal/app.js"></script>`,
+		},
+		{
+			name:        "other content type untouched",
+			contentType: "application/json",
+			literal:     []string{"http://app.internal:8080=https://proxy.example.com"},
+			want:        page,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				body := []byte(page)
+				if tt.gzip {
+					var buf bytes.Buffer
+					gz := gzip.NewWriter(&buf)
+					_, _ = gz.Write(body)
+					_ = gz.Close()
+					body = buf.Bytes()
+					w.Header().Set("Content-Encoding", "gzip")
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+				_, _ = w.Write(body)
+			}))
+			defer backend.Close()
+
+			literal, _ := ParseBodyReplacements(tt.literal, false)
+			regex, _ := ParseBodyReplacements(tt.regex, true)
+			proxy, err := New(Config{
+				ListenAddr:       ":8080",
+				TargetURL:        mustParseURL(backend.URL),
+				BodyReplacements: append(literal, regex...),
+				Logger:           log.New(io.Discard, "", 0),
+			})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+			if tt.gzip {
+				req.Header.Set("Accept-Encoding", "gzip")
+			}
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Length"); got != strconv.Itoa(w.Body.Len()) {
+				t.Errorf("expected Content-Length %d, got %s", w.Body.Len(), got)
+			}
+
+			body := w.Body.Bytes()
+			if tt.gzip {
+				if w.Header().Get("Content-Encoding") != "gzip" {
+					t.Fatalf("expected the body to stay gzip-encoded")
+				}
+				gz, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				body, _ = io.ReadAll(gz)
+			}
+			if string(body) != tt.want {
+				t.Errorf("expected body %q, got %q", tt.want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultBreakerCooldown is how long an open circuit rejects requests before
+// letting a trial request through.
+const DefaultBreakerCooldown = 30 * time.Second
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker tracks consecutive failures per backend. Once a backend
+// reaches the threshold its breaker opens and requests fail fast until the
+// cooldown has passed; then a single trial request decides whether the
+// breaker closes again or reopens for another cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	logger    *log.Logger
+	now       func() time.Time
+
+	mu       sync.Mutex
+	backends map[string]*breakerEntry
+}
+
+type breakerEntry struct {
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, logger *log.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		logger:    logger,
+		now:       time.Now,
+		backends:  make(map[string]*breakerEntry),
+	}
+}
+
+func (b *circuitBreaker) entry(backend *url.URL) *breakerEntry {
+	key := backend.String()
+	e, ok := b.backends[key]
+	if !ok {
+		e = &breakerEntry{}
+		b.backends[key] = e
+	}
+	return e
+}
+
+// allow reports whether a request may be sent to backend. In the half-open
+// state only one trial request is let through at a time.
+func (b *circuitBreaker) allow(backend *url.URL) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e := b.entry(backend)
+	switch e.state {
+	case breakerOpen:
+		if b.now().Sub(e.openedAt) < b.cooldown {
+			return false
+		}
+		e.state = breakerHalfOpen
+		e.trial = true
+		b.logger.Printf("Circuit breaker for %s is half-open, sending trial request", backend.Host)
+		return true
+	case breakerHalfOpen:
+		if e.trial {
+			return false
+		}
+		e.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record reports the outcome of a request previously admitted by allow.
+func (b *circuitBreaker) record(backend *url.URL, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e := b.entry(backend)
+	e.trial = false
+
+	if !failed {
+		if e.state != breakerClosed {
+			b.logger.Printf("Circuit breaker for %s is closed", backend.Host)
+		}
+		e.state = breakerClosed
+		e.failures = 0
+		return
+	}
+
+	e.failures++
+	if e.state == breakerHalfOpen || e.failures >= b.threshold {
+		if e.state != breakerOpen {
+			b.logger.Printf("Circuit breaker for %s is open after %d consecutive failures", backend.Host, e.failures)
+		}
+		e.state = breakerOpen
+		e.openedAt = b.now()
+	}
+}
+
+// release gives up an admitted request without judging the backend, for
+// example when the client went away before the backend answered.
+func (b *circuitBreaker) release(backend *url.URL) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entry(backend).trial = false
+}
+
+func (b *circuitBreaker) state(backend *url.URL) breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.entry(backend).state
+}
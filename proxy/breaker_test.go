@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	now := time.Now()
+	backend := mustParseURL("http://backend")
+	breaker := newCircuitBreaker(3, 10*time.Second, log.New(io.Discard, "", 0))
+	breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if !breaker.allow(backend) {
+			t.Fatalf("request %d should be allowed while closed", i+1)
+		}
+		breaker.record(backend, true)
+	}
+	if state := breaker.state(backend); state != breakerClosed {
+		t.Fatalf("expected closed below threshold, got %s", state)
+	}
+
+	breaker.record(backend, true)
+	if state := breaker.state(backend); state != breakerOpen {
+		t.Fatalf("expected open at threshold, got %s", state)
+	}
+	if breaker.allow(backend) {
+		t.Error("expected open breaker to reject requests during cooldown")
+	}
+
+	now = now.Add(10 * time.Second)
+	if !breaker.allow(backend) {
+		t.Fatal("expected a trial request after cooldown")
+	}
+	if state := breaker.state(backend); state != breakerHalfOpen {
+		t.Fatalf("expected half-open, got %s", state)
+	}
+	if breaker.allow(backend) {
+		t.Error("expected only one trial request in half-open")
+	}
+
+	// A failed trial reopens for another full cooldown
+	breaker.record(backend, true)
+	if state := breaker.state(backend); state != breakerOpen {
+		t.Fatalf("expected failed trial to reopen, got %s", state)
+	}
+	now = now.Add(5 * time.Second)
+	if breaker.allow(backend) {
+		t.Error("expected reopened breaker to reject requests")
+	}
+
+	now = now.Add(5 * time.Second)
+	if !breaker.allow(backend) {
+		t.Fatal("expected a second trial request")
+	}
+	breaker.record(backend, false)
+	if state := breaker.state(backend); state != breakerClosed {
+		t.Fatalf("expected successful trial to close, got %s", state)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	backend := mustParseURL("http://backend")
+	breaker := newCircuitBreaker(2, time.Minute, log.New(io.Discard, "", 0))
+
+	breaker.record(backend, true)
+	breaker.record(backend, false)
+	breaker.record(backend, true)
+	if state := breaker.state(backend); state != breakerClosed {
+		t.Errorf("expected non-consecutive failures to keep breaker closed, got %s", state)
+	}
+}
+
+func TestCircuitBreakerRelease(t *testing.T) {
+	now := time.Now()
+	backend := mustParseURL("http://backend")
+	breaker := newCircuitBreaker(1, time.Second, log.New(io.Discard, "", 0))
+	breaker.now = func() time.Time { return now }
+
+	breaker.record(backend, true)
+	now = now.Add(time.Second)
+	if !breaker.allow(backend) {
+		t.Fatal("expected a trial request")
+	}
+
+	breaker.release(backend)
+	if state := breaker.state(backend); state != breakerHalfOpen {
+		t.Errorf("expected release to keep breaker half-open, got %s", state)
+	}
+	if !breaker.allow(backend) {
+		t.Error("expected another trial after the first was released")
+	}
+}
+
+func TestServeHTTPCircuitBreaker(t *testing.T) {
+	var hits atomic.Int32
+	var failing atomic.Bool
+	failing.Store(true)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:       ":8080",
+		TargetURL:        mustParseURL(backend.URL),
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Hour,
+		BreakerCount5xx:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	serve := func() int {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := serve(); code != http.StatusInternalServerError {
+			t.Fatalf("expected backend 500 to be relayed, got %d", code)
+		}
+	}
+	if state := proxy.BreakerStates()[backend.URL]; state != "open" {
+		t.Fatalf("expected breaker to be open, got %q", state)
+	}
+
+	if code := serve(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected open breaker to fail fast with 503, got %d", code)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected backend not to be contacted while open, got %d hits", hits.Load())
+	}
+
+	// Move past the cooldown and let the trial request succeed
+	failing.Store(false)
+	proxy.breaker.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("expected trial request to succeed, got %d", code)
+	}
+	if state := proxy.BreakerStates()[backend.URL]; state != "closed" {
+		t.Errorf("expected breaker to close after a successful trial, got %q", state)
+	}
+}
+
+func TestServeHTTPCircuitBreakerIgnores5xxByDefault(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:       ":8080",
+		TargetURL:        mustParseURL(backend.URL),
+		BreakerThreshold: 1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500 to be relayed, got %d", w.Code)
+		}
+	}
+	if state := proxy.BreakerStates()[backend.URL]; state != "closed" {
+		t.Errorf("expected 5xx not to count without -breaker-count-5xx, got %q", state)
+	}
+}
+
+func TestServeHTTPCircuitBreakerConnectionErrors(t *testing.T) {
+	proxy, err := New(Config{
+		ListenAddr:       ":8080",
+		TargetURL:        mustParseURL("http://127.0.0.1:1"),
+		BreakerThreshold: 1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 for connection error, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected open breaker to return 503, got %d", w.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// errResponseTooLarge is returned when a buffered response is bigger than
+// Config.MaxResponseSize.
+var errResponseTooLarge = errors.New("response body exceeds the maximum response size")
+
+// bufferResponseBody reads the whole backend response into memory so it can
+// be relayed with an exact Content-Length instead of chunked framing. Event
+// streams are left alone, since they only end when the backend closes them.
+// A body larger than Config.MaxResponseSize fails with errResponseTooLarge
+// rather than being cut short under a length that claims it is complete.
+func (p *Proxy) bufferResponseBody(r *http.Request, resp *http.Response) error {
+	if r.Method == http.MethodHead || !bodyAllowedForStatus(resp.StatusCode) || isEventStream(resp) {
+		return nil
+	}
+
+	var src io.Reader = resp.Body
+	if p.config.MaxResponseSize > 0 {
+		src = io.LimitReader(resp.Body, p.config.MaxResponseSize+1)
+	}
+	body, err := io.ReadAll(src)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	if p.config.MaxResponseSize > 0 && int64(len(body)) > p.config.MaxResponseSize {
+		return errResponseTooLarge
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
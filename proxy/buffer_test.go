@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newChunkedBackend answers with body split over two flushes, which forces
+// chunked encoding on the backend response.
+func newChunkedBackend(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		half := len(body) / 2
+		io.WriteString(w, body[:half])
+		w.(http.Flusher).Flush()
+		io.WriteString(w, body[half:])
+	}))
+}
+
+func getThroughProxy(t *testing.T, config Config, acceptEncoding string) (*http.Response, string) {
+	t.Helper()
+
+	config.ListenAddr = ":0"
+	config.Logger = log.New(io.Discard, "", 0)
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+
+	req, _ := http.NewRequest("GET", server.URL+"/data", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	return resp, string(data)
+}
+
+func TestServeHTTPBufferResponse(t *testing.T) {
+	body := strings.Repeat("chunk of data ", 1000)
+	backend := newChunkedBackend(body)
+	defer backend.Close()
+
+	t.Run("streams chunked without buffering", func(t *testing.T) {
+		resp, got := getThroughProxy(t, Config{TargetURL: mustParseURL(backend.URL)}, "")
+		if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+			t.Errorf("expected chunked encoding, got %v (Content-Length %d)", resp.TransferEncoding, resp.ContentLength)
+		}
+		if got != body {
+			t.Error("body mismatch")
+		}
+	})
+
+	t.Run("buffered response has a fixed length", func(t *testing.T) {
+		resp, got := getThroughProxy(t, Config{TargetURL: mustParseURL(backend.URL), BufferResponse: true}, "")
+		if len(resp.TransferEncoding) != 0 {
+			t.Errorf("expected no transfer encoding, got %v", resp.TransferEncoding)
+		}
+		if resp.ContentLength != int64(len(body)) {
+			t.Errorf("expected Content-Length %d, got %d", len(body), resp.ContentLength)
+		}
+		if got != body {
+			t.Error("body mismatch")
+		}
+	})
+
+	t.Run("compressed buffered response has a fixed length", func(t *testing.T) {
+		resp, got := getThroughProxy(t, Config{TargetURL: mustParseURL(backend.URL), BufferResponse: true, Compress: true}, "gzip")
+		if resp.Header.Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected gzip encoding, got %q", resp.Header.Get("Content-Encoding"))
+		}
+		if len(resp.TransferEncoding) != 0 || resp.ContentLength != int64(len(got)) {
+			t.Errorf("expected Content-Length %d without transfer encoding, got %d %v", len(got), resp.ContentLength, resp.TransferEncoding)
+		}
+		gz, err := gzip.NewReader(strings.NewReader(got))
+		if err != nil {
+			t.Fatalf("invalid gzip body: %v", err)
+		}
+		if plain, _ := io.ReadAll(gz); string(plain) != body {
+			t.Error("decompressed body mismatch")
+		}
+	})
+
+	t.Run("response over the size cap is rejected", func(t *testing.T) {
+		resp, _ := getThroughProxy(t, Config{TargetURL: mustParseURL(backend.URL), BufferResponse: true, MaxResponseSize: 100}, "")
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("expected status 502, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("response within the size cap is relayed", func(t *testing.T) {
+		resp, got := getThroughProxy(t, Config{TargetURL: mustParseURL(backend.URL), BufferResponse: true, MaxResponseSize: int64(len(body))}, "")
+		if resp.StatusCode != http.StatusOK || resp.ContentLength != int64(len(body)) || got != body {
+			t.Errorf("expected full body with Content-Length %d, got status %d length %d", len(body), resp.StatusCode, resp.ContentLength)
+		}
+	})
+}
@@ -0,0 +1,324 @@
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long responses without max-age stay fresh.
+const DefaultCacheTTL = time.Minute
+
+// cachedResponse is a complete backend response held in memory.
+type cachedResponse struct {
+	key        string
+	statusCode int
+	header     http.Header
+	body       []byte
+	backend    *url.URL
+	vary       map[string]string
+	storedAt   time.Time
+	expires    time.Time
+	size       int64
+}
+
+// response rebuilds an *http.Response for the entry so it can be relayed
+// through the same path as a live backend response.
+func (c *cachedResponse) response(now time.Time) *http.Response {
+	header := c.header.Clone()
+	header.Set("Age", strconv.Itoa(int(now.Sub(c.storedAt).Seconds())))
+	return &http.Response{
+		StatusCode:    c.statusCode,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+	}
+}
+
+// notModifiedHeaders are the stored headers repeated on a 304 response, as
+// listed in RFC 9110 section 15.4.5.
+var notModifiedHeaders = []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Last-Modified", "Vary"}
+
+// notModifiedResponse answers a conditional request that matched the entry.
+func (c *cachedResponse) notModifiedResponse(now time.Time) *http.Response {
+	header := make(http.Header)
+	for _, name := range notModifiedHeaders {
+		if values := c.header.Values(name); len(values) > 0 {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+	header.Set("Age", strconv.Itoa(int(now.Sub(c.storedAt).Seconds())))
+	return &http.Response{StatusCode: http.StatusNotModified, Header: header, Body: http.NoBody}
+}
+
+// isNotModified evaluates the client's If-None-Match, or failing that its
+// If-Modified-Since, against a stored response. If-None-Match uses the weak
+// comparison required for GET and HEAD.
+func isNotModified(r *http.Request, stored http.Header) bool {
+	if ifNoneMatch := r.Header.Values("If-None-Match"); len(ifNoneMatch) > 0 {
+		etag := stored.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, value := range ifNoneMatch {
+			for _, candidate := range strings.Split(value, ",") {
+				candidate = strings.TrimSpace(candidate)
+				if candidate == "*" || weakETag(candidate) == weakETag(etag) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(stored.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(ifModifiedSince)
+}
+
+func weakETag(etag string) string {
+	return strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+}
+
+// responseCache is an in-memory LRU of backend responses bounded by the total
+// size of the stored headers and bodies.
+type responseCache struct {
+	maxBytes int64
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+}
+
+func newResponseCache(maxBytes int64, ttl time.Duration) *responseCache {
+	return &responseCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// cacheKey identifies a cacheable request. The host is part of the key since
+// host routing can send the same path to different backends.
+func cacheKey(r *http.Request) string {
+	return r.Method + " " + normalizeHost(r.Host) + " " + r.URL.RequestURI()
+}
+
+func (c *responseCache) get(r *http.Request) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[cacheKey(r)]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cachedResponse)
+	if !c.now().Before(entry.expires) {
+		c.removeElement(elem)
+		return nil, false
+	}
+
+	for name, value := range entry.vary {
+		if r.Header.Get(name) != value {
+			return nil, false
+		}
+	}
+
+	c.lru.MoveToFront(elem)
+	return entry, true
+}
+
+// set stores a response for r, evicting least recently used entries until
+// the cache fits within its size limit.
+func (c *responseCache) set(r *http.Request, resp *http.Response, body []byte, backend *url.URL) {
+	ttl, ok := c.freshness(resp.Header)
+	if !ok {
+		return
+	}
+
+	now := c.now()
+	entry := &cachedResponse{
+		key:        cacheKey(r),
+		statusCode: resp.StatusCode,
+		header:     resp.Header.Clone(),
+		body:       body,
+		backend:    backend,
+		storedAt:   now,
+		expires:    now.Add(ttl),
+		size:       int64(len(body)) + headerSize(resp.Header),
+	}
+
+	for _, name := range headerTokens(resp.Header, "Vary") {
+		if entry.vary == nil {
+			entry.vary = make(map[string]string)
+		}
+		entry.vary[name] = r.Header.Get(name)
+	}
+
+	if entry.size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.removeElement(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for c.size > c.maxBytes {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *responseCache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cachedResponse)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+func (c *responseCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// freshness returns how long a response may be served from cache, preferring
+// the shared-cache s-maxage over max-age and falling back to the configured
+// TTL when the backend gives neither.
+func (c *responseCache) freshness(header http.Header) (time.Duration, bool) {
+	directives := cacheControl(header)
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[name]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return c.ttl, c.ttl > 0
+}
+
+// isCacheableRequest reports whether a request may be answered from or
+// stored in the shared cache.
+func isCacheableRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	_, noStore := cacheControl(r.Header)["no-store"]
+	return !noStore
+}
+
+func isCacheableResponse(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMovedPermanently:
+	default:
+		return false
+	}
+
+	if resp.Header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	directives := cacheControl(resp.Header)
+	for _, name := range []string{"no-store", "private", "no-cache"} {
+		if _, ok := directives[name]; ok {
+			return false
+		}
+	}
+
+	for _, name := range headerTokens(resp.Header, "Vary") {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheControl parses Cache-Control directives into lower-cased names and
+// their (unquoted) arguments.
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				directives[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// headerTokens splits a comma-separated list header into canonical names.
+func headerTokens(header http.Header, name string) []string {
+	var tokens []string
+	for _, value := range header.Values(name) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, http.CanonicalHeaderKey(token))
+			}
+		}
+	}
+	return tokens
+}
+
+func headerSize(header http.Header) int64 {
+	var size int64
+	for name, values := range header {
+		for _, value := range values {
+			size += int64(len(name) + len(value))
+		}
+	}
+	return size
+}
+
+// cacheCapture records a response body as it is relayed to the client so it
+// can be stored afterwards. Bodies larger than limit are not kept.
+type cacheCapture struct {
+	io.ReadCloser
+	limit    int64
+	buf      bytes.Buffer
+	complete bool
+	overflow bool
+}
+
+func (c *cacheCapture) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	if !c.overflow {
+		if int64(c.buf.Len()+n) > c.limit {
+			c.overflow = true
+			c.buf = bytes.Buffer{}
+		} else {
+			c.buf.Write(b[:n])
+		}
+	}
+	if err == io.EOF {
+		c.complete = true
+	}
+	return n, err
+}
@@ -0,0 +1,355 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCacheTestProxy(t *testing.T, handler http.HandlerFunc, cacheSize int64) (*Proxy, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(backend.Close)
+
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		CacheSize:  cacheSize,
+		CacheTTL:   time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return proxy, &hits
+}
+
+func doCacheRequest(proxy *Proxy, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	return w
+}
+
+func TestServeHTTPCacheHitAndMiss(t *testing.T) {
+	proxy, hits := newCacheTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("content for " + r.URL.RequestURI()))
+	}, 1<<20)
+
+	first := doCacheRequest(proxy, "GET", "/static/app.js?v=1", nil)
+	if first.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected first request to MISS, got %q", first.Header().Get("X-Cache"))
+	}
+
+	second := doCacheRequest(proxy, "GET", "/static/app.js?v=1", nil)
+	if second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected second request to HIT, got %q", second.Header().Get("X-Cache"))
+	}
+	if second.Body.String() != "content for /static/app.js?v=1" || second.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("unexpected cached response %q (%s)", second.Body.String(), second.Header().Get("Content-Type"))
+	}
+	if second.Header().Get("Age") == "" {
+		t.Error("expected Age header on cached response")
+	}
+
+	// A different query string is a different entry
+	if w := doCacheRequest(proxy, "GET", "/static/app.js?v=2", nil); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected different query to MISS, got %q", w.Header().Get("X-Cache"))
+	}
+
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 backend requests, got %d", hits.Load())
+	}
+}
+
+func TestServeHTTPCacheBypass(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		requestHeader http.Header
+		cacheControl  string
+		setCookie     bool
+		status        int
+	}{
+		{name: "POST is never cached", method: "POST", status: http.StatusOK},
+		{name: "response no-store", method: "GET", cacheControl: "no-store", status: http.StatusOK},
+		{name: "response private", method: "GET", cacheControl: "private, max-age=60", status: http.StatusOK},
+		{name: "response max-age=0", method: "GET", cacheControl: "max-age=0", status: http.StatusOK},
+		{name: "response sets cookie", method: "GET", setCookie: true, status: http.StatusOK},
+		{name: "server error", method: "GET", status: http.StatusInternalServerError},
+		{name: "request no-store", method: "GET", requestHeader: http.Header{"Cache-Control": {"no-store"}}, status: http.StatusOK},
+		{name: "authorized request", method: "GET", requestHeader: http.Header{"Authorization": {"Bearer token"}}, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, hits := newCacheTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+				if tt.cacheControl != "" {
+					w.Header().Set("Cache-Control", tt.cacheControl)
+				}
+				if tt.setCookie {
+					w.Header().Set("Set-Cookie", "session=abc")
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte("body"))
+			}, 1<<20)
+
+			for i := 0; i < 2; i++ {
+				if w := doCacheRequest(proxy, tt.method, "/resource", tt.requestHeader); w.Header().Get("X-Cache") == "HIT" {
+					t.Error("expected response not to be served from cache")
+				}
+			}
+			if hits.Load() != 2 {
+				t.Errorf("expected every request to reach the backend, got %d", hits.Load())
+			}
+		})
+	}
+}
+
+func TestServeHTTPCacheRequestNoCacheRefreshes(t *testing.T) {
+	var version atomic.Int32
+	proxy, _ := newCacheTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "v%d", version.Add(1))
+	}, 1<<20)
+
+	doCacheRequest(proxy, "GET", "/page", nil)
+	refreshed := doCacheRequest(proxy, "GET", "/page", http.Header{"Cache-Control": {"no-cache"}})
+	if refreshed.Body.String() != "v2" {
+		t.Errorf("expected no-cache request to reach the backend, got %q", refreshed.Body.String())
+	}
+	if cached := doCacheRequest(proxy, "GET", "/page", nil); cached.Body.String() != "v2" || cached.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected refreshed response to be cached, got %q (%s)", cached.Body.String(), cached.Header().Get("X-Cache"))
+	}
+}
+
+func TestServeHTTPCacheVary(t *testing.T) {
+	proxy, hits := newCacheTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte(r.Header.Get("Accept-Language")))
+	}, 1<<20)
+
+	en := http.Header{"Accept-Language": {"en"}}
+	doCacheRequest(proxy, "GET", "/greeting", en)
+	if w := doCacheRequest(proxy, "GET", "/greeting", en); w.Header().Get("X-Cache") != "HIT" {
+		t.Error("expected matching Vary header to HIT")
+	}
+	if w := doCacheRequest(proxy, "GET", "/greeting", http.Header{"Accept-Language": {"fr"}}); w.Body.String() != "fr" {
+		t.Errorf("expected a different Vary value to reach the backend, got %q", w.Body.String())
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 backend requests, got %d", hits.Load())
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	now := time.Now()
+	cache := newResponseCache(1<<20, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	req := httptest.NewRequest("GET", "/a", nil)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Cache-Control": {"public, max-age=10"}}}
+	cache.set(req, resp, []byte("a"), nil)
+
+	if _, ok := cache.get(req); !ok {
+		t.Fatal("expected fresh entry to be served")
+	}
+
+	now = now.Add(10 * time.Second)
+	if _, ok := cache.get(req); ok {
+		t.Error("expected entry to expire after max-age")
+	}
+	if cache.len() != 0 {
+		t.Errorf("expected expired entry to be removed, got %d entries", cache.len())
+	}
+}
+
+func TestResponseCacheFreshness(t *testing.T) {
+	cache := newResponseCache(1<<20, time.Minute)
+
+	tests := []struct {
+		cacheControl string
+		expected     time.Duration
+		ok           bool
+	}{
+		{"", time.Minute, true},
+		{"max-age=30", 30 * time.Second, true},
+		{"max-age=30, s-maxage=5", 5 * time.Second, true},
+		{`max-age="120"`, 2 * time.Minute, true},
+		{"max-age=0", 0, false},
+		{"max-age=abc", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			header := http.Header{}
+			if tt.cacheControl != "" {
+				header.Set("Cache-Control", tt.cacheControl)
+			}
+			ttl, ok := cache.freshness(header)
+			if ok != tt.ok || ttl != tt.expected {
+				t.Errorf("expected (%v, %v), got (%v, %v)", tt.expected, tt.ok, ttl, ok)
+			}
+		})
+	}
+}
+
+func TestResponseCacheLRUEviction(t *testing.T) {
+	body := []byte(strings.Repeat("x", 100))
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+
+	// Room for two entries but not three
+	cache := newResponseCache(250, time.Minute)
+	reqA := httptest.NewRequest("GET", "/a", nil)
+	reqB := httptest.NewRequest("GET", "/b", nil)
+	reqC := httptest.NewRequest("GET", "/c", nil)
+
+	cache.set(reqA, resp, body, nil)
+	cache.set(reqB, resp, body, nil)
+
+	// Touch "a" so "b" becomes the least recently used
+	if _, ok := cache.get(reqA); !ok {
+		t.Fatal("expected /a to be cached")
+	}
+	cache.set(reqC, resp, body, nil)
+
+	if _, ok := cache.get(reqB); ok {
+		t.Error("expected least recently used /b to be evicted")
+	}
+	if _, ok := cache.get(reqA); !ok {
+		t.Error("expected recently used /a to stay cached")
+	}
+	if _, ok := cache.get(reqC); !ok {
+		t.Error("expected newest /c to be cached")
+	}
+
+	// Entries larger than the whole cache are never stored
+	cache.set(httptest.NewRequest("GET", "/huge", nil), resp, make([]byte, 300), nil)
+	if cache.len() != 2 {
+		t.Errorf("expected oversized entry to be skipped, got %d entries", cache.len())
+	}
+}
+
+func TestServeHTTPCacheSkipsOversizedBody(t *testing.T) {
+	proxy, hits := newCacheTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 4096)))
+	}, 1024)
+
+	for i := 0; i < 2; i++ {
+		if w := doCacheRequest(proxy, "GET", "/big", nil); w.Body.Len() != 4096 {
+			t.Fatalf("expected full body to be relayed, got %d bytes", w.Body.Len())
+		}
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected oversized response not to be cached, got %d backend requests", hits.Load())
+	}
+}
+
+func TestServeHTTPCacheConditionalRequests(t *testing.T) {
+	lastModified := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	proxy, hits := newCacheTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		// Backend ignores conditionals so any 304 must come from the cache
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("body"))
+	}, 1<<20)
+
+	if w := doCacheRequest(proxy, "GET", "/doc", http.Header{"If-None-Match": {`"v1"`}}); w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("expected first request to reach the backend, got %d (%s)", w.Code, w.Header().Get("X-Cache"))
+	}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected int
+	}{
+		{"matching ETag", http.Header{"If-None-Match": {`"v1"`}}, http.StatusNotModified},
+		{"weak ETag match", http.Header{"If-None-Match": {`W/"v1"`}}, http.StatusNotModified},
+		{"ETag in list", http.Header{"If-None-Match": {`"v0", "v1"`}}, http.StatusNotModified},
+		{"wildcard", http.Header{"If-None-Match": {"*"}}, http.StatusNotModified},
+		{"different ETag", http.Header{"If-None-Match": {`"v2"`}}, http.StatusOK},
+		{"not modified since", http.Header{"If-Modified-Since": {lastModified.Format(http.TimeFormat)}}, http.StatusNotModified},
+		{"modified since", http.Header{"If-Modified-Since": {lastModified.Add(-time.Hour).Format(http.TimeFormat)}}, http.StatusOK},
+		{"If-None-Match wins over If-Modified-Since", http.Header{
+			"If-None-Match":     {`"v2"`},
+			"If-Modified-Since": {lastModified.Format(http.TimeFormat)},
+		}, http.StatusOK},
+		{"unconditional", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doCacheRequest(proxy, "GET", "/doc", tt.header)
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d", tt.expected, w.Code)
+			}
+			if w.Header().Get("X-Cache") != "HIT" {
+				t.Errorf("expected X-Cache HIT, got %q", w.Header().Get("X-Cache"))
+			}
+			if tt.expected == http.StatusNotModified {
+				if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+					t.Errorf("expected 304 without body or content headers, got %q (%s)", w.Body.String(), w.Header().Get("Content-Type"))
+				}
+				if w.Header().Get("ETag") != `"v1"` {
+					t.Errorf("expected ETag on 304, got %q", w.Header().Get("ETag"))
+				}
+			}
+		})
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("expected only the first request to reach the backend, got %d", hits.Load())
+	}
+}
+
+func TestServeHTTPCacheDoesNotStoreNotModified(t *testing.T) {
+	proxy, hits := newCacheTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("body"))
+	}, 1<<20)
+
+	// A 304 from the backend is relayed but cannot be cached
+	if w := doCacheRequest(proxy, "GET", "/doc", http.Header{"If-None-Match": {`"v1"`}}); w.Code != http.StatusNotModified {
+		t.Fatalf("expected backend 304 to be relayed, got %d", w.Code)
+	}
+	if w := doCacheRequest(proxy, "GET", "/doc", nil); w.Code != http.StatusOK || w.Body.String() != "body" {
+		t.Errorf("expected full response for unconditional request, got %d %q", w.Code, w.Body.String())
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected both requests to reach the backend, got %d", hits.Load())
+	}
+}
+
+func TestServeHTTPCacheConditionalIgnoresNonCacheable(t *testing.T) {
+	proxy, hits := newCacheTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte("body"))
+	}, 1<<20)
+
+	doCacheRequest(proxy, "GET", "/doc", nil)
+	w := doCacheRequest(proxy, "GET", "/doc", http.Header{"If-None-Match": {`"v1"`}})
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected non-cacheable response to reach the backend, got %d (%s)", w.Code, w.Header().Get("X-Cache"))
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 backend requests, got %d", hits.Load())
+	}
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// faultInjector deliberately degrades requests so clients can be tested
+// against a slow or failing backend. It is meant for test environments only.
+type faultInjector struct {
+	latency     time.Duration
+	jitter      time.Duration
+	errorRate   float64
+	errorStatus int
+	random      func() float64
+}
+
+func newFaultInjector(config Config) *faultInjector {
+	f := &faultInjector{
+		latency:     config.InjectLatency,
+		jitter:      config.InjectLatencyJitter,
+		errorRate:   config.InjectErrorRate,
+		errorStatus: config.InjectErrorStatus,
+		random:      rand.Float64,
+	}
+	if f.errorStatus == 0 {
+		f.errorStatus = http.StatusServiceUnavailable
+	}
+
+	// A fixed seed makes the sequence of faults reproducible; the source
+	// itself is not safe for concurrent use
+	if config.InjectSeed != 0 {
+		var mu sync.Mutex
+		rng := rand.New(rand.NewPCG(config.InjectSeed, config.InjectSeed))
+		f.random = func() float64 {
+			mu.Lock()
+			defer mu.Unlock()
+			return rng.Float64()
+		}
+	}
+	return f
+}
+
+// delay returns the latency to add to one request: the configured latency
+// moved by a uniformly random amount within plus or minus the jitter, and
+// never negative.
+func (f *faultInjector) delay() time.Duration {
+	d := f.latency
+	if f.jitter > 0 {
+		d += time.Duration((f.random()*2 - 1) * float64(f.jitter))
+	}
+	return max(d, 0)
+}
+
+// sleep waits out the injected latency, returning early with the context's
+// error if the client goes away first.
+func (f *faultInjector) sleep(ctx context.Context) error {
+	d := f.delay()
+	if d == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fail reports whether this request should get an injected error.
+func (f *faultInjector) fail() bool {
+	return f.errorRate > 0 && f.random() < f.errorRate
+}
@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeHTTPInjectLatency(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	var logBuf bytes.Buffer
+	proxy, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		InjectLatency: 100 * time.Millisecond,
+		Logger:        log.New(&logBuf, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(logBuf.String(), "not for production") {
+		t.Errorf("expected a startup warning, got %q", logBuf.String())
+	}
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if elapsed < 100*time.Millisecond {
+		t.Errorf("expected at least 100ms of added latency, took %v", elapsed)
+	}
+}
+
+func TestServeHTTPInjectLatencyHonorsCancellation(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("backend should not be called after the client gave up")
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		InjectLatency: time.Minute,
+		Logger:        log.New(io.Discard, "", 0),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8080/", nil).WithContext(ctx))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the injected delay to stop when the client cancels, took %v", elapsed)
+	}
+}
+
+func TestFaultInjectorDelayJitter(t *testing.T) {
+	f := newFaultInjector(Config{InjectLatency: 100 * time.Millisecond, InjectLatencyJitter: 40 * time.Millisecond})
+
+	tests := []struct {
+		random   float64
+		expected time.Duration
+	}{
+		{0, 60 * time.Millisecond},
+		{0.5, 100 * time.Millisecond},
+		{1, 140 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		f.random = func() float64 { return tt.random }
+		if got := f.delay(); got != tt.expected {
+			t.Errorf("random %v: expected delay %v, got %v", tt.random, tt.expected, got)
+		}
+	}
+
+	// Jitter larger than the latency never produces a negative delay
+	f = newFaultInjector(Config{InjectLatency: 10 * time.Millisecond, InjectLatencyJitter: 50 * time.Millisecond})
+	f.random = func() float64 { return 0 }
+	if got := f.delay(); got != 0 {
+		t.Errorf("expected delay clamped to 0, got %v", got)
+	}
+}
+
+func TestNewRejectsNegativeInjectedLatency(t *testing.T) {
+	_, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL("http://localhost:3000"),
+		InjectLatency: -time.Second,
+	})
+	if err == nil {
+		t.Error("expected error for negative injected latency")
+	}
+}
+
+func TestServeHTTPInjectErrorRate(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:        ":8080",
+		TargetURL:         mustParseURL(backend.URL),
+		InjectErrorRate:   0.3,
+		InjectErrorStatus: http.StatusInternalServerError,
+		InjectSeed:        42,
+		Logger:            log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const requests = 2000
+	failures := 0
+	for i := 0; i < requests; i++ {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+		switch w.Code {
+		case http.StatusInternalServerError:
+			failures++
+		case http.StatusOK:
+		default:
+			t.Fatalf("unexpected status %d", w.Code)
+		}
+	}
+
+	// The standard deviation at this sample size is about 1%
+	rate := float64(failures) / requests
+	if rate < 0.25 || rate > 0.35 {
+		t.Errorf("expected an error rate near 0.3, observed %.3f", rate)
+	}
+}
+
+func TestFaultInjectorSeedIsReproducible(t *testing.T) {
+	sequence := func(seed uint64) []bool {
+		f := newFaultInjector(Config{InjectErrorRate: 0.5, InjectSeed: seed})
+		result := make([]bool, 50)
+		for i := range result {
+			result[i] = f.fail()
+		}
+		return result
+	}
+
+	first, second, other := sequence(7), sequence(7), sequence(8)
+	if !slices.Equal(first, second) {
+		t.Error("expected the same seed to produce the same faults")
+	}
+	if slices.Equal(first, other) {
+		t.Error("expected different seeds to produce different faults")
+	}
+}
+
+func TestFaultInjectorDefaultStatus(t *testing.T) {
+	if f := newFaultInjector(Config{InjectErrorRate: 1}); f.errorStatus != http.StatusServiceUnavailable || !f.fail() {
+		t.Errorf("expected every request to fail with 503, got status %d", f.errorStatus)
+	}
+	if f := newFaultInjector(Config{InjectLatency: time.Second}); f.fail() {
+		t.Error("expected no injected errors without an error rate")
+	}
+}
+
+func TestNewValidatesInjectedErrors(t *testing.T) {
+	for _, config := range []Config{
+		{InjectErrorRate: 1.5},
+		{InjectErrorRate: -0.1},
+		{InjectErrorRate: 0.5, InjectErrorStatus: 42},
+	} {
+		config.ListenAddr = ":8080"
+		config.TargetURL = mustParseURL("http://localhost:3000")
+		if _, err := New(config); err == nil {
+			t.Errorf("expected error for rate %v and status %d", config.InjectErrorRate, config.InjectErrorStatus)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// CheckBackends sends a single GET to every configured backend, at
+// Config.HealthPath when set, and reports the ones that could not be
+// reached. Any HTTP response counts: the aim is to catch a wrong address,
+// port or TLS setup before serving, not to judge the backend's health.
+func (p *Proxy) CheckBackends(ctx context.Context) error {
+	var errs []error
+	for _, backend := range p.backendURLs() {
+		if err := p.checkBackend(ctx, backend); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", backend.Redacted(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Proxy) checkBackend(ctx context.Context, backend *url.URL) error {
+	timeout := p.live.Load().timeout
+	if timeout <= 0 {
+		timeout = healthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	checkURL := *backend
+	if p.config.HealthPath != "" {
+		checkURL.Path, checkURL.RawPath, checkURL.RawQuery = p.config.HealthPath, "", ""
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	p.logger.Printf("Backend check: %s answered %d", StripUserinfo(&checkURL), resp.StatusCode)
+	return nil
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckBackends(t *testing.T) {
+	var gotPath string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	unreachable := "http://" + listener.Addr().String()
+	listener.Close()
+
+	t.Run("any response counts as reachable", func(t *testing.T) {
+		p, _ := New(Config{
+			ListenAddr: ":8080",
+			TargetURL:  mustParseURL(backend.URL + "/api"),
+			HealthPath: "/ready",
+			Logger:     log.New(io.Discard, "", 0),
+		})
+		if err := p.CheckBackends(context.Background()); err != nil {
+			t.Fatalf("expected backend to be reachable, got %v", err)
+		}
+		if gotPath != "/ready" {
+			t.Errorf("expected check on /ready, got %q", gotPath)
+		}
+	})
+
+	t.Run("unreachable backend is reported", func(t *testing.T) {
+		p, _ := New(Config{
+			ListenAddr: ":8080",
+			Backends:   []Backend{{URL: mustParseURL(backend.URL), Weight: 1}, {URL: mustParseURL(unreachable), Weight: 1}},
+			Logger:     log.New(io.Discard, "", 0),
+		})
+		err := p.CheckBackends(context.Background())
+		if err == nil {
+			t.Fatal("expected an error for the unreachable backend")
+		}
+		if !strings.Contains(err.Error(), unreachable) || strings.Contains(err.Error(), backend.URL) {
+			t.Errorf("expected only %s in the error, got %v", unreachable, err)
+		}
+	})
+}
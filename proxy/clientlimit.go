@@ -0,0 +1,41 @@
+package proxy
+
+import "sync"
+
+// clientLimiter caps the requests each client IP may have in flight at
+// once. Counts are kept only for clients with requests in flight, so the
+// map shrinks back as clients go quiet.
+type clientLimiter struct {
+	limit int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+func newClientLimiter(limit int) *clientLimiter {
+	return &clientLimiter{limit: limit, active: make(map[string]int)}
+}
+
+// acquire counts a request from ip, reporting false if the client is already
+// at the limit. Every successful acquire must be paired with release.
+func (l *clientLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active[ip] >= l.limit {
+		return false
+	}
+	l.active[ip]++
+	return true
+}
+
+func (l *clientLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active[ip] <= 1 {
+		delete(l.active, ip)
+		return
+	}
+	l.active[ip]--
+}
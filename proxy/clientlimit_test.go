@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientLimiter(t *testing.T) {
+	limiter := newClientLimiter(2)
+
+	if !limiter.acquire("10.0.0.1") || !limiter.acquire("10.0.0.1") {
+		t.Fatal("expected two requests to be allowed")
+	}
+	if limiter.acquire("10.0.0.1") {
+		t.Error("expected a third concurrent request to be rejected")
+	}
+	if !limiter.acquire("10.0.0.2") {
+		t.Error("expected another client to be unaffected")
+	}
+
+	limiter.release("10.0.0.1")
+	if !limiter.acquire("10.0.0.1") {
+		t.Error("expected a released slot to be reusable")
+	}
+
+	limiter.release("10.0.0.1")
+	limiter.release("10.0.0.1")
+	limiter.release("10.0.0.2")
+	if len(limiter.active) != 0 {
+		t.Errorf("expected idle clients to be forgotten, got %v", limiter.active)
+	}
+}
+
+func TestServeHTTPMaxConnsPerIP(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		MaxConnsPerIP: 2,
+		Logger:        log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	request := func(remoteAddr, path string) int {
+		req := httptest.NewRequest("GET", "http://localhost:8080"+path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Saturate one client with requests the backend holds open
+	done := make(chan int, 2)
+	for range 2 {
+		go func() { done <- request("203.0.113.1:1234", "/slow") }()
+	}
+	<-started
+	<-started
+
+	if code := request("203.0.113.1:5678", "/"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for the saturated client, got %d", code)
+	}
+	if code := request("203.0.113.2:1234", "/"); code != http.StatusOK {
+		t.Errorf("expected another client to proceed, got %d", code)
+	}
+
+	close(release)
+	for range 2 {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("expected the held requests to complete, got %d", code)
+		}
+	}
+
+	if code := request("203.0.113.1:5678", "/"); code != http.StatusOK {
+		t.Errorf("expected the client to be admitted again once its requests finished, got %d", code)
+	}
+	if len(proxy.perClient.active) != 0 {
+		t.Errorf("expected no tracked clients after all requests finished, got %v", proxy.perClient.active)
+	}
+}
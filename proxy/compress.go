@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressedContentTypes lists media types that are already compressed and
+// gain nothing from another round of gzip.
+var compressedContentTypes = map[string]bool{
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/zip":              true,
+	"application/x-7z-compressed":  true,
+	"application/x-bzip2":          true,
+	"application/x-rar-compressed": true,
+	"application/zstd":             true,
+	"application/pdf":              true,
+}
+
+func shouldCompress(r *http.Request, resp *http.Response) bool {
+	if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		return false
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+
+	if resp.Header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	return !isCompressedContentType(resp.Header.Get("Content-Type"))
+}
+
+func acceptsGzip(acceptEncoding string) bool {
+	return acceptsEncoding(acceptEncoding, "gzip")
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header admits the
+// content coding name, either explicitly or through "*".
+func acceptsEncoding(acceptEncoding, name string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != name && coding != "*" {
+			continue
+		}
+
+		// "gzip;q=0" explicitly refuses the encoding
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isCompressedContentType(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	if strings.HasPrefix(mediaType, "image/") && mediaType != "image/svg+xml" {
+		return true
+	}
+	if strings.HasPrefix(mediaType, "video/") || strings.HasPrefix(mediaType, "audio/") {
+		return true
+	}
+	return compressedContentTypes[mediaType]
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.8", true},
+		{"GZIP", true},
+		{"br, deflate", false},
+		{"gzip;q=0", false},
+		{"*", true},
+	}
+
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.expected {
+			t.Errorf("acceptsGzip(%q) = %v, expected %v", tt.header, got, tt.expected)
+		}
+	}
+}
+
+func TestIsCompressedContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    bool
+	}{
+		{"application/json", false},
+		{"text/html; charset=utf-8", false},
+		{"image/png", true},
+		{"image/svg+xml", false},
+		{"video/mp4", true},
+		{"application/zip", true},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isCompressedContentType(tt.contentType); got != tt.expected {
+			t.Errorf("isCompressedContentType(%q) = %v, expected %v", tt.contentType, got, tt.expected)
+		}
+	}
+}
+
+func TestServeHTTPCompression(t *testing.T) {
+	payload := strings.Repeat(`{"key":"value"}`, 100)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+		case "/encoded":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "br")
+		default:
+			w.Header().Set("Content-Type", "application/json")
+		}
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name           string
+		compress       bool
+		path           string
+		acceptEncoding string
+		expectGzip     bool
+	}{
+		{"compresses json", true, "/json", "gzip, deflate", true},
+		{"client without gzip support", true, "/json", "", false},
+		{"already encoded response", true, "/encoded", "gzip", false},
+		{"already compressed content type", true, "/image", "gzip", false},
+		{"compression disabled", false, "/json", "gzip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL(backend.URL),
+				Compress:   tt.compress,
+			}
+			proxy, _ := New(config)
+
+			req := httptest.NewRequest("GET", "http://localhost:8080"+tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+
+			proxy.ServeHTTP(w, req)
+
+			resp := w.Result()
+			gotGzip := resp.Header.Get("Content-Encoding") == "gzip"
+			if gotGzip != tt.expectGzip {
+				t.Fatalf("expected gzip=%v, got Content-Encoding %q", tt.expectGzip, resp.Header.Get("Content-Encoding"))
+			}
+
+			var reader io.Reader = resp.Body
+			if gotGzip {
+				if resp.Header.Get("Content-Length") != "" {
+					t.Error("expected Content-Length to be removed from compressed response")
+				}
+				gz, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("failed to create gzip reader: %v", err)
+				}
+				reader = gz
+			}
+
+			body, _ := io.ReadAll(reader)
+			if string(body) != payload {
+				t.Errorf("unexpected body length %d, expected %d", len(body), len(payload))
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Policies for requests arriving at the concurrency limit, and how long a
+// queued request waits by default.
+const (
+	ConcurrencyPolicyReject = "reject"
+	ConcurrencyPolicyQueue  = "queue"
+
+	DefaultQueueTimeout = 5 * time.Second
+)
+
+// concurrencyLimiter bounds the number of requests proxied at once with a
+// buffered channel used as a semaphore. When it is full, requests are either
+// rejected immediately or wait up to a timeout for a slot, depending on the
+// policy.
+type concurrencyLimiter struct {
+	slots   chan struct{}
+	queue   bool
+	timeout time.Duration
+}
+
+func newConcurrencyLimiter(limit int, policy string, timeout time.Duration) (*concurrencyLimiter, error) {
+	if policy != ConcurrencyPolicyReject && policy != ConcurrencyPolicyQueue {
+		return nil, fmt.Errorf("invalid concurrency policy %q (must be %q or %q)", policy, ConcurrencyPolicyQueue, ConcurrencyPolicyReject)
+	}
+	return &concurrencyLimiter{
+		slots:   make(chan struct{}, limit),
+		queue:   policy == ConcurrencyPolicyQueue,
+		timeout: timeout,
+	}, nil
+}
+
+// acquire takes a slot, reporting false if none became available. Every
+// successful acquire must be paired with release.
+func (l *concurrencyLimiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if !l.queue {
+		return false
+	}
+
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+}
+
+func (l *concurrencyLimiter) inFlight() int {
+	return len(l.slots)
+}
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter, err := newConcurrencyLimiter(2, ConcurrencyPolicyReject, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	if !limiter.acquire(ctx) || !limiter.acquire(ctx) {
+		t.Fatal("expected two slots to be available")
+	}
+	if limiter.acquire(ctx) {
+		t.Error("expected third acquire to be rejected")
+	}
+	if limiter.inFlight() != 2 {
+		t.Errorf("expected 2 in flight, got %d", limiter.inFlight())
+	}
+
+	limiter.release()
+	if !limiter.acquire(ctx) {
+		t.Error("expected a released slot to be reusable")
+	}
+}
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	limiter, _ := newConcurrencyLimiter(1, ConcurrencyPolicyQueue, 50*time.Millisecond)
+	ctx := context.Background()
+	limiter.acquire(ctx)
+
+	start := time.Now()
+	if limiter.acquire(ctx) {
+		t.Fatal("expected queued acquire to time out")
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("expected to wait for the queue timeout, waited %v", waited)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		limiter.release()
+	}()
+	if !limiter.acquire(ctx) {
+		t.Error("expected queued acquire to get the released slot")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if limiter.acquire(cancelled) {
+		t.Error("expected acquire to give up when the request is cancelled")
+	}
+}
+
+func TestNewConcurrencyLimiterInvalidPolicy(t *testing.T) {
+	if _, err := newConcurrencyLimiter(1, "drop", time.Second); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestServeHTTPMaxConcurrent(t *testing.T) {
+	for _, policy := range []string{ConcurrencyPolicyReject, ConcurrencyPolicyQueue} {
+		t.Run(policy, func(t *testing.T) {
+			release := make(chan struct{})
+			started := make(chan struct{}, 2)
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				<-release
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer backend.Close()
+
+			proxy, err := New(Config{
+				ListenAddr:        ":8080",
+				TargetURL:         mustParseURL(backend.URL),
+				MaxConcurrent:     2,
+				ConcurrencyPolicy: policy,
+				QueueTimeout:      50 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// Saturate the limit with two requests held open by the backend
+			var wg sync.WaitGroup
+			codes := make([]int, 2)
+			for i := range codes {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					w := httptest.NewRecorder()
+					proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+					codes[i] = w.Code
+				}(i)
+			}
+			<-started
+			<-started
+
+			if proxy.InFlight() != 2 {
+				t.Errorf("expected 2 requests in flight, got %d", proxy.InFlight())
+			}
+
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("expected 503 at the concurrency limit, got %d", w.Code)
+			}
+
+			close(release)
+			wg.Wait()
+			for i, code := range codes {
+				if code != http.StatusOK {
+					t.Errorf("request %d: expected 200, got %d", i, code)
+				}
+			}
+			if proxy.InFlight() != 0 {
+				t.Errorf("expected no requests in flight, got %d", proxy.InFlight())
+			}
+		})
+	}
+}
+
+func TestServeHTTPQueuedRequestProceeds(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:        ":8080",
+		TargetURL:         mustParseURL(backend.URL),
+		MaxConcurrent:     1,
+		ConcurrencyPolicy: ConcurrencyPolicyQueue,
+		QueueTimeout:      5 * time.Second,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-started
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected queued request to proceed once a slot frees up, got %d", w.Code)
+	}
+	<-done
+}
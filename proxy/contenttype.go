@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// normalizeContentTypes lower-cases the allowlist and checks that each entry
+// is a media type ("application/json") or a wildcard subtype ("text/*").
+func normalizeContentTypes(types []string) ([]string, error) {
+	normalized := make([]string, 0, len(types))
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		major, minor, ok := strings.Cut(t, "/")
+		if !ok || major == "" || minor == "" || major == "*" {
+			return nil, fmt.Errorf("invalid content type %q (expected type/subtype)", t)
+		}
+		normalized = append(normalized, t)
+	}
+	return normalized, nil
+}
+
+// contentTypeAllowed reports whether r's body may be forwarded under
+// Config.AllowedContentTypes. Parameters such as charset are ignored.
+// Requests without a body always pass; a body without a Content-Type passes
+// unless Config.StrictContentType is set.
+func (p *Proxy) contentTypeAllowed(r *http.Request) bool {
+	if len(p.config.AllowedContentTypes) == 0 || r.ContentLength == 0 {
+		return true
+	}
+
+	value := r.Header.Get("Content-Type")
+	if value == "" {
+		return !p.config.StrictContentType
+	}
+
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return false
+	}
+
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, allowed := range p.config.AllowedContentTypes {
+		if allowed == mediaType || allowed == major+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Proxy) rejectContentType(w http.ResponseWriter, r *http.Request) {
+	p.logger.Printf("Content type %q not allowed for %s %s", r.Header.Get("Content-Type"), r.Method, r.URL.Path)
+	p.writeError(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestServeHTTPAllowedContentTypes(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name         string
+		strict       bool
+		method       string
+		contentType  string
+		body         string
+		expectStatus int
+	}{
+		{"allowed type", false, "POST", "application/json", `{}`, http.StatusOK},
+		{"parameters are ignored", false, "POST", "application/json; charset=utf-8", `{}`, http.StatusOK},
+		{"type is case-insensitive", false, "POST", "Application/JSON", `{}`, http.StatusOK},
+		{"wildcard subtype", false, "POST", "text/csv", "a,b", http.StatusOK},
+		{"disallowed type", false, "POST", "application/xml", "<a/>", http.StatusUnsupportedMediaType},
+		{"malformed type", false, "POST", "json", "{}", http.StatusUnsupportedMediaType},
+		{"missing type", false, "POST", "", "data", http.StatusOK},
+		{"missing type in strict mode", true, "POST", "", "data", http.StatusUnsupportedMediaType},
+		{"no body", false, "GET", "", "", http.StatusOK},
+		{"no body in strict mode", true, "GET", "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, err := New(Config{
+				ListenAddr:          ":8080",
+				TargetURL:           mustParseURL(backend.URL),
+				AllowedContentTypes: []string{"application/json", "Text/*"},
+				StrictContentType:   tt.strict,
+				Logger:              log.New(io.Discard, "", 0),
+			})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			calls.Store(0)
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, "http://localhost:8080/items", body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != tt.expectStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectStatus, w.Code)
+			}
+			expectCalls := int32(1)
+			if tt.expectStatus == http.StatusUnsupportedMediaType {
+				expectCalls = 0
+			}
+			if calls.Load() != expectCalls {
+				t.Errorf("expected %d backend calls, got %d", expectCalls, calls.Load())
+			}
+		})
+	}
+}
+
+func TestNewInvalidContentType(t *testing.T) {
+	for _, contentType := range []string{"json", "*/*", "application/", ""} {
+		_, err := New(Config{
+			ListenAddr:          ":8080",
+			TargetURL:           mustParseURL("http://localhost:3000"),
+			AllowedContentTypes: []string{contentType},
+		})
+		if err == nil {
+			t.Errorf("expected error for content type %q", contentType)
+		}
+	}
+}
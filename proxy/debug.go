@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net/http"
+	"slices"
+)
+
+// debugRequest logs the request line and every header sent to the backend,
+// curl -v style, when Config.Debug is set.
+func (p *Proxy) debugRequest(r *http.Request, proxyReq *http.Request) {
+	if !p.config.Debug {
+		return
+	}
+	requestID := r.Header.Get(p.config.RequestIDHeader)
+	p.logger.Printf("DEBUG request_id=%s > %s %s", requestID, proxyReq.Method, proxyReq.URL.String())
+	host := proxyReq.Host
+	if host == "" {
+		host = proxyReq.URL.Host
+	}
+	p.logger.Printf("DEBUG request_id=%s > Host: %s", requestID, host)
+	p.debugHeaders(requestID, ">", proxyReq.Header)
+}
+
+// debugResponse logs the status and every header received from the backend
+// when Config.Debug is set.
+func (p *Proxy) debugResponse(r *http.Request, resp *http.Response) {
+	if !p.config.Debug {
+		return
+	}
+	requestID := r.Header.Get(p.config.RequestIDHeader)
+	p.logger.Printf("DEBUG request_id=%s < %s", requestID, resp.Status)
+	p.debugHeaders(requestID, "<", resp.Header)
+}
+
+// debugHeaders logs header in name order, redacting credentials such as
+// Authorization and Cookie unless Config.DebugShowSecrets is set.
+func (p *Proxy) debugHeaders(requestID, direction string, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			if !p.config.DebugShowSecrets && isSensitiveHeader(name) {
+				value = redacted
+			}
+			p.logger.Printf("DEBUG request_id=%s %s %s: %s", requestID, direction, name, value)
+		}
+	}
+}
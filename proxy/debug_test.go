@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPDebugLogsHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=backend-secret")
+		w.Header().Set("X-Backend", "one")
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name        string
+		showSecrets bool
+	}{
+		{"redacted by default", false},
+		{"show secrets", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			proxy, err := New(Config{
+				ListenAddr:       ":8080",
+				TargetURL:        mustParseURL(backend.URL),
+				Debug:            true,
+				DebugShowSecrets: tt.showSecrets,
+				Logger:           log.New(&logs, "", 0),
+			})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/items", nil)
+			req.Header.Set("Authorization", "Bearer client-secret")
+			req.Header.Set("Cookie", "session=client-secret")
+			req.Header.Set("X-Client", "curl")
+			proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+			output := logs.String()
+			for _, line := range []string{"> X-Client: curl", "< 200 OK", "< X-Backend: one"} {
+				if !strings.Contains(output, line) {
+					t.Errorf("expected debug log to contain %q, got:\n%s", line, output)
+				}
+			}
+
+			secrets := []string{"> Authorization: Bearer client-secret", "> Cookie: session=client-secret", "< Set-Cookie: session=backend-secret"}
+			for _, line := range secrets {
+				if strings.Contains(output, line) != tt.showSecrets {
+					t.Errorf("expected %q logged=%v, got:\n%s", line, tt.showSecrets, output)
+				}
+			}
+			if !tt.showSecrets && !strings.Contains(output, "> Authorization: "+redacted) {
+				t.Errorf("expected redacted Authorization in debug log, got:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestServeHTTPDebugOffByDefault(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	var logs bytes.Buffer
+	proxy, _ := New(Config{ListenAddr: ":8080", TargetURL: mustParseURL(backend.URL), Logger: log.New(&logs, "", 0)})
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8080/", nil))
+
+	if strings.Contains(logs.String(), "DEBUG") {
+		t.Errorf("expected no debug lines without Debug, got:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a log destination safe to read while requests still log.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestServeHTTPClientDisconnectCancelsBackend(t *testing.T) {
+	tests := []struct {
+		name string
+		// streaming backends answer at once and then keep sending
+		streaming bool
+		logLine   string
+	}{
+		{"before the response", false, "Client closed connection before GET /slow was answered"},
+		{"during the response", true, "Client closed connection during response to GET /slow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			cancelled := make(chan struct{})
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.streaming {
+					w.WriteHeader(http.StatusOK)
+					_, _ = w.Write([]byte("first chunk"))
+					http.NewResponseController(w).Flush()
+				}
+				close(started)
+				<-r.Context().Done()
+				close(cancelled)
+			}))
+			defer backend.Close()
+
+			var logs syncBuffer
+			proxy, err := New(Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL(backend.URL),
+				Logger:     log.New(&logs, "", 0),
+			})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			server := httptest.NewServer(proxy)
+			defer server.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/slow", nil)
+			if !tt.streaming {
+				go func() {
+					<-started
+					cancel()
+				}()
+			}
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				// Hang up once the response is under way
+				chunk := make([]byte, len("first chunk"))
+				_, _ = io.ReadFull(resp.Body, chunk)
+				cancel()
+				resp.Body.Close()
+			}
+			cancel()
+
+			select {
+			case <-cancelled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected the backend request to be cancelled")
+			}
+
+			deadline := time.Now().Add(2 * time.Second)
+			for !strings.Contains(logs.String(), tt.logLine) && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			output := logs.String()
+			if !strings.Contains(output, tt.logLine) {
+				t.Errorf("expected %q in the log, got:\n%s", tt.logLine, output)
+			}
+			if strings.Contains(output, "Error proxying request") || strings.Contains(output, "Error copying response body") {
+				t.Errorf("expected the disconnect not to be logged as a backend error, got:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestServeHTTPClientDisconnectCountedAs499(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8080/", nil).WithContext(ctx))
+
+	counts := proxy.stats.snapshot(time.Now()).StatusCounts
+	if counts["499"] != 1 || counts["502"] != 0 {
+		t.Errorf("expected the request counted as 499, got %v", counts)
+	}
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// DNSCacheGrace is how long past its TTL a cached address may still be
+// used while the backend's name fails to resolve.
+const DNSCacheGrace = 5 * time.Minute
+
+// dnsCacheMaxEntries bounds the cache, which forward-proxy mode could
+// otherwise fill with every host clients ask for. Once it is full, the entry
+// that expires soonest makes room for a new host.
+const dnsCacheMaxEntries = 1024
+
+// HostResolver looks up the addresses of a host; *net.Resolver implements it.
+type HostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+	next    int
+}
+
+// dnsCache remembers resolved backend addresses for a TTL so new
+// connections do not each cost a DNS query. Successive dials start at the
+// next address in turn, spreading connections over every address of a host.
+type dnsCache struct {
+	resolver HostResolver
+	ttl      time.Duration
+	logger   *log.Logger
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+func newDNSCache(resolver HostResolver, ttl time.Duration, logger *log.Logger) *dnsCache {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &dnsCache{
+		resolver: resolver,
+		ttl:      ttl,
+		logger:   logger,
+		now:      time.Now,
+		entries:  make(map[string]*dnsEntry),
+	}
+}
+
+// lookup returns the addresses of host, rotated by one on every call. A
+// failed lookup falls back to the last known addresses for up to
+// DNSCacheGrace after they expired.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	entry := c.entries[host]
+	if entry != nil && c.now().Before(entry.expires) {
+		addrs := entry.rotate()
+		c.mu.Unlock()
+		return addrs, nil
+	}
+	c.mu.Unlock()
+
+	resolved, err := c.resolver.LookupIPAddr(ctx, host)
+	if err == nil && len(resolved) == 0 {
+		err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		entry = c.entries[host]
+		if entry != nil && c.now().Before(entry.expires.Add(DNSCacheGrace)) {
+			c.logger.Printf("WARNING: resolving %s failed, using cached addresses: %v", host, err)
+			return entry.rotate(), nil
+		}
+		return nil, err
+	}
+
+	addrs := make([]string, len(resolved))
+	for i, addr := range resolved {
+		addrs[i] = addr.String()
+	}
+	if _, cached := c.entries[host]; !cached && len(c.entries) >= dnsCacheMaxEntries {
+		c.prune()
+		if len(c.entries) >= dnsCacheMaxEntries {
+			c.evictSoonest()
+		}
+	}
+	entry = &dnsEntry{addrs: addrs, expires: c.now().Add(c.ttl)}
+	if previous := c.entries[host]; previous != nil {
+		entry.next = previous.next
+	}
+	c.entries[host] = entry
+	return entry.rotate(), nil
+}
+
+// prune drops entries too old to be used even as a fallback.
+func (c *dnsCache) prune() {
+	now := c.now()
+	for host, entry := range c.entries {
+		if !now.Before(entry.expires.Add(DNSCacheGrace)) {
+			delete(c.entries, host)
+		}
+	}
+}
+
+// evictSoonest drops the entry that expires first.
+func (c *dnsCache) evictSoonest() {
+	var soonest string
+	var expires time.Time
+	for host, entry := range c.entries {
+		if soonest == "" || entry.expires.Before(expires) {
+			soonest, expires = host, entry.expires
+		}
+	}
+	delete(c.entries, soonest)
+}
+
+func (e *dnsEntry) rotate() []string {
+	start := e.next % len(e.addrs)
+	e.next = start + 1
+	return append(append([]string(nil), e.addrs[start:]...), e.addrs[:start]...)
+}
+
+// dialContext wraps dial so host names are resolved through the cache. Each
+// address is tried in turn until one accepts the connection.
+func (c *dnsCache) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ip := range addrs {
+			var conn net.Conn
+			conn, err = dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, err
+	}
+}
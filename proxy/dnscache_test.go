@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubHostResolver answers every lookup with addrs, or err, counting calls.
+type stubHostResolver struct {
+	mu      sync.Mutex
+	addrs   []string
+	err     error
+	lookups int
+}
+
+func (s *stubHostResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	if s.err != nil {
+		return nil, s.err
+	}
+	var addrs []net.IPAddr
+	for _, addr := range s.addrs {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(addr)})
+	}
+	return addrs, nil
+}
+
+func (s *stubHostResolver) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookups
+}
+
+func TestDNSCacheLookup(t *testing.T) {
+	resolver := &stubHostResolver{addrs: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}}
+	cache := newDNSCache(resolver, time.Minute, log.New(io.Discard, "", 0))
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	var dialed []string
+	dial := cache.dialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, nil
+	})
+
+	for range 4 {
+		if _, err := dial(context.Background(), "tcp", "backend.internal:8080"); err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+	}
+	if resolver.count() != 1 {
+		t.Errorf("expected 1 lookup within the TTL, got %d", resolver.count())
+	}
+	expected := []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080", "10.0.0.1:8080"}
+	for i, addr := range expected {
+		if dialed[i] != addr {
+			t.Errorf("dial %d: expected %s, got %s", i, addr, dialed[i])
+		}
+	}
+
+	// Expired entries are looked up again
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.lookup(context.Background(), "backend.internal"); err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if resolver.count() != 2 {
+		t.Errorf("expected a new lookup after the TTL, got %d lookups", resolver.count())
+	}
+
+	// A failing resolver falls back to the last addresses within the grace window
+	resolver.err = errors.New("server misbehaving")
+	now = now.Add(2 * time.Minute)
+	if addrs, err := cache.lookup(context.Background(), "backend.internal"); err != nil || len(addrs) != 3 {
+		t.Errorf("expected the stale addresses during the grace window, got %v, %v", addrs, err)
+	}
+	now = now.Add(DNSCacheGrace)
+	if _, err := cache.lookup(context.Background(), "backend.internal"); err == nil {
+		t.Error("expected the lookup to fail once the grace window has passed")
+	}
+}
+
+func TestDNSCacheBoundedSize(t *testing.T) {
+	resolver := &stubHostResolver{addrs: []string{"10.0.0.1"}}
+	cache := newDNSCache(resolver, time.Minute, log.New(io.Discard, "", 0))
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	// Every entry is still fresh, so nothing can be pruned by age
+	for i := range dnsCacheMaxEntries + 10 {
+		if _, err := cache.lookup(context.Background(), fmt.Sprintf("host%d.example.com", i)); err != nil {
+			t.Fatalf("lookup failed: %v", err)
+		}
+		now = now.Add(time.Millisecond)
+	}
+
+	if len(cache.entries) != dnsCacheMaxEntries {
+		t.Errorf("expected the cache to hold %d entries, got %d", dnsCacheMaxEntries, len(cache.entries))
+	}
+	// The entries expiring soonest were evicted to make room
+	for i := range 10 {
+		if _, ok := cache.entries[fmt.Sprintf("host%d.example.com", i)]; ok {
+			t.Errorf("expected host%d to be evicted", i)
+		}
+	}
+	if _, ok := cache.entries[fmt.Sprintf("host%d.example.com", dnsCacheMaxEntries+9)]; !ok {
+		t.Error("expected the newest host to be cached")
+	}
+
+	// Refreshing a cached host in a full cache evicts nothing
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.lookup(context.Background(), "host500.example.com"); err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if len(cache.entries) != dnsCacheMaxEntries {
+		t.Errorf("expected a refresh to keep %d entries, got %d", dnsCacheMaxEntries, len(cache.entries))
+	}
+}
+
+func TestDNSCacheDialTriesEachAddress(t *testing.T) {
+	resolver := &stubHostResolver{addrs: []string{"10.0.0.1", "10.0.0.2"}}
+	cache := newDNSCache(resolver, time.Minute, log.New(io.Discard, "", 0))
+
+	dial := cache.dialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == "10.0.0.1:8080" {
+			return nil, errors.New("connection refused")
+		}
+		return nil, nil
+	})
+	if _, err := dial(context.Background(), "tcp", "backend.internal:8080"); err != nil {
+		t.Errorf("expected the second address to be used, got %v", err)
+	}
+}
+
+func TestServeHTTPDNSCacheReducesLookups(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Force a new connection, and so a new dial, for every request
+		w.Header().Set("Connection", "close")
+	}))
+	defer backend.Close()
+	_, port, _ := net.SplitHostPort(mustParseURL(backend.URL).Host)
+
+	resolver := &stubHostResolver{addrs: []string{"127.0.0.1"}}
+	proxy, err := New(Config{
+		ListenAddr:   ":8080",
+		TargetURL:    mustParseURL("http://backend.internal:" + port),
+		DNSCacheTTL:  time.Minute,
+		HostResolver: resolver,
+		Logger:       log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for range 5 {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+	}
+	if resolver.count() != 1 {
+		t.Errorf("expected 5 connections to share 1 lookup, got %d", resolver.count())
+	}
+}
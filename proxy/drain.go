@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"net/http"
+)
+
+// Drain puts the proxy into draining mode: requests already in flight run
+// to completion, while new ones are answered with 503 and Connection: close
+// so clients and load balancers move to another instance. Undrain reverses
+// it.
+func (p *Proxy) Drain() {
+	if !p.draining.Swap(true) {
+		p.logger.Printf("Draining: rejecting new requests")
+	}
+}
+
+// Undrain takes the proxy out of draining mode so it accepts requests again.
+func (p *Proxy) Undrain() {
+	if p.draining.Swap(false) {
+		p.logger.Printf("No longer draining: accepting requests")
+	}
+}
+
+// IsDraining reports whether the proxy is rejecting new requests.
+func (p *Proxy) IsDraining() bool {
+	return p.draining.Load()
+}
+
+func (p *Proxy) rejectDraining(w http.ResponseWriter) {
+	w.Header().Set("Connection", "close")
+	p.writeError(w, "Proxy is draining", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrorPage is a custom body served in place of the plain-text message when
+// the proxy itself responds with an error status.
+type ErrorPage struct {
+	ContentType string
+	Body        []byte
+}
+
+// ParseErrorPages loads "CODE:PATH" mappings at startup so a missing or
+// unreadable file is reported before the proxy starts serving. The content
+// type is contentType when given, otherwise derived from the file extension.
+func ParseErrorPages(values []string, contentType string) (map[int]ErrorPage, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	pages := make(map[int]ErrorPage)
+	for _, value := range values {
+		rawCode, path, ok := strings.Cut(value, ":")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid error page %q (expected 'CODE:PATH')", value)
+		}
+
+		code, err := strconv.Atoi(strings.TrimSpace(rawCode))
+		if err != nil || code < 400 || code > 599 {
+			return nil, fmt.Errorf("invalid error page status code %q (must be 400-599)", rawCode)
+		}
+
+		page, err := readPage(path, contentType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read error page for %d: %w", code, err)
+		}
+		pages[code] = page
+	}
+	return pages, nil
+}
+
+// readPage loads a page from path, typed as contentType when given,
+// otherwise by the file extension or, failing that, by sniffing the body.
+func readPage(path, contentType string) (ErrorPage, error) {
+	body, err := os.ReadFile(path) // #nosec G304 -- path is supplied by the operator
+	if err != nil {
+		return ErrorPage{}, err
+	}
+
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(path))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	return ErrorPage{ContentType: contentType, Body: body}, nil
+}
+
+// writeError sends a proxy-generated error, using the configured custom page
+// for the status code if there is one.
+func (p *Proxy) writeError(w http.ResponseWriter, message string, code int) {
+	page, ok := p.config.ErrorPages[code]
+	if !ok {
+		http.Error(w, message, code)
+		return
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", page.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_, _ = w.Write(page.Body)
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeErrorPage(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseErrorPages(t *testing.T) {
+	dir := t.TempDir()
+	html := writeErrorPage(t, dir, "502.html", "<h1>Bad gateway</h1>")
+	json := writeErrorPage(t, dir, "503.json", `{"error":"unavailable"}`)
+
+	pages, err := ParseErrorPages([]string{"502:" + html, "503:" + json}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := pages[502]; string(got.Body) != "<h1>Bad gateway</h1>" || !strings.HasPrefix(got.ContentType, "text/html") {
+		t.Errorf("unexpected 502 page: %q (%s)", got.Body, got.ContentType)
+	}
+	if got := pages[503].ContentType; got != "application/json" {
+		t.Errorf("expected JSON content type from extension, got %q", got)
+	}
+
+	pages, err = ParseErrorPages([]string{"502:" + html}, "text/plain; charset=utf-8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := pages[502].ContentType; got != "text/plain; charset=utf-8" {
+		t.Errorf("expected configured content type to win, got %q", got)
+	}
+
+	errorCases := []struct {
+		name  string
+		value string
+	}{
+		{"missing file", "502:" + filepath.Join(dir, "missing.html")},
+		{"missing path", "502:"},
+		{"missing separator", "502"},
+		{"non-numeric code", "bad:" + html},
+		{"non-error code", "200:" + html},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseErrorPages([]string{tt.value}, ""); err == nil {
+				t.Errorf("expected error for %q", tt.value)
+			}
+		})
+	}
+}
+
+func TestServeHTTPCustomErrorPage(t *testing.T) {
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("http://127.0.0.1:1"),
+		ErrorPages: map[int]ErrorPage{
+			http.StatusBadGateway: {ContentType: "text/html; charset=utf-8", Body: []byte("<h1>We'll be right back</h1>")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected status 502, got %d", w.Code)
+	}
+	if w.Body.String() != "<h1>We'll be right back</h1>" {
+		t.Errorf("expected custom page body, got %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("expected custom content type, got %q", got)
+	}
+}
+
+func TestServeHTTPErrorPageFallback(t *testing.T) {
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		Routes:     []Route{{Host: "api.example.com", Target: mustParseURL("http://127.0.0.1:1")}},
+		ErrorPages: map[int]ErrorPage{
+			http.StatusBadGateway: {ContentType: "text/html", Body: []byte("custom")},
+		},
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://other.example.com/", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", w.Code)
+	}
+	if w.Body.String() != "No route for host\n" {
+		t.Errorf("expected plain-text fallback, got %q", w.Body.String())
+	}
+}
+
+func TestServeHTTPBackendErrorNotReplaced(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("from backend"))
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		ErrorPages: map[int]ErrorPage{
+			http.StatusBadGateway: {ContentType: "text/html", Body: []byte("custom")},
+		},
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "from backend" {
+		t.Errorf("expected backend errors to be relayed unchanged, got %q", w.Body.String())
+	}
+}
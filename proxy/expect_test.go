@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// sendExpectContinue writes a POST with "Expect: 100-continue" to addr and
+// returns the first response the proxy sends back, before any body is sent.
+func sendExpectContinue(t *testing.T, addr string, contentLength int) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "POST /upload HTTP/1.1\r\nHost: %s\r\nContent-Length: %d\r\nExpect: 100-continue\r\n\r\n", addr, contentLength)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	return conn, reader, resp
+}
+
+func newExpectContinueProxy(t *testing.T, backend *httptest.Server) *httptest.Server {
+	t.Helper()
+
+	target, _ := url.Parse(backend.URL)
+	p, err := New(Config{
+		ListenAddr:            ":0",
+		TargetURL:             target,
+		Timeout:               5 * time.Second,
+		ExpectContinueTimeout: 2 * time.Second,
+		Logger:                log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServeHTTPExpectContinue(t *testing.T) {
+	const body = "hello backend"
+
+	var gotExpect string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotExpect = r.Header.Get("Expect")
+		data, _ := io.ReadAll(r.Body)
+		w.Write(data)
+	}))
+	defer backend.Close()
+
+	server := newExpectContinueProxy(t, backend)
+	conn, reader, resp := sendExpectContinue(t, server.Listener.Addr().String(), len(body))
+
+	if resp.StatusCode != http.StatusContinue {
+		t.Fatalf("expected 100 Continue before the body, got %d", resp.StatusCode)
+	}
+
+	if _, err := io.WriteString(conn, body); err != nil {
+		t.Fatalf("failed to send body: %v", err)
+	}
+
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("failed to read final response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+	if data, _ := io.ReadAll(resp.Body); string(data) != body {
+		t.Errorf("expected body %q, got %q", body, data)
+	}
+	if gotExpect != "100-continue" {
+		t.Errorf("expected backend to see Expect: 100-continue, got %q", gotExpect)
+	}
+}
+
+func TestServeHTTPExpectContinueRejected(t *testing.T) {
+	const limit = 1024
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Judge the upload by its declared size without reading it
+		if r.ContentLength > limit {
+			w.Header().Set("X-Limit", strconv.Itoa(limit))
+			http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	server := newExpectContinueProxy(t, backend)
+
+	// The rejection must arrive in place of 100 Continue, without the
+	// client ever sending the body
+	_, _, resp := sendExpectContinue(t, server.Listener.Addr().String(), 10*limit)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Limit"); got != strconv.Itoa(limit) {
+		t.Errorf("expected backend header X-Limit %d, got %q", limit, got)
+	}
+}
+
+func TestNewExpectContinueTimeout(t *testing.T) {
+	p, err := New(Config{ListenAddr: ":8080", TargetURL: mustParseURL("http://localhost:3000")})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if got := p.config.ExpectContinueTimeout; got != DefaultExpectContinueTimeout {
+		t.Errorf("expected default %v, got %v", DefaultExpectContinueTimeout, got)
+	}
+
+	_, err = New(Config{
+		ListenAddr:            ":8080",
+		TargetURL:             mustParseURL("http://localhost:3000"),
+		ExpectContinueTimeout: -time.Second,
+	})
+	if err == nil {
+		t.Error("expected error for negative expect-continue timeout")
+	}
+}
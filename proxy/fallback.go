@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultFallbackStatuses are the backend statuses that send a request to
+// the fallback backend when Config.FallbackStatuses is empty.
+var DefaultFallbackStatuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// ParseStatusCodes parses -fallback-status values, each a single 5xx code
+// or a comma-separated list of them.
+func ParseStatusCodes(values []string) ([]int, error) {
+	var codes []int
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || code < 500 || code > 599 {
+				return nil, fmt.Errorf("invalid fallback status %q (expected a 5xx status code)", field)
+			}
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+// shouldFallback reports whether the primary backend's answer to req counts
+// as a failure worth repeating against the fallback backend.
+func (p *Proxy) shouldFallback(req *http.Request, resp *http.Response, err error) bool {
+	if p.config.FallbackURL == nil || !canReplay(req) {
+		return false
+	}
+	if err != nil {
+		// A cancelled client or an expired deadline leaves no time to try
+		return req.Context().Err() == nil
+	}
+
+	statuses := p.config.FallbackStatuses
+	if len(statuses) == 0 {
+		statuses = DefaultFallbackStatuses
+	}
+	return slices.Contains(statuses, resp.StatusCode)
+}
+
+// canReplay reports whether req can be sent a second time: its body must be
+// buffered, or it must be an idempotent request without a body.
+func canReplay(req *http.Request) bool {
+	if req.GetBody != nil {
+		return true
+	}
+	return isIdempotent(req.Method) && (req.Body == nil || req.Body == http.NoBody)
+}
+
+// doFallback re-issues req against the fallback backend after the primary
+// failed, discarding the primary's response. The target is built from r as
+// for the primary; the Host header follows the backend unless it was
+// preserved or set explicitly.
+func (p *Proxy) doFallback(r *http.Request, req *http.Request, backend *Backend, resp *http.Response, err error) (*http.Response, error) {
+	var reason string
+	if err != nil {
+		reason = err.Error()
+	} else {
+		reason = strconv.Itoa(resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+
+	fallback := p.config.FallbackURL
+	fallbackReq := req.Clone(req.Context())
+	fallbackReq.URL = p.buildTargetURL(r, fallback)
+	backend.removeHeaders(fallbackReq.Header)
+	setURLCredentials(fallbackReq, fallback)
+	if req.Host == req.URL.Host {
+		fallbackReq.Host = fallback.Host
+	}
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, bodyErr
+		}
+		fallbackReq.Body = body
+	}
+
+	p.logger.Printf("Primary backend %s failed for %s %s (%s), using fallback %s", req.URL.Host, req.Method, req.URL.Path, reason, fallback.Host)
+	return p.httpClient.Do(fallbackReq)
+}
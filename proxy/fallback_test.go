@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newFallbackBackend(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte("fallback " + r.Host + " " + r.URL.RequestURI() + " " + string(body)))
+	}))
+	t.Cleanup(backend.Close)
+	return backend, &calls
+}
+
+func TestServeHTTPFallbackOnConnectionFailure(t *testing.T) {
+	fallback, calls := newFallbackBackend(t)
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	primaryURL := mustParseURL(primary.URL)
+	primary.Close()
+
+	var logBuf bytes.Buffer
+	proxy, _ := New(Config{
+		ListenAddr:  ":8080",
+		TargetURL:   primaryURL,
+		Timeout:     5 * time.Second,
+		FallbackURL: mustParseURL(fallback.URL),
+		Logger:      log.New(&logBuf, "", 0),
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/items?id=7", nil))
+
+	expected := "fallback " + mustParseURL(fallback.URL).Host + " /items?id=7 "
+	if w.Code != http.StatusOK || w.Body.String() != expected {
+		t.Errorf("expected fallback response %q, got %d %q", expected, w.Code, w.Body.String())
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 fallback call, got %d", calls.Load())
+	}
+	if !strings.Contains(logBuf.String(), "using fallback") {
+		t.Errorf("expected fallback to be logged, got %q", logBuf.String())
+	}
+}
+
+func TestServeHTTPFallbackOn5xx(t *testing.T) {
+	tests := []struct {
+		name           string
+		primaryStatus  int
+		statuses       []int
+		expectStatus   int
+		expectFallback bool
+	}{
+		{"default statuses include 503", http.StatusServiceUnavailable, nil, http.StatusOK, true},
+		{"500 is not a default trigger", http.StatusInternalServerError, nil, http.StatusInternalServerError, false},
+		{"configured status triggers", http.StatusInternalServerError, []int{500}, http.StatusOK, true},
+		{"configured statuses replace defaults", http.StatusServiceUnavailable, []int{500}, http.StatusServiceUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback, calls := newFallbackBackend(t)
+			primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.primaryStatus)
+			}))
+			defer primary.Close()
+
+			proxy, _ := New(Config{
+				ListenAddr:       ":8080",
+				TargetURL:        mustParseURL(primary.URL),
+				FallbackURL:      mustParseURL(fallback.URL),
+				FallbackStatuses: tt.statuses,
+				Logger:           log.New(io.Discard, "", 0),
+			})
+
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+
+			if w.Code != tt.expectStatus {
+				t.Errorf("expected status %d, got %d", tt.expectStatus, w.Code)
+			}
+			if got := calls.Load() == 1; got != tt.expectFallback {
+				t.Errorf("expected fallback used = %v, got %d calls", tt.expectFallback, calls.Load())
+			}
+		})
+	}
+}
+
+func TestServeHTTPFallbackRequiresReplayableRequest(t *testing.T) {
+	fallback, calls := newFallbackBackend(t)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:  ":8080",
+		TargetURL:   mustParseURL(primary.URL),
+		FallbackURL: mustParseURL(fallback.URL),
+		Logger:      log.New(io.Discard, "", 0),
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost:8080/orders", strings.NewReader("order")))
+	if w.Code != http.StatusBadGateway || calls.Load() != 0 {
+		t.Errorf("expected streamed POST to skip the fallback, got %d with %d fallback calls", w.Code, calls.Load())
+	}
+
+	// A buffered body can be sent again
+	proxy.RequestBodyTransformer = func(body []byte) ([]byte, error) { return body, nil }
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost:8080/orders", strings.NewReader("order")))
+	if w.Code != http.StatusOK || !strings.HasSuffix(w.Body.String(), " /orders order") {
+		t.Errorf("expected buffered POST to reach the fallback with its body, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestParseStatusCodes(t *testing.T) {
+	codes, err := ParseStatusCodes([]string{"500", "502, 504"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(codes) != 3 || codes[0] != 500 || codes[2] != 504 {
+		t.Errorf("unexpected codes: %v", codes)
+	}
+
+	for _, value := range []string{"404", "abc", "600", ""} {
+		if _, err := ParseStatusCodes([]string{value}); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
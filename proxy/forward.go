@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// forwardBackend returns the destination of an absolute-form request sent to
+// the proxy in forward-proxy mode, or nil when the request names no host.
+func forwardBackend(r *http.Request) *Backend {
+	if !r.URL.IsAbs() || r.URL.Host == "" {
+		return nil
+	}
+	if r.URL.Scheme != "http" && r.URL.Scheme != "https" {
+		return nil
+	}
+	return &Backend{URL: &url.URL{Scheme: r.URL.Scheme, Host: r.URL.Host}, Weight: 1}
+}
+
+// serveConnect handles a CONNECT request by opening a TCP connection to the
+// requested host:port and relaying bytes in both directions until either
+// side closes.
+func (p *Proxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	if _, port, err := net.SplitHostPort(r.Host); err != nil || port == "" {
+		p.writeError(w, "Invalid CONNECT target", http.StatusBadRequest)
+		return
+	}
+
+	dialer := &net.Dialer{Timeout: p.config.DialTimeout, KeepAlive: p.config.KeepAlive}
+	upstream, err := dialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		p.logger.Printf("Error connecting to %s: %v", r.Host, err)
+		p.writeError(w, "Failed to connect to target", http.StatusBadGateway)
+		return
+	}
+
+	conn, buffered, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		p.logger.Printf("Error hijacking connection for CONNECT: %v", err)
+		p.writeError(w, "Tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The server's read and write deadlines would otherwise cut the tunnel
+	_ = conn.SetDeadline(time.Time{})
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		_ = conn.Close()
+		_ = upstream.Close()
+		return
+	}
+
+	// Bytes the client sent right after the CONNECT request may already
+	// sit in the server's read buffer
+	if n := buffered.Reader.Buffered(); n > 0 {
+		data, _ := buffered.Reader.Peek(n)
+		if _, err := upstream.Write(data); err != nil {
+			_ = conn.Close()
+			_ = upstream.Close()
+			return
+		}
+	}
+
+	p.logger.Printf("CONNECT tunnel established to %s", r.Host)
+	tunnel(conn, upstream)
+}
+
+// tunnel copies data between a and b, half-closing each side as its peer
+// finishes sending, and closes both once the two directions are done. It
+// returns the number of bytes sent from a to b and from b to a.
+func tunnel(a, b io.ReadWriteCloser) (fromA, fromB int64) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	relay := func(dst, src io.ReadWriteCloser, n *int64) {
+		defer wg.Done()
+		*n, _ = io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = cw.CloseWrite()
+		} else {
+			_ = dst.Close()
+		}
+	}
+
+	go relay(b, a, &fromA)
+	go relay(a, b, &fromB)
+	wg.Wait()
+
+	_ = a.Close()
+	_ = b.Close()
+	return fromA, fromB
+}
@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newForwardProxyServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	proxy, err := New(Config{ListenAddr: ":8080", ForwardProxy: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server := httptest.NewServer(proxy)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestForwardProxyConnectTunnel(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = echo.Close() }()
+	go func() {
+		conn, err := echo.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	server := newForwardProxyServer(t)
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	target := echo.Addr().String()
+	_, _ = io.WriteString(conn, "CONNECT "+target+" HTTP/1.1\r\nHost: "+target+"\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("failed to read CONNECT response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Status != "200 Connection Established" {
+		t.Fatalf("expected 200 Connection Established, got %q", resp.Status)
+	}
+
+	_, _ = io.WriteString(conn, "ping through tunnel")
+	buf := make([]byte, len("ping through tunnel"))
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		t.Fatalf("failed to read through tunnel: %v", err)
+	}
+	if string(buf) != "ping through tunnel" {
+		t.Errorf("expected echoed data, got %q", buf)
+	}
+}
+
+func TestForwardProxyConnectTLS(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secure " + r.URL.Path))
+	}))
+	defer backend.Close()
+
+	server := newForwardProxyServer(t)
+	client := backend.Client()
+	client.Transport.(*http.Transport).Proxy = http.ProxyURL(mustParseURL(server.URL))
+
+	resp, err := client.Get(backend.URL + "/tunneled")
+	if err != nil {
+		t.Fatalf("request through CONNECT tunnel failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "secure /tunneled" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestForwardProxyAbsoluteURI(t *testing.T) {
+	var receivedHost string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHost = r.Host
+		if r.Header.Get("Proxy-Connection") != "" {
+			t.Error("Proxy-Connection should not be forwarded")
+		}
+		_, _ = w.Write([]byte("plain " + r.URL.RequestURI()))
+	}))
+	defer backend.Close()
+
+	server := newForwardProxyServer(t)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(mustParseURL(server.URL))}}
+
+	req, _ := http.NewRequest("GET", backend.URL+"/items?id=7", nil)
+	req.Header.Set("Proxy-Connection", "keep-alive")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request through forward proxy failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "plain /items?id=7" {
+		t.Errorf("unexpected body %q", body)
+	}
+	if receivedHost != mustParseURL(backend.URL).Host {
+		t.Errorf("expected Host %s, got %s", mustParseURL(backend.URL).Host, receivedHost)
+	}
+}
+
+func TestForwardProxyErrors(t *testing.T) {
+	server := newForwardProxyServer(t)
+
+	tests := []struct {
+		name     string
+		request  string
+		expected int
+	}{
+		{"unreachable CONNECT target", "CONNECT 127.0.0.1:1 HTTP/1.1\r\nHost: 127.0.0.1:1\r\n\r\n", http.StatusBadGateway},
+		{"CONNECT target without port", "CONNECT example.com HTTP/1.1\r\nHost: example.com\r\n\r\n", http.StatusBadRequest},
+		{"origin-form request without backend", "GET /path HTTP/1.1\r\nHost: proxy.local\r\n\r\n", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", server.Listener.Addr().String())
+			if err != nil {
+				t.Fatalf("failed to dial proxy: %v", err)
+			}
+			defer func() { _ = conn.Close() }()
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			_, _ = io.WriteString(conn, tt.request)
+			method, _, _ := strings.Cut(tt.request, " ")
+			resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: method})
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestForwardBackend(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{"http://example.com/path?q=1", "http://example.com"},
+		{"https://example.com:8443/", "https://example.com:8443"},
+		{"/relative", ""},
+		{"ftp://example.com/file", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			r := &http.Request{URL: mustParseURL(tt.target)}
+			backend := forwardBackend(r)
+			got := ""
+			if backend != nil {
+				got = backend.URL.String()
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
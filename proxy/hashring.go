@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hashVirtualNodes is how many points a backend of weight 1 gets on the
+// ring; more points spread keys more evenly at the cost of a larger ring.
+const hashVirtualNodes = 160
+
+type hashPoint struct {
+	hash    uint64
+	backend *Backend
+}
+
+// consistentHashSelector places the healthy backends on a hash ring and
+// sends each request to the first backend point at or after the hash of
+// its key. The same key keeps reaching the same backend, and a backend
+// joining or leaving only moves the keys that land next to its own points.
+type consistentHashSelector struct {
+	key func(r *http.Request) string
+
+	mu      sync.Mutex
+	members []*Backend
+	ring    []hashPoint
+}
+
+func newConsistentHashSelector(key func(r *http.Request) string) *consistentHashSelector {
+	return &consistentHashSelector{key: key}
+}
+
+// parseHashKey turns a -hash-key value ("ip", "header:NAME" or
+// "cookie:NAME") into a function returning a request's key. Requests
+// without the header or cookie are keyed on their client IP.
+func parseHashKey(value string, trustedProxies []*net.IPNet, trustedHops int) (func(r *http.Request) string, error) {
+	clientIP := func(r *http.Request) string {
+		return getClientIP(r, trustedProxies, trustedHops)
+	}
+
+	kind, name, _ := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "ip":
+		if name == "" {
+			return clientIP, nil
+		}
+	case "header":
+		if name != "" {
+			return func(r *http.Request) string {
+				if key := r.Header.Get(name); key != "" {
+					return key
+				}
+				return clientIP(r)
+			}, nil
+		}
+	case "cookie":
+		if name != "" {
+			return func(r *http.Request) string {
+				if cookie, err := r.Cookie(name); err == nil && cookie.Value != "" {
+					return cookie.Value
+				}
+				return clientIP(r)
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid hash key %q (must be ip, header:NAME or cookie:NAME)", value)
+}
+
+func (s *consistentHashSelector) Select(r *http.Request, candidates []*Backend) *Backend {
+	eligible := make([]*Backend, 0, len(candidates))
+	for _, backend := range candidates {
+		if backend.Weight > 0 {
+			eligible = append(eligible, backend)
+		}
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+	hash := hashString(s.key(r))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The ring only changes with the healthy set, so it is rebuilt lazily
+	if !slices.Equal(eligible, s.members) {
+		s.members = eligible
+		s.ring = buildHashRing(eligible)
+	}
+
+	i := sort.Search(len(s.ring), func(i int) bool { return s.ring[i].hash >= hash })
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.ring[i].backend
+}
+
+// buildHashRing gives every backend hashVirtualNodes points per unit of
+// weight. Points are derived from the backend URL rather than its position
+// in the pool, so other proxies and restarts agree on the ring.
+func buildHashRing(backends []*Backend) []hashPoint {
+	var ring []hashPoint
+	for _, backend := range backends {
+		name := backend.URL.String()
+		for i := 0; i < hashVirtualNodes*backend.Weight; i++ {
+			ring = append(ring, hashPoint{hash: hashString(name + "#" + strconv.Itoa(i)), backend: backend})
+		}
+	}
+	slices.SortFunc(ring, func(a, b hashPoint) int { return cmp.Compare(a.hash, b.hash) })
+	return ring
+}
+
+// hashString hashes s with FNV-1a and the splitmix64 finalizer. FNV alone
+// barely changes between inputs that differ in their last byte, such as a
+// backend's point names, which would bunch its points together.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
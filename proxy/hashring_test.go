@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newHashBackends(n int) []*Backend {
+	var backends []*Backend
+	for i := 0; i < n; i++ {
+		backends = append(backends, &Backend{URL: mustParseURL(fmt.Sprintf("http://10.0.0.%d:8080", i+1)), Weight: 1})
+	}
+	return backends
+}
+
+// hashAssignments maps each of n keys, sent in the X-User-ID header, to the
+// host of the backend it is routed to.
+func hashAssignments(t *testing.T, selector BackendSelector, backends []*Backend, n int) map[string]string {
+	t.Helper()
+
+	assignments := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		key := "user-" + strconv.Itoa(i)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User-ID", key)
+		assignments[key] = selector.Select(req, backends).URL.Host
+	}
+	return assignments
+}
+
+func TestConsistentHashSelectorStability(t *testing.T) {
+	const keys = 10000
+	key, err := parseHashKey("header:X-User-ID", nil, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	selector := newConsistentHashSelector(key)
+	backends := newHashBackends(5)
+
+	before := hashAssignments(t, selector, backends, keys)
+	if again := hashAssignments(t, selector, backends, keys); fmt.Sprint(again) != fmt.Sprint(before) {
+		t.Fatal("expected the same keys to reach the same backends")
+	}
+
+	counts := make(map[string]int)
+	for _, host := range before {
+		counts[host]++
+	}
+	for _, backend := range backends {
+		if got := counts[backend.URL.Host]; math.Abs(float64(got)-keys/5) > keys/5*0.25 {
+			t.Errorf("backend %s got %d keys, expected about %d", backend.URL.Host, got, keys/5)
+		}
+	}
+
+	t.Run("backend removed", func(t *testing.T) {
+		removed := backends[2].URL.Host
+		after := hashAssignments(t, selector, append(append([]*Backend(nil), backends[:2]...), backends[3:]...), keys)
+
+		moved := 0
+		for key, host := range before {
+			if host == removed {
+				moved++
+				continue
+			}
+			if after[key] != host {
+				t.Fatalf("key %s moved from %s to %s although its backend stayed", key, host, after[key])
+			}
+		}
+		if moved > keys/5*5/4 {
+			t.Errorf("expected about a fifth of the keys to move, %d of %d did", moved, keys)
+		}
+	})
+
+	t.Run("backend added", func(t *testing.T) {
+		added := &Backend{URL: mustParseURL("http://10.0.0.6:8080"), Weight: 1}
+		after := hashAssignments(t, selector, append(append([]*Backend(nil), backends...), added), keys)
+
+		moved := 0
+		for key, host := range before {
+			if after[key] == host {
+				continue
+			}
+			if after[key] != added.URL.Host {
+				t.Fatalf("key %s moved from %s to %s instead of the new backend", key, host, after[key])
+			}
+			moved++
+		}
+		if moved == 0 || moved > keys/6*5/4 {
+			t.Errorf("expected about a sixth of the keys to move, %d of %d did", moved, keys)
+		}
+	})
+}
+
+func TestConsistentHashSelectorDrained(t *testing.T) {
+	key, _ := parseHashKey("ip", nil, 0)
+	selector := newConsistentHashSelector(key)
+	req := httptest.NewRequest("GET", "/", nil)
+
+	if backend := selector.Select(req, []*Backend{{URL: mustParseURL("http://a"), Weight: 0}}); backend != nil {
+		t.Errorf("expected no backend when all are drained, got %s", backend.URL)
+	}
+}
+
+func TestParseHashKey(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.7:5555"
+	req.Header.Set("X-User-ID", "alice")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "abc123"})
+
+	tests := []struct {
+		value       string
+		expectKey   string
+		expectError bool
+	}{
+		{"ip", "192.0.2.7", false},
+		{"header:X-User-ID", "alice", false},
+		{"Header: x-user-id", "alice", false},
+		{"cookie:sid", "abc123", false},
+		{"header:X-Missing", "192.0.2.7", false},
+		{"cookie:missing", "192.0.2.7", false},
+		{"header:", "", true},
+		{"ip:1", "", true},
+		{"query:id", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			key, err := parseHashKey(tt.value, nil, 0)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := key(req); got != tt.expectKey {
+				t.Errorf("expected key %q, got %q", tt.expectKey, got)
+			}
+		})
+	}
+}
+
+func TestServeHTTPConsistentHash(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		}))
+	}
+	a := newBackend("a")
+	defer a.Close()
+	b := newBackend("b")
+	defer b.Close()
+	c := newBackend("c")
+	defer c.Close()
+
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		HashKey:    "cookie:sid",
+		Backends: []Backend{
+			{URL: mustParseURL(a.URL), Weight: 1},
+			{URL: mustParseURL(b.URL), Weight: 1},
+			{URL: mustParseURL(c.URL), Weight: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 30; i++ {
+		sid := "session-" + strconv.Itoa(i)
+		var first string
+		for j := 0; j < 3; j++ {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.AddCookie(&http.Cookie{Name: "sid", Value: sid})
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+			if j == 0 {
+				first = w.Body.String()
+			} else if w.Body.String() != first {
+				t.Fatalf("session %s reached %s and then %s", sid, first, w.Body.String())
+			}
+		}
+		seen[first] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected sessions spread over all 3 backends, got %v", seen)
+	}
+}
+
+func TestNewRejectsHashKeyWithoutConsistentHash(t *testing.T) {
+	_, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL("http://example.com"),
+		LoadBalancing: LoadBalancingLeastConn,
+		HashKey:       "ip",
+	})
+	if err == nil {
+		t.Fatal("expected error for a hash key with least-conn")
+	}
+}
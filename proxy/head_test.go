@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPHeadKeepsContentLength(t *testing.T) {
+	var method string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Length", "1234")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"default", Config{}},
+		{"compress and buffer", Config{Compress: true, BufferResponse: true}},
+		{"body replace", Config{BodyReplacements: []BodyReplacement{{Old: "a", New: "b"}}}},
+		{"cached", Config{CacheSize: 1 << 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.ListenAddr = ":8080"
+			config.TargetURL = mustParseURL(backend.URL)
+			config.Logger = log.New(io.Discard, "", 0)
+			proxy, err := New(config)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			server := httptest.NewServer(proxy)
+			defer server.Close()
+
+			// The second request checks a cached HEAD response too
+			for i := 0; i < 2; i++ {
+				req, _ := http.NewRequest(http.MethodHead, server.URL+"/page", nil)
+				req.Header.Set("Accept-Encoding", "gzip")
+				resp, err := http.DefaultTransport.RoundTrip(req)
+				if err != nil {
+					t.Fatalf("HEAD failed: %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+
+				if method != http.MethodHead {
+					t.Errorf("expected the backend to see HEAD, got %s", method)
+				}
+				if resp.StatusCode != http.StatusOK {
+					t.Errorf("expected status 200, got %d", resp.StatusCode)
+				}
+				if resp.ContentLength != 1234 || resp.Header.Get("Content-Length") != "1234" {
+					t.Errorf("expected the backend's Content-Length 1234, got %d (%q)", resp.ContentLength, resp.Header.Get("Content-Length"))
+				}
+				if resp.Header.Get("ETag") != `"v1"` || resp.Header.Get("Content-Encoding") != "" {
+					t.Errorf("unexpected headers: %v", resp.Header)
+				}
+				if len(body) != 0 {
+					t.Errorf("expected no body, got %q", body)
+				}
+				if i == 1 && config.CacheSize > 0 && resp.Header.Get("X-Cache") != "HIT" {
+					t.Errorf("expected the HEAD response to be cached, got X-Cache %q", resp.Header.Get("X-Cache"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// rawHeaderBackend accepts one request at a time and reports the header
+// lines exactly as they arrived on the wire.
+func rawHeaderBackend(t *testing.T) (string, <-chan []string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	lines := make(chan []string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			var headers []string
+			for {
+				line, err := reader.ReadString('\n')
+				line = strings.TrimRight(line, "\r\n")
+				if err != nil || line == "" {
+					break
+				}
+				headers = append(headers, line)
+			}
+			_, _ = io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+			conn.Close()
+			lines <- headers
+		}
+	}()
+	return "http://" + listener.Addr().String(), lines
+}
+
+func TestServeHTTPPreserveHeaderCase(t *testing.T) {
+	tests := []struct {
+		name     string
+		preserve bool
+		expect   []string
+	}{
+		{"canonicalized by default", false, []string{"X-Custom-Id: abc", "X-Backend-Token: t1"}},
+		{"preserved when enabled", true, []string{"x-custom-ID: abc", "x-backend-token: t1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backendURL, lines := rawHeaderBackend(t)
+			proxy, err := New(Config{
+				ListenAddr:         ":8080",
+				Backends:           []Backend{{URL: mustParseURL(backendURL), Weight: 1, Headers: map[string]string{"x-backend-token": "t1"}}},
+				CustomHeaders:      map[string]string{"x-custom-ID": "abc"},
+				PreserveHeaderCase: tt.preserve,
+				Logger:             log.New(io.Discard, "", 0),
+			})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+			// The client's copy is replaced, not sent alongside
+			req.Header.Set("X-Custom-Id", "from-client")
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			got := <-lines
+			for _, want := range tt.expect {
+				found := false
+				for _, line := range got {
+					found = found || line == want
+				}
+				if !found {
+					t.Errorf("expected header line %q, got %q", want, got)
+				}
+			}
+			for _, line := range got {
+				if strings.Contains(line, "from-client") {
+					t.Errorf("expected the client's X-Custom-Id to be replaced, got %q", line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const healthCheckTimeout = 5 * time.Second
+
+// healthChecker periodically probes each backend and tracks whether it is
+// currently fit to receive traffic. Backends start out healthy so traffic
+// flows before the first round of checks completes.
+type healthChecker struct {
+	client   *http.Client
+	logger   *log.Logger
+	path     string
+	interval time.Duration
+	backends []*url.URL
+
+	mu     sync.RWMutex
+	status map[string]bool
+}
+
+func newHealthChecker(backends []*url.URL, path string, interval time.Duration, client *http.Client, logger *log.Logger) *healthChecker {
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	status := make(map[string]bool, len(backends))
+	for _, backend := range backends {
+		status[backend.String()] = true
+	}
+
+	return &healthChecker{
+		client:   client,
+		logger:   logger,
+		path:     path,
+		interval: interval,
+		backends: backends,
+		status:   status,
+	}
+}
+
+func (h *healthChecker) run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	h.checkAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.checkAll(ctx)
+		}
+	}
+}
+
+func (h *healthChecker) checkAll(ctx context.Context) {
+	h.mu.RLock()
+	backends := h.backends
+	h.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, backend := range backends {
+		wg.Add(1)
+		go func(backend *url.URL) {
+			defer wg.Done()
+			healthy := h.check(ctx, backend)
+			h.setHealthy(backend, healthy)
+		}(backend)
+	}
+	wg.Wait()
+}
+
+func (h *healthChecker) check(ctx context.Context, backend *url.URL) bool {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	checkURL := &url.URL{Scheme: backend.Scheme, User: backend.User, Host: backend.Host, Path: h.path}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL.String(), nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		h.logger.Printf("Health check failed for %s: %v", backend.Host, err)
+		return false
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
+
+// setBackends replaces the set of checked backends after a reload. Backends
+// that were already known keep their state; new ones start out healthy.
+func (h *healthChecker) setBackends(backends []*url.URL) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := make(map[string]bool, len(backends))
+	for _, backend := range backends {
+		healthy, ok := h.status[backend.String()]
+		status[backend.String()] = healthy || !ok
+	}
+	h.backends = backends
+	h.status = status
+}
+
+func (h *healthChecker) setHealthy(backend *url.URL, healthy bool) {
+	key := backend.String()
+
+	h.mu.Lock()
+	previous, known := h.status[key]
+	if !known {
+		// Dropped by a reload while the check was running
+		h.mu.Unlock()
+		return
+	}
+	h.status[key] = healthy
+	h.mu.Unlock()
+
+	if previous != healthy {
+		state := "down"
+		if healthy {
+			state = "up"
+		}
+		h.logger.Printf("Backend %s is now %s", backend.Host, state)
+	}
+}
+
+func (h *healthChecker) isHealthy(backend *url.URL) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status[backend.String()]
+}
+
+func (h *healthChecker) snapshot() map[string]bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	status := make(map[string]bool, len(h.status))
+	for key, healthy := range h.status {
+		status[key] = healthy
+	}
+	return status
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckerMarksBackendDown(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" && !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	config := Config{
+		ListenAddr:     ":8080",
+		TargetURL:      backendURL,
+		HealthInterval: time.Second,
+		HealthPath:     "/healthz",
+	}
+	proxy, _ := New(config)
+
+	if status := proxy.HealthStatus(); !status[backendURL.String()] {
+		t.Fatal("expected backend to start out healthy")
+	}
+
+	healthy.Store(false)
+	proxy.health.checkAll(context.Background())
+
+	if status := proxy.HealthStatus(); status[backendURL.String()] {
+		t.Fatal("expected backend to be marked down")
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 with all backends down, got %d", w.Code)
+	}
+
+	healthy.Store(true)
+	proxy.health.checkAll(context.Background())
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 after recovery, got %d", w.Code)
+	}
+}
+
+func TestHealthCheckerUnreachableBackend(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backendURL := mustParseURL(backend.URL)
+	backend.Close()
+
+	config := Config{
+		ListenAddr:     ":8080",
+		TargetURL:      backendURL,
+		HealthInterval: time.Second,
+	}
+	proxy, _ := New(config)
+	proxy.health.checkAll(context.Background())
+
+	if status := proxy.HealthStatus(); status[backendURL.String()] {
+		t.Error("expected unreachable backend to be marked down")
+	}
+}
+
+func TestHealthStatusWithoutHealthChecks(t *testing.T) {
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("https://example.com"),
+	}
+	proxy, _ := New(config)
+
+	status := proxy.HealthStatus()
+	if len(status) != 1 || !status["https://example.com"] {
+		t.Errorf("expected single healthy backend, got %v", status)
+	}
+}
+
+func TestHealthCheckerRunStopsOnCancel(t *testing.T) {
+	var checks atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	config := Config{
+		ListenAddr:     ":8080",
+		TargetURL:      mustParseURL(backend.URL),
+		HealthInterval: 20 * time.Millisecond,
+	}
+	proxy, _ := New(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		proxy.health.run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("health checker did not stop after cancel")
+	}
+
+	if checks.Load() < 2 {
+		t.Errorf("expected periodic health checks, got %d", checks.Load())
+	}
+}
+
+func TestServeHTTPSelfHealthPath(t *testing.T) {
+	backendCalls := 0
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendCalls++
+		_, _ = w.Write([]byte("backend " + r.URL.Path))
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name         string
+		healthPath   string
+		expectBody   string
+		expectCalls  int
+		expectLogged bool
+	}{
+		{"answered by proxy", "/healthz", "ok", 0, false},
+		{"disabled forwards to backend", "", "backend /healthz", 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backendCalls = 0
+			var logBuf bytes.Buffer
+			proxy, _ := New(Config{
+				ListenAddr:     ":8080",
+				TargetURL:      mustParseURL(backend.URL),
+				SelfHealthPath: tt.healthPath,
+				Logger:         log.New(&logBuf, "", 0),
+			})
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/healthz", nil)
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("expected status 200, got %d", w.Code)
+			}
+			if w.Body.String() != tt.expectBody {
+				t.Errorf("expected body %q, got %q", tt.expectBody, w.Body.String())
+			}
+			if backendCalls != tt.expectCalls {
+				t.Errorf("expected %d backend calls, got %d", tt.expectCalls, backendCalls)
+			}
+			if logged := strings.Contains(logBuf.String(), "/healthz"); logged != tt.expectLogged {
+				t.Errorf("expected logged=%v, log output %q", tt.expectLogged, logBuf.String())
+			}
+		})
+	}
+}
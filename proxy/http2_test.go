@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func protoBackend() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "HTTP/%d", r.ProtoMajor)
+	}
+}
+
+func proxyProto(t *testing.T, config Config) string {
+	t.Helper()
+
+	proxy, err := New(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/proto", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	body, _ := io.ReadAll(w.Result().Body)
+	return string(body)
+}
+
+func TestBackendDefaultsToHTTP1(t *testing.T) {
+	backend := httptest.NewUnstartedServer(protoBackend())
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	defer backend.Close()
+
+	proto := proxyProto(t, Config{
+		ListenAddr:         ":8080",
+		TargetURL:          mustParseURL(backend.URL),
+		InsecureSkipVerify: true,
+	})
+	if proto != "HTTP/1" {
+		t.Errorf("expected HTTP/1 by default, got %s", proto)
+	}
+}
+
+func TestBackendHTTP2(t *testing.T) {
+	backend := httptest.NewUnstartedServer(protoBackend())
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	defer backend.Close()
+
+	proto := proxyProto(t, Config{
+		ListenAddr:         ":8080",
+		TargetURL:          mustParseURL(backend.URL),
+		InsecureSkipVerify: true,
+		HTTP2:              true,
+	})
+	if proto != "HTTP/2" {
+		t.Errorf("expected HTTP/2 with -http2, got %s", proto)
+	}
+}
+
+func TestBackendH2C(t *testing.T) {
+	backend := httptest.NewUnstartedServer(protoBackend())
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetHTTP1(true)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	defer backend.Close()
+
+	if proto := proxyProto(t, Config{ListenAddr: ":8080", TargetURL: mustParseURL(backend.URL)}); proto != "HTTP/1" {
+		t.Errorf("expected HTTP/1 without -h2c, got %s", proto)
+	}
+
+	proto := proxyProto(t, Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		H2C:        true,
+	})
+	if proto != "HTTP/2" {
+		t.Errorf("expected cleartext HTTP/2 with -h2c, got %s", proto)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build http3
+
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+const http3Supported = true
+
+// http3Server answers HTTP/3 on the UDP socket sharing a TCP listener's port.
+type http3Server struct {
+	server *http3.Server
+	conn   net.PacketConn
+}
+
+// newHTTP3Server binds the UDP port matching listener, which is the port
+// the Alt-Svc header sent over TCP points clients at.
+func (p *Proxy) newHTTP3Server(listener net.Listener) (quicServer, error) {
+	tlsConfig, err := p.http3TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenPacket("udp", listener.Addr().String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for HTTP/3: %w", err)
+	}
+
+	return &http3Server{
+		server: &http3.Server{
+			Handler:        p,
+			TLSConfig:      http3.ConfigureTLSConfig(tlsConfig),
+			IdleTimeout:    60 * time.Second,
+			MaxHeaderBytes: p.config.MaxHeaderBytes,
+		},
+		conn: conn,
+	}, nil
+}
+
+func (p *Proxy) http3TLSConfig() (*tls.Config, error) {
+	if p.autocert != nil {
+		return p.autocert.TLSConfig(), nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(p.config.TLSCertFile, p.config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate for HTTP/3: %w", err)
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS13, Certificates: []tls.Certificate{cert}}, nil
+}
+
+func (s *http3Server) Serve() error {
+	return s.server.Serve(s.conn)
+}
+
+func (s *http3Server) Close() error {
+	err := s.server.Close()
+	_ = s.conn.Close()
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !http3
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+)
+
+// HTTP/3 pulls in a QUIC implementation, so it is only compiled in with
+// -tags http3.
+const http3Supported = false
+
+func (p *Proxy) newHTTP3Server(net.Listener) (quicServer, error) {
+	return nil, fmt.Errorf("HTTP/3 support is not built in; rebuild with -tags http3")
+}
@@ -0,0 +1,95 @@
+//go:build http3
+
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestServeHTTP3(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := writeTestCertificate(t, dir, "server")
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Forwarded-Proto-Seen", r.Header.Get("X-Forwarded-Proto"))
+		_, _ = io.WriteString(w, "hello from "+r.URL.Path)
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:  "127.0.0.1:0",
+		TargetURL:   mustParseURL(backend.URL),
+		TLSCertFile: serverCert,
+		TLSKeyFile:  serverKey,
+		HTTP3:       true,
+		Logger:      log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	go func() { _ = proxy.Serve(listener) }()
+
+	serverPEM, _ := os.ReadFile(serverCert)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(serverPEM)
+	addr := listener.Addr().String()
+	_, port, _ := net.SplitHostPort(addr)
+
+	// HTTP/1.1 and HTTP/2 responses point clients at the QUIC port
+	tcpClient := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+			ForceAttemptHTTP2: true,
+		},
+	}
+	resp, err := tcpClient.Get("https://" + addr + "/tcp")
+	if err != nil {
+		t.Fatalf("TCP request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if want := `h3=":` + port + `"; ma=86400`; resp.Header.Get("Alt-Svc") != want {
+		t.Errorf("expected Alt-Svc %q, got %q", want, resp.Header.Get("Alt-Svc"))
+	}
+
+	transport := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS13}}
+	defer func() { _ = transport.Close() }()
+	client := &http.Client{Timeout: 5 * time.Second, Transport: transport}
+
+	resp, err = client.Get("https://" + addr + "/quic")
+	if err != nil {
+		t.Fatalf("HTTP/3 request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.ProtoMajor != 3 {
+		t.Errorf("expected an HTTP/3 response, got %s", resp.Proto)
+	}
+	if string(body) != "hello from /quic" {
+		t.Errorf("expected the backend's body, got %q", body)
+	}
+	if got := resp.Header.Get("X-Forwarded-Proto-Seen"); got != "https" {
+		t.Errorf("expected the backend to see X-Forwarded-Proto https, got %q", got)
+	}
+	if resp.Header.Get("Alt-Svc") != "" {
+		t.Errorf("expected no Alt-Svc over HTTP/3, got %q", resp.Header.Get("Alt-Svc"))
+	}
+}
@@ -1,11 +1,15 @@
-package main
+package proxy
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -22,13 +26,13 @@ func TestIntegrationBasicProxy(t *testing.T) {
 	proxyAddr := findFreePort(t)
 	backendURL := mustParseURL(backend.URL)
 
-	config := ProxyConfig{
+	config := Config{
 		ListenAddr: proxyAddr,
 		TargetURL:  backendURL,
 		Timeout:    5 * time.Second,
 	}
 
-	proxy, err := NewProxy(config, nil)
+	proxy, err := New(config)
 	if err != nil {
 		t.Fatalf("failed to create proxy: %v", err)
 	}
@@ -76,13 +80,13 @@ func TestIntegrationProxyHeaders(t *testing.T) {
 	proxyAddr := findFreePort(t)
 	backendURL := mustParseURL(backend.URL)
 
-	config := ProxyConfig{
+	config := Config{
 		ListenAddr: proxyAddr,
 		TargetURL:  backendURL,
 		Timeout:    5 * time.Second,
 	}
 
-	proxy, err := NewProxy(config, nil)
+	proxy, err := New(config)
 	if err != nil {
 		t.Fatalf("failed to create proxy: %v", err)
 	}
@@ -136,11 +140,11 @@ func TestServeHTTPWithMultipleHeaderValues(t *testing.T) {
 	defer backend.Close()
 
 	backendURL := mustParseURL(backend.URL)
-	config := ProxyConfig{
+	config := Config{
 		ListenAddr: ":8080",
 		TargetURL:  backendURL,
 	}
-	proxy, _ := NewProxy(config, nil)
+	proxy, _ := New(config)
 
 	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
 	w := httptest.NewRecorder()
@@ -157,11 +161,11 @@ func TestServeHTTPWithMultipleHeaderValues(t *testing.T) {
 
 func TestServeHTTPErrorCreatingRequest(t *testing.T) {
 	backendURL := mustParseURL("http://example.com")
-	config := ProxyConfig{
+	config := Config{
 		ListenAddr: ":8080",
 		TargetURL:  backendURL,
 	}
-	proxy, _ := NewProxy(config, nil)
+	proxy, _ := New(config)
 
 	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
 	req.Method = "\n"
@@ -177,11 +181,11 @@ func TestServeHTTPErrorCreatingRequest(t *testing.T) {
 
 func TestAddForwardedHeadersEmptyHost(t *testing.T) {
 	targetURL := mustParseURL("https://target.example.com")
-	config := ProxyConfig{
+	config := Config{
 		ListenAddr: ":8080",
 		TargetURL:  targetURL,
 	}
-	proxy, _ := NewProxy(config, nil)
+	proxy, _ := New(config)
 
 	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
 	srcReq.RemoteAddr = "192.168.1.100:12345"
@@ -207,11 +211,11 @@ func TestServeHTTPWithResponseHeaders(t *testing.T) {
 	defer backend.Close()
 
 	backendURL := mustParseURL(backend.URL)
-	config := ProxyConfig{
+	config := Config{
 		ListenAddr: ":8080",
 		TargetURL:  backendURL,
 	}
-	proxy, _ := NewProxy(config, nil)
+	proxy, _ := New(config)
 
 	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
 	w := httptest.NewRecorder()
@@ -251,11 +255,11 @@ func TestServeHTTPWithLargeBody(t *testing.T) {
 	defer backend.Close()
 
 	backendURL := mustParseURL(backend.URL)
-	config := ProxyConfig{
+	config := Config{
 		ListenAddr: ":8080",
 		TargetURL:  backendURL,
 	}
-	proxy, _ := NewProxy(config, nil)
+	proxy, _ := New(config)
 
 	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
 	w := httptest.NewRecorder()
@@ -283,11 +287,11 @@ func TestServeHTTPWithRedirect(t *testing.T) {
 	defer backend.Close()
 
 	backendURL := mustParseURL(backend.URL)
-	config := ProxyConfig{
+	config := Config{
 		ListenAddr: ":8080",
 		TargetURL:  backendURL,
 	}
-	proxy, _ := NewProxy(config, nil)
+	proxy, _ := New(config)
 
 	req := httptest.NewRequest("GET", "http://localhost:8080/redirect", nil)
 	w := httptest.NewRecorder()
@@ -314,3 +318,250 @@ func findFreePort(t *testing.T) string {
 	addr := listener.Addr().(*net.TCPAddr)
 	return fmt.Sprintf(":%d", addr.Port)
 }
+
+func TestServeHTTPRewriteRedirects(t *testing.T) {
+	var backendURL *url.URL
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/absolute":
+			w.Header().Set("Location", backendURL.String()+"/target?x=1")
+		case "/external":
+			w.Header().Set("Location", "https://other.example.com/target")
+		default:
+			w.Header().Set("Location", "/target")
+		}
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer backend.Close()
+	backendURL = mustParseURL(backend.URL)
+
+	tests := []struct {
+		name     string
+		rewrite  bool
+		path     string
+		expected string
+	}{
+		{
+			name:     "absolute backend location rewritten",
+			rewrite:  true,
+			path:     "/absolute",
+			expected: "http://proxy.example.com:8080/target?x=1",
+		},
+		{
+			name:     "external location untouched",
+			rewrite:  true,
+			path:     "/external",
+			expected: "https://other.example.com/target",
+		},
+		{
+			name:     "relative location untouched",
+			rewrite:  true,
+			path:     "/relative",
+			expected: "/target",
+		},
+		{
+			name:     "rewrite disabled keeps raw value",
+			rewrite:  false,
+			path:     "/absolute",
+			expected: backendURL.String() + "/target?x=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				ListenAddr:       ":8080",
+				TargetURL:        backendURL,
+				RewriteRedirects: tt.rewrite,
+			}
+			proxy, _ := New(config)
+
+			req := httptest.NewRequest("GET", "http://proxy.example.com:8080"+tt.path, nil)
+			w := httptest.NewRecorder()
+
+			proxy.ServeHTTP(w, req)
+
+			resp := w.Result()
+			if resp.StatusCode != http.StatusFound {
+				t.Errorf("expected status 302, got %d", resp.StatusCode)
+			}
+			if location := resp.Header.Get("Location"); location != tt.expected {
+				t.Errorf("expected Location %s, got %s", tt.expected, location)
+			}
+		})
+	}
+}
+
+func TestIntegrationServerSentEvents(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+
+		_, _ = fmt.Fprint(w, "data: first\n\n")
+		flusher.Flush()
+
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+			return
+		}
+
+		_, _ = fmt.Fprint(w, "data: second\n\n")
+		flusher.Flush()
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Timeout:    10 * time.Second,
+	})
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	resp, err := http.Get(proxyServer.URL + "/events")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// The first event must arrive while the backend is still holding the
+	// connection open, otherwise the proxy is buffering the stream
+	firstEvent := make(chan string, 1)
+	go func() {
+		buf := make([]byte, len("data: first\n\n"))
+		_, _ = io.ReadFull(resp.Body, buf)
+		firstEvent <- string(buf)
+	}()
+
+	select {
+	case event := <-firstEvent:
+		if event != "data: first\n\n" {
+			t.Errorf("unexpected first event: %q", event)
+		}
+	case <-time.After(2 * time.Second):
+		close(release)
+		t.Fatal("first event was not delivered before the stream completed")
+	}
+
+	close(release)
+
+	rest, _ := io.ReadAll(resp.Body)
+	if string(rest) != "data: second\n\n" {
+		t.Errorf("unexpected second event: %q", string(rest))
+	}
+}
+
+func TestIntegrationClientCancellationPropagates(t *testing.T) {
+	backendCancelled := make(chan struct{})
+	backendStarted := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(backendStarted)
+		select {
+		case <-r.Context().Done():
+			close(backendCancelled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+	})
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", proxyServer.URL+"/slow", nil)
+
+	go func() {
+		<-backendStarted
+		cancel()
+	}()
+
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("expected client request to be cancelled")
+	}
+
+	select {
+	case <-backendCancelled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("client cancellation did not propagate to the backend")
+	}
+}
+
+func TestServeHTTPZeroTimeoutAllowsSlowResponse(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte("finally"))
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Timeout:    0,
+	})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/slow", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "finally" {
+		t.Errorf("expected slow response to complete, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServeHTTPMaxResponseSize(t *testing.T) {
+	largeData := strings.Repeat("x", 64*1024)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(largeData))
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name          string
+		maxSize       int64
+		expectLength  int
+		expectWarning bool
+	}{
+		{"unlimited by default", 0, len(largeData), false},
+		{"truncated at cap", 1024, 1024, true},
+		{"cap larger than body", 1024 * 1024, len(largeData), false},
+		{"cap equal to body", int64(len(largeData)), len(largeData), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logBuf bytes.Buffer
+			proxy, _ := New(Config{
+				ListenAddr:      ":8080",
+				TargetURL:       mustParseURL(backend.URL),
+				MaxResponseSize: tt.maxSize,
+				Logger:          log.New(&logBuf, "", 0),
+			})
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/big", nil)
+			req.RemoteAddr = "192.0.2.7:4321"
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+
+			if w.Body.Len() != tt.expectLength {
+				t.Errorf("expected %d bytes, got %d", tt.expectLength, w.Body.Len())
+			}
+
+			logOutput := logBuf.String()
+			warned := strings.Contains(logOutput, "truncated")
+			if warned != tt.expectWarning {
+				t.Errorf("expected warning=%v, log output %q", tt.expectWarning, logOutput)
+			}
+			if warned && (!strings.Contains(logOutput, "192.0.2.7") || !strings.Contains(logOutput, "/big")) {
+				t.Errorf("expected warning to include client IP and path, got %q", logOutput)
+			}
+		})
+	}
+}
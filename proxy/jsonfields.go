@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxValidatedJSONBody caps how much of a request body is buffered to check
+// Config.RequiredJSONFields.
+const maxValidatedJSONBody = 10 << 20
+
+var errJSONBodyTooLarge = errors.New("request body too large to validate")
+
+// isJSONContentType reports whether a Content-Type names JSON, either
+// application/json or a structured "+json" type such as
+// application/merge-patch+json.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// missingJSONFields buffers a JSON request body and returns the
+// Config.RequiredJSONFields it lacks, putting the body back so it is
+// forwarded intact. Requests without a JSON Content-Type are not checked.
+func (p *Proxy) missingJSONFields(r *http.Request) ([]string, error) {
+	if len(p.config.RequiredJSONFields) == 0 || !isJSONContentType(r.Header.Get("Content-Type")) {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedJSONBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(body) > maxValidatedJSONBody {
+		return nil, errJSONBodyTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	var document any
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("request body is not valid JSON: %w", err)
+	}
+
+	var missing []string
+	for _, field := range p.config.RequiredJSONFields {
+		if !hasJSONField(document, field) {
+			missing = append(missing, field)
+		}
+	}
+	return missing, nil
+}
+
+// hasJSONField follows a dot-separated path such as "user.id" through
+// objects, with numeric segments indexing arrays ("items.0.sku"). A field
+// that is present with a null value counts as present.
+func hasJSONField(document any, path string) bool {
+	current := document
+	for _, segment := range strings.Split(path, ".") {
+		switch value := current.(type) {
+		case map[string]any:
+			next, ok := value[segment]
+			if !ok {
+				return false
+			}
+			current = next
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(value) {
+				return false
+			}
+			current = value[index]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// checkJSONFields answers 400 (or 413) and returns false when a JSON
+// request body lacks a required field or cannot be validated.
+func (p *Proxy) checkJSONFields(w http.ResponseWriter, r *http.Request) bool {
+	missing, err := p.missingJSONFields(r)
+	switch {
+	case errors.Is(err, errJSONBodyTooLarge):
+		p.logger.Printf("JSON body too large to validate for %s %s", r.Method, r.URL.Path)
+		p.writeError(w, "Request body too large to validate", http.StatusRequestEntityTooLarge)
+		return false
+	case err != nil:
+		p.logger.Printf("Rejecting %s %s: %v", r.Method, r.URL.Path, err)
+		p.writeError(w, "Request body is not valid JSON", http.StatusBadRequest)
+		return false
+	case len(missing) > 0:
+		p.logger.Printf("Rejecting %s %s: missing JSON fields %s", r.Method, r.URL.Path, strings.Join(missing, ", "))
+		p.writeError(w, "Missing required JSON fields: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
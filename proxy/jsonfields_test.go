@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPRequiredJSONFields(t *testing.T) {
+	var gotBody string
+	var gotLength int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		gotBody, gotLength = string(data), r.ContentLength
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:         ":8080",
+		TargetURL:          mustParseURL(backend.URL),
+		RequiredJSONFields: []string{"user.id", "items.0.sku"},
+		Logger:             log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		expectStatus  int
+		expectMessage string
+	}{
+		{"fields present", "application/json", `{"user": {"id": 7}, "items": [{"sku": "a1"}]}`, http.StatusOK, ""},
+		{"null counts as present", "application/json; charset=utf-8", `{"user": {"id": null}, "items": [{"sku": null}]}`, http.StatusOK, ""},
+		{"structured json type", "application/merge-patch+json", `{"user": {"id": 7}, "items": [{"sku": "a1"}]}`, http.StatusOK, ""},
+		{"one field missing", "application/json", `{"user": {"name": "ann"}, "items": [{"sku": "a1"}]}`, http.StatusBadRequest, "Missing required JSON fields: user.id"},
+		{"all fields missing", "application/json", `{"items": []}`, http.StatusBadRequest, "Missing required JSON fields: user.id, items.0.sku"},
+		{"wrong shape", "application/json", `{"user": "7", "items": [{"id": "a1"}]}`, http.StatusBadRequest, "Missing required JSON fields: user.id, items.0.sku"},
+		{"invalid json", "application/json", `{"user": `, http.StatusBadRequest, "Request body is not valid JSON"},
+		{"non-json body is not checked", "text/plain", "just text", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBody, gotLength = "", 0
+			req := httptest.NewRequest("POST", "http://localhost:8080/orders", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != tt.expectStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectStatus, w.Code, w.Body.String())
+			}
+			if tt.expectStatus != http.StatusOK {
+				if !strings.Contains(w.Body.String(), tt.expectMessage) {
+					t.Errorf("expected message %q, got %q", tt.expectMessage, w.Body.String())
+				}
+				if gotBody != "" {
+					t.Error("expected the backend not to be contacted")
+				}
+				return
+			}
+			// The validated body must still reach the backend untouched
+			if gotBody != tt.body || gotLength != int64(len(tt.body)) {
+				t.Errorf("expected body %q (%d bytes) at the backend, got %q (%d)", tt.body, len(tt.body), gotBody, gotLength)
+			}
+		})
+	}
+}
+
+func TestNewInvalidRequiredJSONField(t *testing.T) {
+	for _, field := range []string{"", "user.", ".id", "a..b"} {
+		_, err := New(Config{
+			ListenAddr:         ":8080",
+			TargetURL:          mustParseURL("http://localhost:3000"),
+			RequiredJSONFields: []string{field},
+		})
+		if err == nil {
+			t.Errorf("expected error for field %q", field)
+		}
+	}
+}
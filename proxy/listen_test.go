@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeMultipleListeners(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Forwarded-Port")))
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddrs: []string{"127.0.0.1:0", "127.0.0.1:0"},
+		TargetURL:   mustParseURL(backend.URL),
+		Logger:      log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	internal, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	external, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- proxy.Serve(internal, external) }()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	for _, listener := range []net.Listener{internal, external} {
+		resp, err := client.Get("http://" + listener.Addr().String() + "/")
+		if err != nil {
+			t.Fatalf("request to %s failed: %v", listener.Addr(), err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+		if string(body) != port {
+			t.Errorf("expected X-Forwarded-Port %s, got %q", port, body)
+		}
+	}
+
+	// Losing one listener shuts the other server down as well
+	_ = internal.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected the listener failure to be returned")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after a listener failed")
+	}
+
+	if _, err := client.Get("http://" + external.Addr().String() + "/"); err == nil {
+		t.Error("expected the remaining listener to be closed")
+	}
+}
+
+func TestStartFailsWhenAnyAddressCannotBind(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = taken.Close() }()
+
+	proxy, err := New(Config{
+		ListenAddrs: []string{"127.0.0.1:0", taken.Addr().String()},
+		TargetURL:   mustParseURL("http://localhost:3000"),
+		Logger:      log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = proxy.Start()
+	if err == nil {
+		t.Fatal("expected error when an address is already in use")
+	}
+	if !strings.Contains(err.Error(), taken.Addr().String()) {
+		t.Errorf("expected error to name %s, got %v", taken.Addr(), err)
+	}
+}
+
+func TestServeMaxHeaderBytes(t *testing.T) {
+	var calls int
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:     "127.0.0.1:0",
+		TargetURL:      mustParseURL(backend.URL),
+		MaxHeaderBytes: 8 << 10,
+		Logger:         log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() { _ = proxy.Serve(listener) }()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	send := func(headerSize int) int {
+		req, _ := http.NewRequest("GET", "http://"+listener.Addr().String()+"/", nil)
+		req.Header.Set("X-Padding", strings.Repeat("a", headerSize))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := send(1 << 10); status != http.StatusOK {
+		t.Errorf("expected small headers to be accepted, got %d", status)
+	}
+	if status := send(64 << 10); status != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("expected status 431 for oversized headers, got %d", status)
+	}
+	if calls != 1 {
+		t.Errorf("expected only the small request to reach the backend, got %d calls", calls)
+	}
+}
+
+func TestNewMaxHeaderBytes(t *testing.T) {
+	tests := []struct {
+		configured int
+		expected   int
+	}{
+		{0, DefaultMaxHeaderBytes},
+		{64 << 10, 64 << 10},
+		{100, MinMaxHeaderBytes},
+		{1 << 30, MaxMaxHeaderBytes},
+	}
+
+	for _, tt := range tests {
+		proxy, err := New(Config{
+			ListenAddr:     ":8080",
+			TargetURL:      mustParseURL("http://localhost:3000"),
+			MaxHeaderBytes: tt.configured,
+			Logger:         log.New(io.Discard, "", 0),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := proxy.newServer().MaxHeaderBytes; got != tt.expected {
+			t.Errorf("MaxHeaderBytes %d: expected %d, got %d", tt.configured, tt.expected, got)
+		}
+	}
+
+	if _, err := New(Config{ListenAddr: ":8080", TargetURL: mustParseURL("http://localhost:3000"), MaxHeaderBytes: -1}); err == nil {
+		t.Error("expected error for negative maximum header bytes")
+	}
+}
+
+func TestNewServerLeavesWritesToRequestDeadline(t *testing.T) {
+	for _, timeout := range []time.Duration{0, 30 * time.Second} {
+		proxy, _ := New(Config{
+			ListenAddr: ":8080",
+			TargetURL:  mustParseURL("http://localhost:3000"),
+			Timeout:    timeout,
+			Logger:     log.New(io.Discard, "", 0),
+		})
+		// A fixed WriteTimeout would cut off backends slower than it
+		if got := proxy.newServer().WriteTimeout; got != 0 {
+			t.Errorf("timeout %v: expected no server write timeout, got %v", timeout, got)
+		}
+	}
+}
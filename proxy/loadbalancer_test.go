@@ -0,0 +1,413 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeightedSelectorDistribution(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []int
+	}{
+		{"equal weights", []int{1, 1}},
+		{"three to one", []int{3, 1}},
+		{"uneven three way", []int{5, 2, 1}},
+		{"drained backend", []int{2, 0, 1}},
+	}
+
+	const requests = 8000
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var backends []*Backend
+			total := 0
+			for i, w := range tt.weights {
+				backends = append(backends, &Backend{URL: mustParseURL("http://backend" + string(rune('a'+i))), Weight: w})
+				total += w
+			}
+
+			selector := newWeightedSelector()
+			counts := make(map[*Backend]int)
+			req := httptest.NewRequest("GET", "/", nil)
+			for i := 0; i < requests; i++ {
+				counts[selector.Select(req, backends)]++
+			}
+
+			for _, backend := range backends {
+				expected := float64(requests) * float64(backend.Weight) / float64(total)
+				got := float64(counts[backend])
+				if backend.Weight == 0 && got != 0 {
+					t.Errorf("drained backend %s was selected %v times", backend.URL, got)
+				}
+				if math.Abs(got-expected) > float64(requests)*0.02 {
+					t.Errorf("backend %s (weight %d): expected ~%.0f selections, got %.0f", backend.URL, backend.Weight, expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedSelectorSmooth(t *testing.T) {
+	a := &Backend{URL: mustParseURL("http://a"), Weight: 2}
+	b := &Backend{URL: mustParseURL("http://b"), Weight: 1}
+	selector := newWeightedSelector()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	var sequence []string
+	for i := 0; i < 6; i++ {
+		sequence = append(sequence, selector.Select(req, []*Backend{a, b}).URL.Host)
+	}
+
+	// Smooth selection interleaves instead of sending a burst to "a"
+	if got := strings.Join(sequence, ","); got != "a,b,a,a,b,a" {
+		t.Errorf("unexpected selection order %s", got)
+	}
+}
+
+func TestWeightedSelectorAllDrained(t *testing.T) {
+	selector := newWeightedSelector()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	if backend := selector.Select(req, []*Backend{{URL: mustParseURL("http://a"), Weight: 0}}); backend != nil {
+		t.Errorf("expected no backend, got %s", backend.URL)
+	}
+	if backend := selector.Select(req, nil); backend != nil {
+		t.Errorf("expected no backend for empty pool, got %s", backend.URL)
+	}
+}
+
+func TestLeastConnSelector(t *testing.T) {
+	a := &Backend{URL: mustParseURL("http://a"), Weight: 1}
+	b := &Backend{URL: mustParseURL("http://b"), Weight: 5}
+	drained := &Backend{URL: mustParseURL("http://drained"), Weight: 0}
+	candidates := []*Backend{a, b, drained}
+	selector := newLeastConnSelector()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	// Idle backends take turns regardless of weight
+	var sequence []string
+	for i := 0; i < 4; i++ {
+		sequence = append(sequence, selector.Select(req, candidates).URL.Host)
+	}
+	if got := strings.Join(sequence, ","); got != "a,b,a,b" {
+		t.Errorf("expected ties to alternate, got %s", got)
+	}
+
+	selector.acquire(a)
+	for i := 0; i < 3; i++ {
+		if backend := selector.Select(req, candidates); backend != b {
+			t.Fatalf("expected the idle backend b while a is busy, got %s", backend.URL)
+		}
+	}
+
+	selector.release(a)
+	if len(selector.active) != 0 {
+		t.Errorf("expected released backends to be forgotten, got %v", selector.active)
+	}
+	if backend := selector.Select(req, []*Backend{drained}); backend != nil {
+		t.Errorf("expected no backend when all are drained, got %s", backend.URL)
+	}
+}
+
+func TestP2CSelectorAvoidsBusiestBackend(t *testing.T) {
+	a := &Backend{URL: mustParseURL("http://a"), Weight: 1}
+	b := &Backend{URL: mustParseURL("http://b"), Weight: 1}
+	c := &Backend{URL: mustParseURL("http://c"), Weight: 1}
+	drained := &Backend{URL: mustParseURL("http://drained"), Weight: 0}
+	candidates := []*Backend{a, b, c, drained}
+	selector := newP2CSelector()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	// a is far busier than the others, c slightly busier than b
+	for i := 0; i < 10; i++ {
+		selector.acquire(a)
+	}
+	selector.acquire(c)
+
+	counts := make(map[*Backend]int)
+	for i := 0; i < 3000; i++ {
+		counts[selector.Select(req, candidates)]++
+	}
+	if counts[a] != 0 || counts[drained] != 0 {
+		t.Errorf("expected the busiest and drained backends to be avoided, got a=%d drained=%d", counts[a], counts[drained])
+	}
+	// b wins whenever it is drawn, c only when paired with a
+	if counts[b] <= counts[c] || counts[c] == 0 {
+		t.Errorf("expected b to be chosen most and c sometimes, got b=%d c=%d", counts[b], counts[c])
+	}
+
+	if backend := selector.Select(req, []*Backend{a, drained}); backend != a {
+		t.Errorf("expected the only eligible backend regardless of load, got %v", backend)
+	}
+	if backend := selector.Select(req, []*Backend{drained}); backend != nil {
+		t.Errorf("expected no backend when all are drained, got %s", backend.URL)
+	}
+}
+
+func TestP2CSelectorWeights(t *testing.T) {
+	heavy := &Backend{URL: mustParseURL("http://heavy"), Weight: 9}
+	light := &Backend{URL: mustParseURL("http://light"), Weight: 1}
+	other := &Backend{URL: mustParseURL("http://other"), Weight: 1}
+	selector := newP2CSelector()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	// With equal load the first draw wins, so weights decide the share
+	counts := make(map[*Backend]int)
+	const requests = 6000
+	for i := 0; i < requests; i++ {
+		counts[selector.Select(req, []*Backend{heavy, light, other})]++
+	}
+	if counts[heavy] < requests*3/4 {
+		t.Errorf("expected the weight 9 backend to take most requests, got %v of %d", counts[heavy], requests)
+	}
+}
+
+func TestParseBackend(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expectURL   string
+		expectW     int
+		expectError bool
+	}{
+		{"plain URL", "http://a:8080", "http://a:8080", 1, false},
+		{"weighted", "https://a:80=3", "https://a:80", 3, false},
+		{"zero weight", "http://a:8080=0", "http://a:8080", 0, false},
+		{"equals in query", "http://a:8080/?x=y", "http://a:8080/?x=y", 1, false},
+		{"number in query", "http://b:80/api?shard=3", "http://b:80/api?shard=3", 1, false},
+		{"number in last query parameter", "http://b:80/api?region=eu&shard=3", "http://b:80/api?region=eu&shard=3", 1, false},
+		{"weighted with query", "http://b:80/api?shard=3=2", "http://b:80/api?shard=3", 2, false},
+		{"drained with query", "http://b:80/api?shard=3=0", "http://b:80/api?shard=3", 0, false},
+		{"negative weight", "http://a:8080=-1", "", 0, true},
+		{"missing scheme", "a:8080=2", "", 0, true},
+		{"empty", "", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := ParseBackend(tt.value)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if backend.URL.String() != tt.expectURL || backend.Weight != tt.expectW {
+				t.Errorf("expected %s weight %d, got %s weight %d", tt.expectURL, tt.expectW, backend.URL, backend.Weight)
+			}
+		})
+	}
+}
+
+func TestServeHTTPWeightedBackends(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		}))
+	}
+	a := newBackend("a")
+	defer a.Close()
+	b := newBackend("b")
+	defer b.Close()
+	drained := newBackend("drained")
+	defer drained.Close()
+
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		Backends: []Backend{
+			{URL: mustParseURL(a.URL), Weight: 3},
+			{URL: mustParseURL(b.URL), Weight: 1},
+			{URL: mustParseURL(drained.URL), Weight: 0},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 40; i++ {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		counts[w.Body.String()]++
+	}
+
+	if counts["a"] != 30 || counts["b"] != 10 || counts["drained"] != 0 {
+		t.Errorf("expected 30/10/0 split, got %v", counts)
+	}
+}
+
+func TestServeHTTPLeastConnFavorsIdleBackend(t *testing.T) {
+	slowArrived := make(chan struct{}, 10)
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slowArrived <- struct{}{}
+		<-release
+		_, _ = w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:    ":8080",
+		LoadBalancing: LoadBalancingLeastConn,
+		Backends: []Backend{
+			{URL: mustParseURL(slow.URL), Weight: 1},
+			{URL: mustParseURL(fast.URL), Weight: 1},
+		},
+		Logger: log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Each request is sent once the previous one either finished on the
+	// fast backend or is stuck on the slow one
+	const requests = 10
+	slowCount, fastCount := 0, 0
+	for i := 0; i < requests; i++ {
+		done := make(chan string, 1)
+		go func() {
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			done <- w.Body.String()
+		}()
+
+		select {
+		case <-slowArrived:
+			slowCount++
+		case body := <-done:
+			if body != "fast" {
+				t.Fatalf("expected a response from the fast backend, got %q", body)
+			}
+			fastCount++
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the request")
+		}
+	}
+
+	// Round-robin would have parked half of them on the slow backend
+	if slowCount != 1 || fastCount != requests-1 {
+		t.Errorf("expected 1 request on the slow backend and %d on the fast one, got %d and %d", requests-1, slowCount, fastCount)
+	}
+}
+
+func TestNewRejectsUnknownLoadBalancing(t *testing.T) {
+	_, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL("http://example.com"),
+		LoadBalancing: "fastest",
+	})
+	if err == nil {
+		t.Fatal("expected error for an unknown load balancing algorithm")
+	}
+}
+
+func TestNewLoadBalancingNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect BackendSelector
+	}{
+		{"", &weightedSelector{}},
+		{"round-robin", &weightedSelector{}},
+		{"roundrobin", &weightedSelector{}},
+		{"weighted", &weightedSelector{}},
+		{"random", &randomSelector{}},
+		{"least-conn", &leastConnSelector{}},
+		{"leastconn", &leastConnSelector{}},
+		{"p2c", &p2cSelector{}},
+		{"consistent-hash", &consistentHashSelector{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, err := New(Config{
+				ListenAddr:    ":8080",
+				Backends:      []Backend{{URL: mustParseURL("http://a"), Weight: 1}},
+				LoadBalancing: tt.name,
+			})
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			if got, want := fmt.Sprintf("%T", proxy.selector), fmt.Sprintf("%T", tt.expect); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestRandomSelectorDistribution(t *testing.T) {
+	a := &Backend{URL: mustParseURL("http://a"), Weight: 3}
+	b := &Backend{URL: mustParseURL("http://b"), Weight: 1}
+	drained := &Backend{URL: mustParseURL("http://drained"), Weight: 0}
+	selector := newRandomSelector()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	const requests = 8000
+	counts := make(map[*Backend]int)
+	for i := 0; i < requests; i++ {
+		counts[selector.Select(req, []*Backend{a, b, drained})]++
+	}
+	if counts[drained] != 0 {
+		t.Errorf("drained backend was selected %d times", counts[drained])
+	}
+	if got := float64(counts[a]) / requests; math.Abs(got-0.75) > 0.03 {
+		t.Errorf("expected the weight 3 backend to take ~75%% of requests, got %.1f%%", got*100)
+	}
+	if backend := selector.Select(req, []*Backend{drained}); backend != nil {
+		t.Errorf("expected no backend when all are drained, got %s", backend.URL)
+	}
+}
+
+func TestServeHTTPSkipsUnhealthyBackends(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("healthy"))
+	}))
+	defer healthy.Close()
+
+	down := mustParseURL("http://127.0.0.1:1")
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		Backends: []Backend{
+			{URL: mustParseURL(healthy.URL), Weight: 1},
+			{URL: down, Weight: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxy.health = newHealthChecker(proxy.backendURLs(), "/", 0, proxy.httpClient, proxy.logger)
+	proxy.health.setHealthy(down, false)
+
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "healthy" {
+			t.Fatalf("expected healthy backend, got %d %q", w.Code, w.Body.String())
+		}
+	}
+
+	proxy.health.setHealthy(mustParseURL(healthy.URL), false)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 with every backend down, got %d", w.Code)
+	}
+}
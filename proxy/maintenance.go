@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaintenanceRetryAfter is the Retry-After sent with the maintenance page.
+const MaintenanceRetryAfter = 5 * time.Minute
+
+// LoadMaintenancePage reads the page served in maintenance mode, typed by
+// its file extension, so a missing file is reported before the proxy starts.
+func LoadMaintenancePage(path string) (*ErrorPage, error) {
+	page, err := readPage(path, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maintenance page: %w", err)
+	}
+	return &page, nil
+}
+
+// EnterMaintenance puts the proxy into maintenance mode: every request is
+// answered with 503 and the maintenance page, without reaching a backend.
+// ExitMaintenance reverses it.
+func (p *Proxy) EnterMaintenance() {
+	if !p.maintenance.Swap(true) {
+		p.logger.Printf("Maintenance mode: serving the maintenance page to all requests")
+	}
+}
+
+// ExitMaintenance takes the proxy out of maintenance mode so requests reach
+// the backends again.
+func (p *Proxy) ExitMaintenance() {
+	if p.maintenance.Swap(false) {
+		p.logger.Printf("Maintenance mode over: forwarding requests")
+	}
+}
+
+// InMaintenance reports whether the proxy is serving the maintenance page.
+func (p *Proxy) InMaintenance() bool {
+	return p.maintenance.Load()
+}
+
+func (p *Proxy) serveMaintenance(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(MaintenanceRetryAfter.Seconds())))
+	w.Header().Set("Cache-Control", "no-store")
+
+	page := p.config.MaintenancePage
+	if page == nil {
+		http.Error(w, "Service under maintenance", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", page.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write(page.Body)
+}
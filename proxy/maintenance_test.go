@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestServeHTTPMaintenancePage(t *testing.T) {
+	var backendHits atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendHits.Add(1)
+		_, _ = w.Write([]byte("from backend"))
+	}))
+	defer backend.Close()
+
+	path := filepath.Join(t.TempDir(), "maintenance.html")
+	const html = "<h1>Back soon</h1>"
+	if err := os.WriteFile(path, []byte(html), 0o600); err != nil {
+		t.Fatalf("failed to write maintenance page: %v", err)
+	}
+	page, err := LoadMaintenancePage(path)
+	if err != nil {
+		t.Fatalf("LoadMaintenancePage failed: %v", err)
+	}
+
+	proxy, err := New(Config{
+		ListenAddr:      ":8080",
+		TargetURL:       mustParseURL(backend.URL),
+		SelfHealthPath:  "/healthz",
+		Maintenance:     true,
+		MaintenancePage: page,
+		Logger:          log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for _, path := range []string{"/", "/api/users"} {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080"+path, nil))
+		if w.Code != http.StatusServiceUnavailable || w.Body.String() != html {
+			t.Errorf("expected the maintenance page with 503 for %s, got %d %q", path, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Retry-After") != "300" {
+			t.Errorf("expected Retry-After: 300, got %q", w.Header().Get("Retry-After"))
+		}
+		if got := w.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+			t.Errorf("expected an HTML content type, got %q", got)
+		}
+	}
+	if backendHits.Load() != 0 {
+		t.Errorf("expected the backend not to be contacted, got %d requests", backendHits.Load())
+	}
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the self-health endpoint to keep answering 200, got %d", w.Code)
+	}
+
+	// The admin API switches maintenance off and on again
+	admin := proxy.AdminHandler()
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest("POST", "/maintenance/off", nil))
+	if w.Code != http.StatusOK || proxy.InMaintenance() {
+		t.Fatalf("expected POST /maintenance/off to end maintenance, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "from backend" {
+		t.Errorf("expected requests to be forwarded after maintenance, got %d %q", w.Code, w.Body.String())
+	}
+
+	admin.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/maintenance/on", nil))
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != html {
+		t.Errorf("expected the maintenance page again, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestLoadMaintenancePageMissingFile(t *testing.T) {
+	if _, err := LoadMaintenancePage(filepath.Join(t.TempDir(), "missing.html")); err == nil {
+		t.Error("expected a missing maintenance page to be reported")
+	}
+}
@@ -0,0 +1,21 @@
+package proxy
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// methodAllowed reports whether method may be forwarded. With no
+// Config.AllowedMethods every method is allowed.
+func (p *Proxy) methodAllowed(method string) bool {
+	return len(p.config.AllowedMethods) == 0 || slices.Contains(p.config.AllowedMethods, method)
+}
+
+// rejectMethod answers 405 with the Allow header listing what the proxy
+// accepts, as RFC 9110 section 15.5.6 requires.
+func (p *Proxy) rejectMethod(w http.ResponseWriter, r *http.Request) {
+	p.logger.Printf("Method %s not allowed for %s", r.Method, r.URL.Path)
+	w.Header().Set("Allow", strings.Join(p.config.AllowedMethods, ", "))
+	p.writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestServeHTTPAllowedMethods(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:     ":8080",
+		TargetURL:      mustParseURL(backend.URL),
+		AllowedMethods: []string{"GET", "HEAD"},
+		Logger:         log.New(io.Discard, "", 0),
+	})
+
+	tests := []struct {
+		method       string
+		expectStatus int
+	}{
+		{"GET", http.StatusOK},
+		{"HEAD", http.StatusOK},
+		{"POST", http.StatusMethodNotAllowed},
+		{"DELETE", http.StatusMethodNotAllowed},
+		{"get", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			calls.Store(0)
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest(tt.method, "http://localhost:8080/items", nil))
+
+			if w.Code != tt.expectStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectStatus, w.Code)
+			}
+			if tt.expectStatus == http.StatusMethodNotAllowed {
+				if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+					t.Errorf("expected Allow header %q, got %q", "GET, HEAD", allow)
+				}
+				if calls.Load() != 0 {
+					t.Error("expected the backend not to be contacted")
+				}
+			} else if calls.Load() != 1 {
+				t.Errorf("expected 1 backend call, got %d", calls.Load())
+			}
+		})
+	}
+}
+
+func TestServeHTTPAllMethodsAllowedByDefault(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	for _, method := range []string{"GET", "POST", "PATCH", "PROPFIND"} {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(method, "http://localhost:8080/", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected %s to be forwarded, got %d", method, w.Code)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// maxMirrorInFlight bounds outstanding mirror requests so a slow mirror
+	// cannot pile up goroutines; requests beyond it are simply not mirrored.
+	maxMirrorInFlight = 100
+
+	// defaultMirrorBuffer is how far, in bytes, a mirror may fall behind the
+	// primary backend while both read the same request body before it is
+	// cut off.
+	defaultMirrorBuffer = 1 << 20
+
+	defaultMirrorTimeout = 30 * time.Second
+)
+
+var (
+	errMirrorTooSlow       = errors.New("mirror fell too far behind the primary backend")
+	errMirrorBodyAbandoned = errors.New("primary request ended before its body was read")
+)
+
+// mirror sends copies of a sample of requests to a shadow backend. Its
+// responses are discarded and its failures only logged, so clients never see
+// a difference.
+type mirror struct {
+	target  *url.URL
+	percent float64
+	timeout time.Duration
+	random  func() float64
+	slots   chan struct{}
+	buffer  int
+	wg      sync.WaitGroup
+}
+
+func newMirror(target *url.URL, percent float64, timeout time.Duration) *mirror {
+	if timeout == 0 {
+		timeout = defaultMirrorTimeout
+	}
+	return &mirror{
+		target:  target,
+		percent: percent,
+		timeout: timeout,
+		random:  rand.Float64,
+		slots:   make(chan struct{}, maxMirrorInFlight),
+		buffer:  defaultMirrorBuffer,
+	}
+}
+
+// sample reports whether this request should be mirrored.
+func (m *mirror) sample() bool {
+	return m.percent >= 100 || m.random()*100 < m.percent
+}
+
+// wait blocks until all in-flight mirror requests have finished.
+func (m *mirror) wait() {
+	m.wg.Wait()
+}
+
+// mirrorRequest fires a copy of req at the mirror target in the background.
+// A request body is not buffered: it streams to the mirror as the primary
+// backend reads it, so the caller must close req.Body once the primary
+// request is done, which ends the mirror's copy if it was never read.
+func (p *Proxy) mirrorRequest(r *http.Request, req *http.Request, backend *Backend) {
+	select {
+	case p.mirror.slots <- struct{}{}:
+	default:
+		p.logger.Printf("Mirror busy, not mirroring %s %s", r.Method, r.URL.Path)
+		return
+	}
+
+	// The copy must outlive the client request, so it gets its own context
+	ctx, cancel := context.WithTimeout(context.Background(), p.mirror.timeout)
+	mirrorReq, err := http.NewRequestWithContext(ctx, req.Method, p.buildTargetURL(r, p.mirror.target).String(), nil)
+	if err != nil {
+		cancel()
+		<-p.mirror.slots
+		p.logger.Printf("Error creating mirror request: %v", err)
+		return
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		pipe := newMirrorPipe(p.mirror.buffer)
+		req.Body = &teeBody{ReadCloser: req.Body, pipe: pipe}
+		mirrorReq.Body = pipe
+		mirrorReq.ContentLength = req.ContentLength
+	}
+	mirrorReq.Header = req.Header.Clone()
+	backend.removeHeaders(mirrorReq.Header)
+	setURLCredentials(mirrorReq, p.mirror.target)
+	if p.config.PreserveHost {
+		mirrorReq.Host = req.Host
+	}
+
+	p.mirror.wg.Add(1)
+	go func() {
+		defer p.mirror.wg.Done()
+		defer func() { <-p.mirror.slots }()
+		defer cancel()
+
+		resp, err := p.httpClient.Do(mirrorReq)
+		if err != nil {
+			p.logger.Printf("Mirror request failed: %v", err)
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		p.logger.Printf("Mirrored %s %s -> %d", mirrorReq.Method, mirrorReq.URL.Path, resp.StatusCode)
+	}()
+}
+
+// teeBody copies everything the primary backend reads from a request body
+// into the mirror's pipe.
+type teeBody struct {
+	io.ReadCloser
+	pipe *mirrorPipe
+}
+
+func (b *teeBody) Read(data []byte) (int, error) {
+	n, err := b.ReadCloser.Read(data)
+	if n > 0 {
+		_, _ = b.pipe.Write(data[:n])
+	}
+	if err != nil {
+		b.pipe.closeWithError(err)
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	// A no-op when the body was read to the end
+	b.pipe.closeWithError(errMirrorBodyAbandoned)
+	return b.ReadCloser.Close()
+}
+
+// mirrorPipe hands a request body to the mirror through a bounded buffer.
+// Writes never block: when the mirror has not kept up and the buffer would
+// overflow, the mirror's copy is cut off instead of slowing the client.
+type mirrorPipe struct {
+	mu    sync.Mutex
+	ready *sync.Cond
+	buf   bytes.Buffer
+	limit int
+	// err ends the stream once buf is drained: io.EOF after the whole body
+	// or the reason the mirror's copy was cut short
+	err error
+}
+
+func newMirrorPipe(limit int) *mirrorPipe {
+	pipe := &mirrorPipe{limit: limit}
+	pipe.ready = sync.NewCond(&pipe.mu)
+	return pipe
+}
+
+func (p *mirrorPipe) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return len(data), nil
+	}
+	if p.buf.Len()+len(data) > p.limit {
+		p.err = errMirrorTooSlow
+		p.buf.Reset()
+	} else {
+		p.buf.Write(data)
+	}
+	p.ready.Broadcast()
+	return len(data), nil
+}
+
+func (p *mirrorPipe) Read(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.buf.Len() == 0 && p.err == nil {
+		p.ready.Wait()
+	}
+	if p.buf.Len() > 0 {
+		return p.buf.Read(data)
+	}
+	return 0, p.err
+}
+
+// Close is called by the mirror's transport; later writes are discarded.
+func (p *mirrorPipe) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = io.ErrClosedPipe
+	p.buf.Reset()
+	p.ready.Broadcast()
+	return nil
+}
+
+func (p *mirrorPipe) closeWithError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err == nil {
+		p.err = err
+		p.ready.Broadcast()
+	}
+}
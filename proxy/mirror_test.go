@@ -0,0 +1,298 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServeHTTPMirrorsRequest(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte("primary:" + string(body)))
+	}))
+	defer primary.Close()
+
+	var mu sync.Mutex
+	var mirroredPath, mirroredBody, mirroredHeader string
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		mirroredPath, mirroredBody, mirroredHeader = r.URL.Path, string(body), r.Header.Get("X-Test")
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("shadow response must be discarded"))
+	}))
+	defer shadow.Close()
+
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(primary.URL),
+		MirrorURL:  mustParseURL(shadow.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/orders", strings.NewReader("payload"))
+	req.Header.Set("X-Test", "yes")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	proxy.mirror.wait()
+
+	if w.Code != http.StatusOK || w.Body.String() != "primary:payload" {
+		t.Errorf("expected primary response, got %d %q", w.Code, w.Body.String())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if mirroredPath != "/orders" || mirroredBody != "payload" || mirroredHeader != "yes" {
+		t.Errorf("unexpected mirrored request: path=%q body=%q header=%q", mirroredPath, mirroredBody, mirroredHeader)
+	}
+}
+
+func TestServeHTTPMirrorFailureDoesNotAffectClient(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer primary.Close()
+
+	// Nothing listens here, so every mirror request fails to connect
+	shadowURL := "http://127.0.0.1" + findFreePort(t)
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(primary.URL),
+		MirrorURL:  mustParseURL(shadowURL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	proxy.mirror.wait()
+
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("expected primary response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServeHTTPMirrorSkipsRequestsRejectedByBreaker(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+
+	var mu sync.Mutex
+	mirrored := 0
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		mirrored++
+		mu.Unlock()
+	}))
+	defer shadow.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:       ":8080",
+		TargetURL:        mustParseURL(primary.URL),
+		MirrorURL:        mustParseURL(shadow.URL),
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Hour,
+		BreakerCount5xx:  true,
+		Logger:           log.New(io.Discard, "", 0),
+	})
+
+	codes := make([]int, 2)
+	for i := range codes {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+		codes[i] = w.Code
+	}
+	proxy.mirror.wait()
+
+	if codes[0] != http.StatusInternalServerError || codes[1] != http.StatusServiceUnavailable {
+		t.Fatalf("expected 500 then 503 from the open breaker, got %v", codes)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if mirrored != 1 {
+		t.Errorf("expected only the request let through to be mirrored, got %d mirrored", mirrored)
+	}
+}
+
+func TestServeHTTPMirrorIsAsynchronous(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer primary.Close()
+
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer shadow.Close()
+	defer close(release)
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(primary.URL),
+		MirrorURL:  mustParseURL(shadow.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	done := make(chan struct{})
+	go func() {
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost:8080/", nil))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client response waited for the mirror")
+	}
+}
+
+func TestServeHTTPMirrorStreamsLargeBody(t *testing.T) {
+	body := make([]byte, 8<<20)
+	_, _ = rand.Read(body)
+	want := sha256.Sum256(body)
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hash := sha256.New()
+		_, _ = io.Copy(hash, r.Body)
+		_, _ = w.Write(hash.Sum(nil))
+	}))
+	defer primary.Close()
+
+	var mu sync.Mutex
+	var mirrored []byte
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hash := sha256.New()
+		_, _ = io.Copy(hash, r.Body)
+		mu.Lock()
+		mirrored = hash.Sum(nil)
+		mu.Unlock()
+	}))
+	defer shadow.Close()
+
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(primary.URL),
+		MirrorURL:  mustParseURL(shadow.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Loopback socket buffers let the primary race megabytes ahead while
+	// the mirror is still connecting; the cut-off is tested separately
+	proxy.mirror.buffer = len(body)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/upload", "application/octet-stream", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	proxy.mirror.wait()
+
+	if !bytes.Equal(got, want[:]) {
+		t.Error("primary backend did not receive the whole body")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !bytes.Equal(mirrored, want[:]) {
+		t.Error("mirror did not receive the whole body")
+	}
+}
+
+func TestServeHTTPMirrorCutOffWhenTooSlow(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), 32<<20)
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		fmt.Fprint(w, n)
+	}))
+	defer primary.Close()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var mirrored int64 = -1
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		n, _ := io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		mirrored = n
+		mu.Unlock()
+	}))
+	defer shadow.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(primary.URL),
+		MirrorURL:  mustParseURL(shadow.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	proxy.mirror.buffer = 64 << 10
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost:8080/upload", bytes.NewReader(body)))
+		done <- w
+	}()
+
+	select {
+	case w := <-done:
+		if w.Code != http.StatusOK || w.Body.String() != fmt.Sprint(len(body)) {
+			t.Errorf("expected the primary to get the whole body, got %d %q", w.Code, w.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("client waited for the stalled mirror")
+	}
+
+	close(release)
+	proxy.mirror.wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if mirrored >= int64(len(body)) {
+		t.Errorf("expected the mirror's copy to be cut off, it got %d bytes", mirrored)
+	}
+}
+
+func TestMirrorSample(t *testing.T) {
+	m := newMirror(mustParseURL("http://shadow.example.com"), 25, 0)
+
+	m.random = func() float64 { return 0.2 }
+	if !m.sample() {
+		t.Error("expected request below the percentage to be mirrored")
+	}
+
+	m.random = func() float64 { return 0.3 }
+	if m.sample() {
+		t.Error("expected request above the percentage not to be mirrored")
+	}
+}
+
+func TestNewInvalidMirrorPercent(t *testing.T) {
+	_, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL("http://example.com"),
+		MirrorURL:     mustParseURL("http://shadow.example.com"),
+		MirrorPercent: 150,
+	})
+	if err == nil {
+		t.Fatal("expected error for mirror percentage above 100")
+	}
+}
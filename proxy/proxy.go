@@ -0,0 +1,1695 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http/httpguts"
+)
+
+// Version is reported in the Via header and tracing resource. Release
+// builds override it with -ldflags "-X .../proxy.Version=...".
+var Version = "1.0.0"
+
+// Backend connection pool defaults. The per-host idle limit keeps one busy
+// backend from holding most of the shared idle pool.
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 10
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// Limits for Config.MaxHeaderBytes. Zero uses net/http's default of 1 MB;
+// other values are clamped to this range, since a tiny limit rejects
+// ordinary browsers and a huge one invites memory exhaustion.
+const (
+	DefaultMaxHeaderBytes = http.DefaultMaxHeaderBytes
+	MinMaxHeaderBytes     = 4 << 10
+	MaxMaxHeaderBytes     = 16 << 20
+)
+
+// DefaultExpectContinueTimeout is how long the proxy waits for a backend's
+// 100 Continue before sending a request body anyway.
+const DefaultExpectContinueTimeout = 1 * time.Second
+
+// Config holds everything New needs to build a Proxy.
+type Config struct {
+	ListenAddr string
+	// ListenAddrs, when set, are all served by Start instead of ListenAddr
+	ListenAddrs   []string
+	TargetURL     *url.URL
+	Timeout       time.Duration
+	CustomHeaders map[string]string
+	// AddHeaders are added to forwarded requests next to any values the
+	// client sent, after CustomHeaders are set
+	AddHeaders          map[string][]string
+	ResponseHeaders     map[string]string
+	RewriteRedirects    bool
+	Compress            bool
+	RateLimit           float64
+	RateBurst           int
+	HealthInterval      time.Duration
+	HealthPath          string
+	BasicAuth           map[string]string
+	ForwardAuth         bool
+	AllowCIDRs          []*net.IPNet
+	DenyCIDRs           []*net.IPNet
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration
+	TLSHandshakeTimeout time.Duration
+	// ExpectContinueTimeout bounds the wait for 100 Continue on requests
+	// carrying "Expect: 100-continue"
+	ExpectContinueTimeout time.Duration
+	// ResponseHeaderTimeout bounds the wait for a backend's response headers
+	// once the request is sent; zero waits as long as Timeout allows. The
+	// body can then stream for the rest of Timeout.
+	ResponseHeaderTimeout time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	InsecureSkipVerify    bool
+	ClientCertFile        string
+	ClientKeyFile         string
+	CACertFile            string
+	TLSMinVersion         uint16        // TLS 1.2 when zero
+	TLSCipherSuites       []uint16      // TLS 1.0-1.2 only; Go picks TLS 1.3 suites itself
+	TLSCurves             []tls.CurveID // key exchanges, in order of preference
+	HTTP2                 bool
+	H2C                   bool
+	SelfHealthPath        string
+	Routes                []Route
+	PathRoutes            []PathRoute
+	MaxResponseSize       int64
+	// MaxHeaderBytes limits the size of client request headers; larger
+	// requests are refused with 431 before reaching the handler
+	MaxHeaderBytes int
+	// PreserveHeaderCase sends CustomHeaders, AddHeaders and per-backend
+	// header names with the exact casing given instead of canonicalizing
+	// them. Header names sent by clients are canonicalized by net/http when
+	// the request is read, so their original casing cannot be restored.
+	PreserveHeaderCase bool
+	// NoForwardedHeaders stops the proxy adding X-Forwarded-* and Via
+	// headers altogether; the NoXForwarded* fields turn off single ones.
+	// Values the client sent itself are forwarded unchanged either way.
+	NoForwardedHeaders bool
+	NoXForwardedFor    bool
+	NoXForwardedHost   bool
+	NoXForwardedProto  bool
+	// ForwardedRFC7239 also sends the standard Forwarded header
+	// ("for=...;host=...;proto=..."), appended to any value already present
+	ForwardedRFC7239 bool
+	// XFFTrustedHops is the number of trusted proxies in front of this one.
+	// When set, the client IP is the X-Forwarded-For entry that many
+	// positions from the right instead of the first untrusted one.
+	XFFTrustedHops int
+	// LoadBalancing chooses how requests are spread over Backends:
+	// LoadBalancingRoundRobin (the default, honouring weights),
+	// LoadBalancingRandom, LoadBalancingLeastConn, LoadBalancingP2C or
+	// LoadBalancingConsistentHash. The aliases "roundrobin", "weighted" and
+	// "leastconn" are accepted too.
+	LoadBalancing string
+	// HashKey is what consistent hashing keys on: "ip" (the default),
+	// "header:NAME" or "cookie:NAME". Setting it implies
+	// LoadBalancingConsistentHash.
+	HashKey string
+	// SRVTarget, used instead of TargetURL or Backends, fills the backend
+	// pool from the DNS SRV records it names (srv://_http._tcp.NAME),
+	// looked up again every SRVRefresh (DefaultSRVRefresh when zero)
+	SRVTarget  *url.URL
+	SRVRefresh time.Duration
+	// SRVResolver looks the SRV records up; nil uses net.DefaultResolver
+	SRVResolver SRVResolver
+	// TimingHeader adds DurationHeader to proxied responses: the time from
+	// sending the request to the backend until its whole body has been read.
+	// Responses are buffered for that, as with BufferResponse, except event
+	// streams, whose timing stops at the headers
+	TimingHeader bool
+	// BufferResponse reads each response fully before relaying it, so it
+	// goes out with an exact Content-Length instead of chunked
+	BufferResponse       bool
+	RequestIDHeader      string
+	Backends             []Backend
+	BreakerThreshold     int
+	BreakerCooldown      time.Duration
+	BreakerCount5xx      bool
+	AccessLogPath        string
+	ProxyProtocol        bool
+	TrustedProxies       []*net.IPNet
+	OTelEndpoint         string
+	StripHeaders         []string
+	StripResponseHeaders []string
+	PreserveHost         bool
+	PreserveRawPath      bool
+	CacheSize            int64
+	CacheTTL             time.Duration
+	ForwardProxy         bool
+	Reflect              bool
+	ErrorPages           map[int]ErrorPage
+	MaxConcurrent        int
+	MaxConnsPerIP        int
+	ConcurrencyPolicy    string
+	QueueTimeout         time.Duration
+	TLSCertFile          string
+	TLSKeyFile           string
+	AutocertDomains      []string
+	AutocertCacheDir     string
+	Rewrites             []RewriteRule
+	MirrorURL            *url.URL
+	MirrorPercent        float64
+	Retries              int
+	RetryBackoff         time.Duration
+	MaxRetryAfter        time.Duration
+	ForwardTrailers      bool
+	AdminAddr            string
+	UpstreamProxy        *url.URL
+	SlowThreshold        time.Duration
+	SetQuery             []QueryParam
+	FallbackURL          *url.URL
+	FallbackStatuses     []int
+	AllowedMethods       []string
+	// AllowedContentTypes, when set, limits request bodies to these media
+	// types ("text/*" matches any subtype); others get 415
+	AllowedContentTypes []string
+	// StrictContentType also rejects bodies that carry no Content-Type
+	StrictContentType bool
+	// StatusRemaps rewrites backend status codes (key) to the code sent to
+	// the client (value); headers and body are relayed unchanged
+	StatusRemaps map[int]int
+	// AllowedStatuses, when set, lists the backend statuses relayed to the
+	// client; any other response is logged and replaced by a generic 502
+	AllowedStatuses []StatusRange
+	// RequiredJSONFields are dot-separated paths ("user.id") that must be
+	// present in JSON request bodies; requests lacking one get 400
+	RequiredJSONFields  []string
+	InjectLatency       time.Duration
+	InjectLatencyJitter time.Duration
+	InjectErrorRate     float64
+	InjectErrorStatus   int
+	// InjectSeed, when non-zero, seeds the random source behind injected
+	// faults so runs are reproducible
+	InjectSeed  uint64
+	RemoveQuery []string
+	// RetryMaxBackoff caps the delay between retries, which starts at
+	// RetryBackoff and doubles with each retry (DefaultRetryMaxBackoff when
+	// zero)
+	RetryMaxBackoff time.Duration
+	// RetryJitter takes a random share of up to this fraction off each
+	// retry delay: 0 keeps delays exact, 1 is full jitter
+	RetryJitter float64
+	// DNSCacheTTL, when set, caches the resolved addresses of backend hosts
+	// for this long and rotates new connections over them. A failed lookup
+	// keeps using the last addresses for up to DNSCacheGrace.
+	DNSCacheTTL time.Duration
+	// Maintenance starts the proxy in maintenance mode, answering every
+	// request with 503 and MaintenancePage (a plain message when nil) until
+	// ExitMaintenance is called
+	Maintenance     bool
+	MaintenancePage *ErrorPage
+	// BodyReplacements rewrite the bodies of responses whose media type is
+	// in BodyReplaceTypes (DefaultBodyReplaceTypes when empty), for example
+	// to point absolute backend URLs at the proxy. Matching bodies are
+	// buffered in memory.
+	BodyReplacements []BodyReplacement
+	BodyReplaceTypes []string
+	// HostResolver resolves backend hosts for the DNS cache; nil uses
+	// net.DefaultResolver
+	HostResolver HostResolver
+	// PprofHandler, when set, is mounted on the admin API under
+	// /debug/pprof/. The proxy package does not import net/http/pprof
+	// itself, since that registers the profiles on http.DefaultServeMux in
+	// every program linking it.
+	PprofHandler http.Handler
+	// HTTP3 also serves HTTP/3 over QUIC on the UDP side of each TLS
+	// listener's port and advertises it with Alt-Svc. It needs TLSCertFile
+	// or AutocertDomains, and a binary built with -tags http3.
+	HTTP3 bool
+	// Debug logs every header sent to and received from the backend, with
+	// credentials redacted unless DebugShowSecrets is set
+	Debug            bool
+	DebugShowSecrets bool
+	// Logger receives operational logs; nil uses the standard logger
+	Logger *log.Logger
+	// SlowLogger receives slow request warnings, which callers usually want
+	// even when Logger is silenced; nil uses Logger
+	SlowLogger *log.Logger
+	// TracerProvider, when set, is used instead of exporting to OTelEndpoint
+	TracerProvider trace.TracerProvider
+}
+
+type Proxy struct {
+	// RequestBodyTransformer, when set, rewrites each request body before it
+	// is forwarded. The whole body is buffered in memory first.
+	RequestBodyTransformer BodyTransformer
+	// ResponseBodyTransformer, when set, rewrites each backend response body
+	// before it is relayed. The whole body is buffered in memory first, so
+	// streamed responses such as Server-Sent Events are no longer incremental.
+	ResponseBodyTransformer BodyTransformer
+
+	config      Config
+	httpClient  *http.Client
+	logger      *log.Logger
+	slowLogger  *log.Logger
+	rateLimiter *rateLimiter
+	health      *healthChecker
+	breaker     *circuitBreaker
+	accessLog   *accessLog
+	tracing     *tracing
+	cache       *responseCache
+	concurrency *concurrencyLimiter
+	perClient   *clientLimiter
+	autocert    *autocert.Manager
+	mirror      *mirror
+	faults      *faultInjector
+	stats       *stats
+	draining    atomic.Bool
+	maintenance atomic.Bool
+	// live holds the settings Reload may swap while serving
+	live     atomic.Pointer[liveConfig]
+	liveMu   sync.Mutex // serialises Reload and SRV refreshes
+	selector BackendSelector
+	backoff  backoff
+	// discovery keeps the backend pool in line with Config.SRVTarget
+	discovery *srvDiscovery
+	// running are the servers started by Start and Serve, for Stop;
+	// stopped is set by Stop so that later servers never start
+	runningMu   sync.Mutex
+	running     []*http.Server
+	runningQUIC []quicServer
+	stopped     bool
+}
+
+// New validates config and returns a Proxy ready to serve requests, either
+// directly as an http.Handler or through Start and Serve.
+func New(config Config) (*Proxy, error) {
+	if err := validateLiveConfig(config); err != nil {
+		return nil, err
+	}
+
+	if len(config.AllowedContentTypes) > 0 {
+		types, err := normalizeContentTypes(config.AllowedContentTypes)
+		if err != nil {
+			return nil, err
+		}
+		config.AllowedContentTypes = types
+	}
+
+	for _, field := range config.RequiredJSONFields {
+		if field == "" || slices.Contains(strings.Split(field, "."), "") {
+			return nil, fmt.Errorf("invalid required JSON field %q", field)
+		}
+	}
+
+	if config.ListenAddr == "" && len(config.ListenAddrs) == 0 {
+		return nil, fmt.Errorf("listen address cannot be empty")
+	}
+
+	if config.InjectLatency < 0 || config.InjectLatencyJitter < 0 {
+		return nil, fmt.Errorf("injected latency cannot be negative")
+	}
+
+	if config.InjectErrorRate < 0 || config.InjectErrorRate > 1 {
+		return nil, fmt.Errorf("injected error rate must be between 0 and 1")
+	}
+
+	if config.InjectErrorStatus != 0 && (config.InjectErrorStatus < 100 || config.InjectErrorStatus > 599) {
+		return nil, fmt.Errorf("injected error status must be a valid HTTP status code")
+	}
+
+	if config.RequestIDHeader == "" {
+		config.RequestIDHeader = DefaultRequestIDHeader
+	}
+
+	if config.DialTimeout < 0 || config.KeepAlive < 0 || config.TLSHandshakeTimeout < 0 {
+		return nil, fmt.Errorf("dial, keep-alive and TLS handshake timeouts cannot be negative")
+	}
+
+	if config.MaxHeaderBytes < 0 {
+		return nil, fmt.Errorf("maximum header bytes cannot be negative")
+	}
+
+	if config.ExpectContinueTimeout < 0 || config.ResponseHeaderTimeout < 0 {
+		return nil, fmt.Errorf("expect-continue and response header timeouts cannot be negative")
+	}
+
+	if config.MaxIdleConns < 0 || config.MaxIdleConnsPerHost < 0 || config.MaxConnsPerHost < 0 {
+		return nil, fmt.Errorf("connection pool limits cannot be negative")
+	}
+
+	if config.IdleConnTimeout < 0 {
+		return nil, fmt.Errorf("idle connection timeout cannot be negative")
+	}
+
+	if config.SRVTarget != nil && (config.TargetURL != nil || len(config.Backends) > 0) {
+		return nil, fmt.Errorf("an SRV target cannot be combined with a target URL or backends")
+	}
+
+	if config.XFFTrustedHops < 0 {
+		return nil, fmt.Errorf("X-Forwarded-For trusted hops cannot be negative")
+	}
+
+	if config.XFFTrustedHops > 0 && len(config.TrustedProxies) == 0 {
+		return nil, fmt.Errorf("X-Forwarded-For trusted hops require trusted proxies")
+	}
+
+	if config.DNSCacheTTL < 0 {
+		return nil, fmt.Errorf("DNS cache TTL cannot be negative")
+	}
+
+	if config.SRVRefresh < 0 {
+		return nil, fmt.Errorf("SRV refresh interval cannot be negative")
+	}
+
+	if config.IdleConnTimeout == 0 {
+		config.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+
+	if config.MaxIdleConns == 0 {
+		config.MaxIdleConns = DefaultMaxIdleConns
+	}
+
+	if config.MaxIdleConnsPerHost == 0 {
+		config.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+
+	if config.Retries < 0 || config.RetryBackoff < 0 || config.RetryMaxBackoff < 0 || config.MaxRetryAfter < 0 {
+		return nil, fmt.Errorf("retries, retry backoff and maximum Retry-After cannot be negative")
+	}
+
+	if config.RetryJitter < 0 || config.RetryJitter > 1 {
+		return nil, fmt.Errorf("retry jitter must be between 0 and 1")
+	}
+
+	if config.RetryBackoff == 0 {
+		config.RetryBackoff = DefaultRetryBackoff
+	}
+
+	if config.RetryMaxBackoff == 0 {
+		config.RetryMaxBackoff = max(DefaultRetryMaxBackoff, config.RetryBackoff)
+	}
+
+	if config.RetryMaxBackoff < config.RetryBackoff {
+		return nil, fmt.Errorf("maximum retry backoff cannot be below the base retry backoff")
+	}
+
+	if config.MaxRetryAfter == 0 {
+		config.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+
+	if config.MirrorPercent < 0 || config.MirrorPercent > 100 {
+		return nil, fmt.Errorf("mirror percentage must be between 0 and 100")
+	}
+
+	if config.MirrorPercent == 0 {
+		config.MirrorPercent = 100
+	}
+
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS certificate and key must be provided together")
+	}
+
+	if len(config.AutocertDomains) > 0 && config.TLSCertFile != "" {
+		return nil, fmt.Errorf("automatic certificates cannot be combined with a TLS certificate and key")
+	}
+
+	if config.HTTP3 {
+		if config.TLSCertFile == "" && len(config.AutocertDomains) == 0 {
+			return nil, fmt.Errorf("HTTP/3 requires a TLS certificate or automatic certificates")
+		}
+		if !http3Supported {
+			return nil, fmt.Errorf("HTTP/3 support is not built in; rebuild with -tags http3")
+		}
+	}
+
+	if config.CacheSize < 0 || config.CacheTTL < 0 {
+		return nil, fmt.Errorf("cache size and TTL cannot be negative")
+	}
+
+	if config.MaxConcurrent < 0 || config.QueueTimeout < 0 {
+		return nil, fmt.Errorf("max concurrent requests and queue timeout cannot be negative")
+	}
+
+	if config.MaxConnsPerIP < 0 {
+		return nil, fmt.Errorf("max concurrent requests per client IP cannot be negative")
+	}
+
+	for i, replacement := range config.BodyReplacements {
+		if replacement.Old == "" && replacement.Pattern == nil {
+			return nil, fmt.Errorf("body replacement %d must have old text or a pattern", i)
+		}
+	}
+
+	config.LoadBalancing = CanonicalLoadBalancing(config.LoadBalancing)
+	if config.LoadBalancing == "" {
+		config.LoadBalancing = LoadBalancingRoundRobin
+		if config.HashKey != "" {
+			config.LoadBalancing = LoadBalancingConsistentHash
+		}
+	}
+	selector, err := newBackendSelector(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ConcurrencyPolicy == "" {
+		config.ConcurrencyPolicy = ConcurrencyPolicyReject
+	}
+
+	if config.QueueTimeout == 0 {
+		config.QueueTimeout = DefaultQueueTimeout
+	}
+
+	if config.BreakerThreshold < 0 || config.BreakerCooldown < 0 {
+		return nil, fmt.Errorf("circuit breaker threshold and cooldown cannot be negative")
+	}
+
+	if config.BreakerCooldown == 0 {
+		config.BreakerCooldown = DefaultBreakerCooldown
+	}
+
+	if config.DialTimeout == 0 {
+		config.DialTimeout = 10 * time.Second
+	}
+
+	if config.KeepAlive == 0 {
+		config.KeepAlive = 30 * time.Second
+	}
+
+	if config.TLSHandshakeTimeout == 0 {
+		config.TLSHandshakeTimeout = 10 * time.Second
+	}
+
+	if config.ExpectContinueTimeout == 0 {
+		config.ExpectContinueTimeout = DefaultExpectContinueTimeout
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	if config.MaxHeaderBytes == 0 {
+		config.MaxHeaderBytes = DefaultMaxHeaderBytes
+	} else if clamped := min(max(config.MaxHeaderBytes, MinMaxHeaderBytes), MaxMaxHeaderBytes); clamped != config.MaxHeaderBytes {
+		logger.Printf("Maximum header bytes %d is out of range, using %d", config.MaxHeaderBytes, clamped)
+		config.MaxHeaderBytes = clamped
+	}
+
+	tlsConfig, err := buildTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ExpectContinueTimeout: config.ExpectContinueTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+	}
+
+	if config.DNSCacheTTL > 0 {
+		transport.DialContext = newDNSCache(config.HostResolver, config.DNSCacheTTL, logger).dialContext(dialer.DialContext)
+	}
+
+	if config.UpstreamProxy != nil {
+		if err := configureUpstreamProxy(transport, dialer, config.UpstreamProxy); err != nil {
+			return nil, err
+		}
+	}
+
+	// HTTP/1.1 stays the default; HTTP/2 must be requested explicitly since
+	// a custom dialer and TLS config disable the transport's automatic upgrade
+	if config.HTTP2 {
+		transport.ForceAttemptHTTP2 = true
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	// h2c has no negotiation, so http:// backends must speak HTTP/2 directly
+	if config.H2C {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols = protocols
+	}
+
+	httpClient := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	if config.InsecureSkipVerify {
+		logger.Printf("WARNING: TLS certificate verification is disabled for backends")
+	}
+
+	proxy := &Proxy{
+		config:     config,
+		httpClient: httpClient,
+		logger:     logger,
+		slowLogger: logger,
+		selector:   selector,
+		backoff:    newBackoff(config),
+		stats:      newStats(),
+	}
+
+	if config.SlowLogger != nil {
+		proxy.slowLogger = config.SlowLogger
+	}
+
+	proxy.maintenance.Store(config.Maintenance)
+
+	if config.InjectLatency > 0 || config.InjectLatencyJitter > 0 {
+		logger.Printf("WARNING: injecting %v (+/- %v) of latency into every request; not for production use", config.InjectLatency, config.InjectLatencyJitter)
+	}
+	if config.InjectErrorRate > 0 {
+		logger.Printf("WARNING: failing %.4g%% of requests with injected errors; not for production use", config.InjectErrorRate*100)
+	}
+	if config.InjectLatency > 0 || config.InjectLatencyJitter > 0 || config.InjectErrorRate > 0 {
+		proxy.faults = newFaultInjector(config)
+	}
+
+	proxy.live.Store(newLiveConfig(config))
+
+	if config.SRVTarget != nil {
+		proxy.discovery = newSRVDiscovery(config.SRVTarget, config.SRVResolver, config.SRVRefresh)
+		if err := proxy.refreshSRV(context.Background()); err != nil {
+			logger.Printf("WARNING: %v; starting with no backends", err)
+		}
+	}
+
+	if config.RateLimit > 0 {
+		proxy.rateLimiter = newRateLimiter(config.RateLimit, config.RateBurst)
+	}
+
+	if config.BreakerThreshold > 0 {
+		proxy.breaker = newCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown, logger)
+	}
+
+	if config.AccessLogPath != "" {
+		proxy.accessLog, err = openAccessLog(config.AccessLogPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.TracerProvider != nil {
+		proxy.tracing = newTracing(config.TracerProvider, nil)
+	} else if config.OTelEndpoint != "" {
+		provider, err := newOTLPTracerProvider(config.OTelEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up tracing: %w", err)
+		}
+		proxy.tracing = newTracing(provider, provider.Shutdown)
+	}
+
+	if config.MaxConnsPerIP > 0 {
+		proxy.perClient = newClientLimiter(config.MaxConnsPerIP)
+	}
+
+	if config.MaxConcurrent > 0 {
+		proxy.concurrency, err = newConcurrencyLimiter(config.MaxConcurrent, config.ConcurrencyPolicy, config.QueueTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.MirrorURL != nil {
+		proxy.mirror = newMirror(config.MirrorURL, config.MirrorPercent, config.Timeout)
+	}
+
+	if len(config.AutocertDomains) > 0 {
+		proxy.autocert = newAutocertManager(config.AutocertDomains, config.AutocertCacheDir)
+	}
+
+	if config.CacheSize > 0 {
+		proxy.cache = newResponseCache(config.CacheSize, config.CacheTTL)
+	}
+
+	if config.HealthInterval > 0 {
+		proxy.health = newHealthChecker(proxy.backendURLs(), config.HealthPath, config.HealthInterval, httpClient, logger)
+	}
+
+	return proxy, nil
+}
+
+// BreakerStates reports the circuit breaker state of each backend ("closed",
+// "open" or "half-open"), keyed by backend URL. Backends are always reported
+// closed when the circuit breaker is disabled.
+func (p *Proxy) BreakerStates() map[string]string {
+	states := make(map[string]string)
+	for _, backend := range p.backendURLs() {
+		state := breakerClosed
+		if p.breaker != nil {
+			state = p.breaker.state(backend)
+		}
+		states[backend.String()] = state.String()
+	}
+	return states
+}
+
+// InFlight reports how many requests are currently being proxied to a
+// backend. It is always 0 when -max-concurrent is not set.
+func (p *Proxy) InFlight() int {
+	if p.concurrency == nil {
+		return 0
+	}
+	return p.concurrency.inFlight()
+}
+
+// HealthStatus reports the last known health of each backend, keyed by
+// backend URL. Backends are always reported healthy when health checking is
+// disabled.
+func (p *Proxy) HealthStatus() map[string]bool {
+	if p.health == nil {
+		status := make(map[string]bool)
+		for _, backend := range p.backendURLs() {
+			status[backend.String()] = true
+		}
+		return status
+	}
+	return p.health.snapshot()
+}
+
+// ReopenAccessLog reopens the access log file so a rotated file is replaced
+// by a fresh one at the configured path. It is a no-op without an access log.
+func (p *Proxy) ReopenAccessLog() error {
+	if p.accessLog == nil {
+		return nil
+	}
+	return p.accessLog.reopen()
+}
+
+// Close releases resources held by the proxy, closing the access log file
+// and flushing any buffered trace spans.
+func (p *Proxy) Close() error {
+	var errs []error
+	if p.accessLog != nil {
+		errs = append(errs, p.accessLog.close())
+	}
+	if p.tracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		errs = append(errs, p.tracing.shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// statusClientClosedRequest is nginx's 499, written when the client
+// disconnects before the backend answers so that logs and stats record it.
+// The connection is already gone, so the client never sees it.
+const statusClientClosedRequest = 499
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Only a forward proxy goes where an absolute-form URL points
+	if !p.config.ForwardProxy {
+		r = originForm(r)
+	}
+
+	// Liveness probes are answered locally and kept out of the access log
+	if p.config.SelfHealthPath != "" && r.URL.Path == p.config.SelfHealthPath {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+		return
+	}
+
+	start := time.Now()
+
+	// IDs assigned by upstream proxies are preserved so traces stay joined
+	requestID := r.Header.Get(p.config.RequestIDHeader)
+	if requestID == "" {
+		requestID = newRequestID()
+		r = r.Clone(r.Context())
+		r.Header.Set(p.config.RequestIDHeader, requestID)
+	}
+
+	var span trace.Span
+	if p.tracing != nil {
+		r, span = p.tracing.startServerSpan(r)
+	}
+
+	rw := newResponseWriter(w)
+	rw.Header().Set(p.config.RequestIDHeader, requestID)
+	if p.config.HTTP3 && r.ProtoMajor < 3 {
+		if port := p.listenPort(r); port != "" {
+			rw.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%s"; ma=86400`, port))
+		}
+	}
+	defer func() {
+		if span != nil {
+			setSpanStatus(span, rw.statusCode)
+			span.End()
+		}
+		p.stats.record(rw.statusCode)
+		duration := time.Since(start)
+		p.logger.Printf("%s %s -> %d (%d bytes) %dms request_id=%s", r.Method, r.URL.Path, rw.statusCode, rw.bytesWritten, duration.Milliseconds(), requestID)
+		if p.config.SlowThreshold > 0 && duration > p.config.SlowThreshold {
+			p.slowLogger.Printf("WARNING: slow request %s %s from %s took %v (threshold %v) request_id=%s", r.Method, r.URL.Path, p.clientIP(r), duration.Round(time.Millisecond), p.config.SlowThreshold, requestID)
+		}
+		if p.accessLog != nil {
+			if err := p.accessLog.write(newAccessLogEntry(r, rw, p.clientIP(r), requestID, start)); err != nil {
+				p.logger.Printf("Error writing access log: %v", err)
+			}
+		}
+	}()
+
+	p.serve(rw, r)
+}
+
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request) {
+	if p.draining.Load() {
+		p.rejectDraining(w)
+		return
+	}
+
+	if p.maintenance.Load() {
+		p.serveMaintenance(w)
+		return
+	}
+
+	if !p.ipAllowed(p.clientIP(r)) {
+		p.logger.Printf("Forbidden request from %s", p.clientIP(r))
+		p.writeError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if p.rateLimiter != nil {
+		if allowed, wait := p.rateLimiter.allow(p.clientIP(r)); !allowed {
+			p.logger.Printf("Rate limit exceeded for %s", p.clientIP(r))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			p.writeError(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	if p.perClient != nil {
+		clientIP := p.clientIP(r)
+		if !p.perClient.acquire(clientIP) {
+			p.logger.Printf("Too many concurrent requests from %s", clientIP)
+			p.writeError(w, "Too many concurrent requests", http.StatusTooManyRequests)
+			return
+		}
+		defer p.perClient.release(clientIP)
+	}
+
+	if len(p.config.BasicAuth) > 0 && !p.checkBasicAuth(r) {
+		p.logger.Printf("Unauthorized request from %s", p.clientIP(r))
+		p.requireBasicAuth(w)
+		return
+	}
+
+	if !p.methodAllowed(r.Method) {
+		p.rejectMethod(w, r)
+		return
+	}
+
+	if !p.contentTypeAllowed(r) {
+		p.rejectContentType(w, r)
+		return
+	}
+
+	if !p.checkJSONFields(w, r) {
+		return
+	}
+
+	if p.config.Reflect {
+		p.serveReflect(w, r)
+		return
+	}
+
+	if p.config.ForwardProxy && r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+
+	webSocket := isWebSocketUpgrade(r)
+	useCache := p.cache != nil && isCacheableRequest(r) && !webSocket
+	if useCache {
+		// "no-cache" from the client asks for a fresh copy, which may still
+		// replace the stored one
+		if _, noCache := cacheControl(r.Header)["no-cache"]; !noCache {
+			if entry, ok := p.cache.get(r); ok {
+				resp := entry.response(p.cache.now())
+				if isNotModified(r, entry.header) {
+					// The client already holds this version
+					resp = entry.notModifiedResponse(p.cache.now())
+				}
+				resp.Header.Set("X-Cache", "HIT")
+				p.writeResponse(w, r, resp, entry.backend)
+				return
+			}
+		}
+	}
+
+	if p.concurrency != nil {
+		if !p.concurrency.acquire(r.Context()) {
+			p.logger.Printf("Concurrency limit reached, rejecting %s %s", r.Method, r.URL.Path)
+			p.writeError(w, "Too many concurrent requests", http.StatusServiceUnavailable)
+			return
+		}
+		defer p.concurrency.release()
+	}
+
+	if p.faults != nil {
+		if err := p.faults.sleep(r.Context()); err != nil {
+			p.logger.Printf("Client gave up during injected latency for %s %s", r.Method, r.URL.Path)
+			return
+		}
+		if p.faults.fail() {
+			p.logger.Printf("Injecting %d for %s %s", p.faults.errorStatus, r.Method, r.URL.Path)
+			p.writeError(w, "Injected fault", p.faults.errorStatus)
+			return
+		}
+	}
+
+	backend, err := p.selectBackend(r)
+	if errors.Is(err, errNoRoute) {
+		p.logger.Printf("No route for host %s and path %s", r.Host, r.URL.Path)
+		p.writeError(w, "No route for host", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		p.logger.Printf("No healthy backend available for %s %s", r.Method, r.URL.Path)
+		p.writeError(w, "No healthy backend available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if tracker, ok := p.selector.(connectionTracker); ok {
+		tracker.acquire(backend)
+		defer tracker.release(backend)
+	}
+
+	targetReq := stripPathPrefix(r, backend.stripPrefix)
+	targetURL := p.buildTargetURL(targetReq, backend.URL)
+
+	// Deriving from the client's context means a disconnect cancels the
+	// backend request; a zero timeout leaves long-lived streams unbounded
+	ctx := r.Context()
+	if timeout := p.requestTimeout(r, backend); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	proxyReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL.String(), r.Body)
+	if err != nil {
+		p.logger.Printf("Error creating proxy request: %v", err)
+		p.writeError(w, "Failed to create proxy request", http.StatusInternalServerError)
+		return
+	}
+	// A known length lets the backend judge the body from the headers alone,
+	// e.g. rejecting an oversized upload before "Expect: 100-continue" lets
+	// the client send it
+	proxyReq.ContentLength = r.ContentLength
+
+	p.copyHeaders(r, proxyReq, backend)
+	if !p.config.NoForwardedHeaders {
+		p.addForwardedHeaders(r, proxyReq)
+	}
+	if webSocket {
+		// Hop-by-hop headers are dropped above; the upgrade is asked for
+		// again on the backend connection
+		proxyReq.Header.Set("Connection", "Upgrade")
+		proxyReq.Header.Set("Upgrade", "websocket")
+	}
+
+	if p.RequestBodyTransformer != nil {
+		if err := p.transformRequestBody(proxyReq); err != nil {
+			p.logger.Printf("Error transforming request body: %v", err)
+			p.writeError(w, "Failed to transform request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if p.breaker != nil && !p.breaker.allow(backend.URL) {
+		p.logger.Printf("Circuit open for %s, rejecting %s %s", backend.URL.Host, r.Method, r.URL.Path)
+		p.writeError(w, "Backend unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Only requests the primary backend will see are mirrored
+	if p.mirror != nil && p.mirror.sample() {
+		p.mirrorRequest(r, proxyReq, backend)
+		if body := proxyReq.Body; body != nil {
+			defer func() { _ = body.Close() }()
+		}
+	}
+
+	p.logger.Printf("%s %s -> %s", r.Method, r.URL.Path, targetURL.String())
+
+	var endSpan func(*http.Response, error)
+	if p.tracing != nil {
+		proxyReq, endSpan = p.tracing.startClientSpan(proxyReq)
+	}
+
+	p.debugRequest(r, proxyReq)
+	backendStart := time.Now()
+	resp, err := p.doWithRetries(proxyReq)
+	if endSpan != nil {
+		endSpan(resp, err)
+	}
+	if p.breaker != nil {
+		switch {
+		case err == nil:
+			p.breaker.record(backend.URL, p.config.BreakerCount5xx && resp.StatusCode >= 500)
+		case r.Context().Err() != nil:
+			// A client that gave up says nothing about the backend
+			p.breaker.release(backend.URL)
+		default:
+			p.breaker.record(backend.URL, true)
+		}
+	}
+	if p.shouldFallback(proxyReq, resp, err) {
+		resp, err = p.doFallback(targetReq, proxyReq, backend, resp, err)
+	}
+	if err != nil && r.Context().Err() != nil {
+		// The client went away, which cancelled the backend request along
+		// with it; nobody is left to read an error page
+		p.logger.Printf("Client closed connection before %s %s was answered", r.Method, r.URL.Path)
+		w.WriteHeader(statusClientClosedRequest)
+		return
+	}
+	if err != nil {
+		p.logger.Printf("Error proxying request: %v", err)
+		// A backend that answered too slowly is a 504, distinct from one
+		// that could not be reached at all
+		if errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err) {
+			p.writeError(w, "Backend request timed out", http.StatusGatewayTimeout)
+			return
+		}
+		p.writeError(w, "Failed to proxy request", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	p.debugResponse(r, resp)
+
+	timing := &backendTiming{TTFB: time.Since(backendStart)}
+	if rw, ok := w.(*responseWriter); ok {
+		rw.backend = timing
+	}
+	defer func() { timing.Total = time.Since(backendStart) }()
+
+	if !p.statusAllowed(resp.StatusCode) {
+		p.logger.Printf("Backend answered %s %s with %d, which is not an allowed status", r.Method, r.URL.Path, resp.StatusCode)
+		p.writeError(w, "Bad gateway", http.StatusBadGateway)
+		return
+	}
+
+	if webSocket && resp.StatusCode == http.StatusSwitchingProtocols {
+		p.serveWebSocket(w, r, resp)
+		return
+	}
+
+	if len(p.config.BodyReplacements) > 0 && p.shouldReplaceBody(resp) {
+		if err := p.transformResponseBody(r, resp, p.replaceBody); err != nil {
+			if errors.Is(err, errResponseTooLarge) {
+				p.logger.Printf("WARNING: response larger than %d bytes for %s %s (client %s)", p.config.MaxResponseSize, r.Method, r.URL.Path, p.clientIP(r))
+				p.writeError(w, "Backend response too large", http.StatusBadGateway)
+				return
+			}
+			p.logger.Printf("Error replacing in response body: %v", err)
+			p.writeError(w, "Failed to transform response body", http.StatusBadGateway)
+			return
+		}
+	}
+
+	if p.ResponseBodyTransformer != nil {
+		if err := p.transformResponseBody(r, resp, p.ResponseBodyTransformer); err != nil {
+			if errors.Is(err, errResponseTooLarge) {
+				p.logger.Printf("WARNING: response larger than %d bytes for %s %s (client %s)", p.config.MaxResponseSize, r.Method, r.URL.Path, p.clientIP(r))
+				p.writeError(w, "Backend response too large", http.StatusBadGateway)
+				return
+			}
+			p.logger.Printf("Error transforming response body: %v", err)
+			p.writeError(w, "Failed to transform response body", http.StatusBadGateway)
+			return
+		}
+	}
+
+	// The timing header has to cover the body, so it waits for all of it
+	if p.config.BufferResponse || p.config.TimingHeader {
+		if err := p.bufferResponseBody(r, resp); err != nil {
+			if errors.Is(err, errResponseTooLarge) {
+				p.logger.Printf("WARNING: response larger than %d bytes for %s %s (client %s)", p.config.MaxResponseSize, r.Method, r.URL.Path, p.clientIP(r))
+				p.writeError(w, "Backend response too large", http.StatusBadGateway)
+				return
+			}
+			p.logger.Printf("Error buffering response body: %v", err)
+			p.writeError(w, "Failed to read response body", http.StatusBadGateway)
+			return
+		}
+	}
+
+	var capture *cacheCapture
+	var storedHeader http.Header
+	if useCache {
+		if isCacheableResponse(resp) {
+			storedHeader = resp.Header.Clone()
+			capture = &cacheCapture{ReadCloser: resp.Body, limit: p.config.CacheSize}
+			resp.Body = capture
+		}
+		resp.Header.Set("X-Cache", "MISS")
+	}
+
+	if p.config.TimingHeader {
+		setDurationHeader(resp.Header, backendStart)
+	}
+
+	p.writeResponse(w, r, resp, backend.URL)
+
+	// The body of a HEAD response is never read, so there is nothing to wait
+	// for before caching it
+	if capture != nil && (capture.complete || r.Method == http.MethodHead) && !capture.overflow {
+		resp.Header = storedHeader
+		p.cache.set(r, resp, capture.buf.Bytes(), backend.URL)
+	}
+}
+
+// writeResponse relays a backend (or cached) response to the client,
+// applying redirect rewriting, compression and response header overrides.
+// The body of a HEAD response is never read.
+func (p *Proxy) writeResponse(w http.ResponseWriter, r *http.Request, resp *http.Response, backend *url.URL) {
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	for _, name := range p.config.StripResponseHeaders {
+		w.Header().Del(name)
+	}
+	w.Header().Set(p.config.RequestIDHeader, r.Header.Get(p.config.RequestIDHeader))
+
+	if p.config.RewriteRedirects && isRedirect(resp.StatusCode) {
+		p.rewriteLocation(w.Header(), r, backend)
+	}
+
+	var body io.Writer = w
+	if p.config.Compress && shouldCompress(r, resp) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if p.config.BufferResponse && !isEventStream(resp) {
+			// Compress the buffered body up front so Content-Length
+			// describes what is actually sent
+			data, err := io.ReadAll(resp.Body)
+			if err == nil {
+				data, err = encodeBody(data, "gzip")
+			}
+			if err != nil {
+				p.logger.Printf("Error compressing response body: %v", err)
+				w.Header().Del("Content-Encoding")
+				p.writeError(w, "Failed to read response body", http.StatusBadGateway)
+				return
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(data))
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		} else {
+			gz := gzip.NewWriter(w)
+			defer func() { _ = gz.Close() }()
+			body = gz
+		}
+	}
+
+	p.applyResponseHeaders(w.Header())
+
+	// Declared trailers are announced up front; the values only exist once
+	// the body has been read
+	if p.config.ForwardTrailers {
+		for name := range resp.Trailer {
+			w.Header().Add("Trailer", name)
+		}
+	}
+
+	w.WriteHeader(p.responseStatus(resp.StatusCode))
+
+	// A HEAD response has no body to relay. Its Content-Length describes
+	// what a GET would return and was copied with the other headers.
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	var src io.Reader = resp.Body
+	if p.config.MaxResponseSize > 0 {
+		src = io.LimitReader(resp.Body, p.config.MaxResponseSize)
+	}
+
+	var err error
+	if isStreamingResponse(resp) {
+		err = copyStreaming(w, body, src)
+	} else {
+		_, err = io.Copy(body, src)
+	}
+	if err != nil && r.Context().Err() != nil {
+		// The client's context also governs the backend request, so the
+		// backend read was cancelled too
+		p.logger.Printf("Client closed connection during response to %s %s", r.Method, r.URL.Path)
+	} else if err != nil {
+		p.logger.Printf("Error copying response body: %v", err)
+	}
+
+	if p.config.ForwardTrailers {
+		for name, values := range resp.Trailer {
+			for _, value := range values {
+				w.Header().Add(http.TrailerPrefix+name, value)
+			}
+		}
+	}
+
+	// Anything left after the cap means the body was truncated; closing the
+	// unread body drops the backend connection instead of draining it
+	if p.config.MaxResponseSize > 0 && err == nil {
+		var probe [1]byte
+		if n, _ := resp.Body.Read(probe[:]); n > 0 {
+			p.logger.Printf("WARNING: response truncated at %d bytes for %s %s (client %s)", p.config.MaxResponseSize, r.Method, r.URL.Path, p.clientIP(r))
+		}
+	}
+}
+
+// buildTargetURL joins the request path and query onto backend. The path is
+// sent in its decoded form, so "%2F" reaches the backend as "/", unless
+// Config.PreserveRawPath keeps the client's encoding.
+func (p *Proxy) buildTargetURL(r *http.Request, backend *url.URL) *url.URL {
+	path, rawPath := p.rewritePath(r.URL.Path), ""
+	if p.config.PreserveRawPath && r.URL.RawPath != "" {
+		// Rewrites then see the path as the client encoded it
+		rawPath = p.rewritePath(r.URL.RawPath)
+		if decoded, err := url.PathUnescape(rawPath); err == nil {
+			path = decoded
+		} else {
+			rawPath = ""
+		}
+	}
+
+	targetURL := &url.URL{
+		Scheme:   backend.Scheme,
+		Host:     backend.Host,
+		Path:     path,
+		RawPath:  rawPath,
+		RawQuery: p.editQuery(r.URL.RawQuery),
+	}
+
+	if backend.Path != "" && backend.Path != "/" {
+		targetURL.Path = strings.TrimSuffix(backend.Path, "/") + path
+		if rawPath != "" {
+			targetURL.RawPath = strings.TrimSuffix(backend.EscapedPath(), "/") + rawPath
+		}
+	}
+
+	return targetURL
+}
+
+func (p *Proxy) copyHeaders(src *http.Request, dst *http.Request, backend *Backend) {
+	for key, values := range endToEndHeaders(src.Header) {
+		for _, value := range values {
+			dst.Header.Add(key, value)
+		}
+	}
+
+	for _, name := range p.config.StripHeaders {
+		dst.Header.Del(name)
+	}
+	dst.Header.Del(requestTimeoutHeader)
+
+	// Credentials used to authenticate with the proxy itself stay here
+	if len(p.config.BasicAuth) > 0 && !p.config.ForwardAuth {
+		dst.Header.Del("Authorization")
+	}
+	if backend != nil {
+		setURLCredentials(dst, backend.URL)
+	}
+
+	// Set default Host header to the selected backend's host, unless the
+	// backend does virtual hosting on the client's original Host
+	dst.Host = dst.URL.Host
+	if p.config.PreserveHost && src.Host != "" {
+		dst.Host = src.Host
+	}
+
+	// Apply custom headers (these override any existing headers), added
+	// headers, then the selected backend's own headers
+	live := p.live.Load()
+	p.setHeaders(dst, live.customHeaders)
+	for name, values := range live.addHeaders {
+		if p.config.PreserveHeaderCase {
+			dst.Header[name] = append(dst.Header[name], values...)
+			continue
+		}
+		for _, value := range values {
+			dst.Header.Add(name, value)
+		}
+	}
+	if backend != nil {
+		p.setHeaders(dst, backend.Headers)
+	}
+}
+
+func (p *Proxy) setHeaders(dst *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		// Special handling for Host header - must be set via dst.Host
+		switch {
+		case http.CanonicalHeaderKey(name) == "Host":
+			dst.Host = value
+		case p.config.PreserveHeaderCase:
+			// Writing the map directly skips canonicalization, so the
+			// name goes out exactly as configured
+			dst.Header.Del(name)
+			dst.Header[name] = []string{value}
+		default:
+			dst.Header.Set(name, value)
+		}
+	}
+}
+
+func (p *Proxy) applyResponseHeaders(header http.Header) {
+	// An empty value removes the header from the response entirely
+	for name, value := range p.config.ResponseHeaders {
+		if value == "" {
+			header.Del(name)
+		} else {
+			header.Set(name, value)
+		}
+	}
+}
+
+func (p *Proxy) rewriteLocation(header http.Header, r *http.Request, backend *url.URL) {
+	location := header.Get("Location")
+	if location == "" || r.Host == "" {
+		return
+	}
+
+	locURL, err := url.Parse(location)
+	if err != nil || !strings.EqualFold(locURL.Host, backend.Host) {
+		return
+	}
+
+	locURL.Host = r.Host
+	locURL.Scheme = "http"
+	if r.TLS != nil {
+		locURL.Scheme = "https"
+	}
+	header.Set("Location", locURL.String())
+}
+
+func (p *Proxy) addForwardedHeaders(src *http.Request, dst *http.Request) {
+	// Only the direct peer is appended; earlier hops are already in the list
+	clientIP := remoteIP(src)
+	if p.config.ForwardedRFC7239 {
+		forwarded := forwardedElement(clientIP, src)
+		if prior := dst.Header.Get("Forwarded"); prior != "" {
+			forwarded = prior + ", " + forwarded
+		}
+		dst.Header.Set("Forwarded", forwarded)
+	}
+	if clientIP != "" && !p.config.NoXForwardedFor {
+		if prior := dst.Header.Get("X-Forwarded-For"); prior != "" {
+			clientIP = prior + ", " + clientIP
+		}
+		dst.Header.Set("X-Forwarded-For", clientIP)
+	}
+
+	if src.Host != "" && !p.config.NoXForwardedHost {
+		dst.Header.Set("X-Forwarded-Host", src.Host)
+	}
+
+	if !p.config.NoXForwardedProto {
+		scheme := "http"
+		if src.TLS != nil {
+			scheme = "https"
+		}
+		dst.Header.Set("X-Forwarded-Proto", scheme)
+	}
+
+	if port := p.listenPort(src); port != "" {
+		dst.Header.Set("X-Forwarded-Port", port)
+	}
+
+	via := fmt.Sprintf("%d.%d goreflector/%s", src.ProtoMajor, src.ProtoMinor, Version)
+	if prior := dst.Header.Get("Via"); prior != "" {
+		via = prior + ", " + via
+	}
+	dst.Header.Set("Via", via)
+}
+
+// forwardedElement builds one RFC 7239 Forwarded element describing src.
+// IPv6 addresses are bracketed, and values that are not plain tokens, such as
+// a host with a port, are quoted.
+func forwardedElement(clientIP string, src *http.Request) string {
+	scheme := "http"
+	if src.TLS != nil {
+		scheme = "https"
+	}
+
+	var pairs []string
+	if clientIP != "" {
+		if strings.Contains(clientIP, ":") {
+			clientIP = "[" + clientIP + "]"
+		}
+		pairs = append(pairs, "for="+forwardedValue(clientIP))
+	}
+	if src.Host != "" {
+		pairs = append(pairs, "host="+forwardedValue(src.Host))
+	}
+	pairs = append(pairs, "proto="+scheme)
+	return strings.Join(pairs, ";")
+}
+
+// forwardedValue returns value as a token when it is one, and as a
+// quoted-string otherwise.
+func forwardedValue(value string) string {
+	for _, c := range value {
+		if !httpguts.IsTokenRune(c) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+	}
+	return value
+}
+
+// listenPort returns the port the client connected to, preferring the
+// accepting socket's address over the configured listen address.
+func (p *Proxy) listenPort(r *http.Request) string {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if _, port, err := net.SplitHostPort(addr.String()); err == nil {
+			return port
+		}
+	}
+	if _, port, err := net.SplitHostPort(p.config.ListenAddr); err == nil {
+		return port
+	}
+	return ""
+}
+
+func (p *Proxy) Start() error {
+	addrs := p.config.ListenAddrs
+	if len(addrs) == 0 {
+		addrs = []string{p.config.ListenAddr}
+	}
+	if p.autocert != nil {
+		addrs = []string{autocertHTTPSAddr}
+	}
+	listenOn := strings.Join(addrs, ", ")
+
+	if p.config.Reflect {
+		p.logger.Printf("Starting reflector on %s, echoing requests back to clients", listenOn)
+	} else if p.config.ForwardProxy {
+		p.logger.Printf("Starting forward proxy server on %s", listenOn)
+	} else if p.discovery != nil {
+		p.logger.Printf("Starting proxy server on %s, forwarding to backends from SRV %s", listenOn, p.discovery.target.Hostname())
+	} else if live := p.live.Load(); len(live.backends) == 1 {
+		p.logger.Printf("Starting proxy server on %s, forwarding to %s", listenOn, StripUserinfo(live.backends[0].URL))
+	} else {
+		p.logger.Printf("Starting proxy server on %s with %d backends, %d host routes and %d path routes", listenOn, len(live.backends), len(live.routes), len(live.pathRoutes))
+	}
+
+	// Sockets handed over by systemd replace the configured addresses, so
+	// the service can restart without ever closing its port
+	listeners, err := systemdListeners()
+	if err != nil {
+		return err
+	}
+	if len(listeners) > 0 {
+		p.logger.Printf("Using %d socket(s) passed by systemd instead of %s", len(listeners), listenOn)
+		addrs = nil
+	}
+
+	// Every address is bound before any is served, so a typo in one of
+	// them fails startup instead of leaving the proxy half listening
+	var errs []error
+	for _, addr := range addrs {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(errs) > 0 {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+		return errors.Join(errs...)
+	}
+
+	if p.autocert != nil {
+		p.logger.Printf("Obtaining certificates automatically for %s", strings.Join(p.config.AutocertDomains, ", "))
+		go p.serveACMEChallenges()
+	}
+
+	if p.config.AdminAddr != "" {
+		go p.serveAdmin()
+	}
+
+	return p.Serve(listeners...)
+}
+
+// quicServer serves HTTP/3 alongside a TCP listener; see Config.HTTP3.
+type quicServer interface {
+	Serve() error
+	Close() error
+}
+
+// Serve accepts connections on each listener with its own http.Server, all
+// sharing this handler, terminating TLS when a certificate is configured.
+// With Config.HTTP3 each listener's port is served over QUIC as well. When
+// one server fails the others are closed too, and the failures are returned
+// together. Stop shuts them all down gracefully instead; once Stop has been
+// called, Serve closes the listeners and returns http.ErrServerClosed.
+func (p *Proxy) Serve(listeners ...net.Listener) error {
+	var quicServers []quicServer
+	if p.config.HTTP3 {
+		for _, listener := range listeners {
+			server, err := p.newHTTP3Server(listener)
+			if err != nil {
+				for _, server := range quicServers {
+					_ = server.Close()
+				}
+				for _, listener := range listeners {
+					_ = listener.Close()
+				}
+				return err
+			}
+			quicServers = append(quicServers, server)
+		}
+	}
+
+	servers := make([]*http.Server, len(listeners))
+	for i := range listeners {
+		servers[i] = p.newServer()
+	}
+	if !p.track(servers, quicServers) {
+		for _, server := range quicServers {
+			_ = server.Close()
+		}
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+		return http.ErrServerClosed
+	}
+
+	if p.health != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go p.health.run(ctx)
+	}
+
+	if p.discovery != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go p.runSRVDiscovery(ctx)
+	}
+
+	results := make(chan error, len(listeners)+len(quicServers))
+	for i, listener := range listeners {
+		go func() {
+			results <- p.serveListener(servers[i], listener)
+		}()
+	}
+	for _, server := range quicServers {
+		go func() {
+			results <- server.Serve()
+		}()
+	}
+
+	var errs []error
+	for i := range cap(results) {
+		err := <-results
+		// After Stop the servers shut down gracefully on their own
+		if i == 0 && !errors.Is(err, http.ErrServerClosed) {
+			for _, server := range servers {
+				_ = server.Close()
+			}
+			for _, server := range quicServers {
+				_ = server.Close()
+			}
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Proxy) newServer() *http.Server {
+	// There is no WriteTimeout: it would cut off responses from backends
+	// slower than it and streamed responses alike. Each request is bounded
+	// by its own deadline instead, which follows Config.Timeout, per-route
+	// timeouts and reloads.
+	return &http.Server{
+		Handler:        p,
+		ReadTimeout:    15 * time.Second,
+		IdleTimeout:    60 * time.Second,
+		MaxHeaderBytes: p.config.MaxHeaderBytes,
+	}
+}
+
+func (p *Proxy) serveListener(server *http.Server, listener net.Listener) error {
+	if p.config.ProxyProtocol {
+		listener = &proxyProtocolListener{Listener: listener}
+	}
+
+	if p.autocert != nil {
+		server.TLSConfig = p.autocert.TLSConfig()
+		return server.ServeTLS(listener, "", "")
+	}
+
+	if p.config.TLSCertFile != "" {
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		return server.ServeTLS(listener, p.config.TLSCertFile, p.config.TLSKeyFile)
+	}
+
+	return server.Serve(listener)
+}
+
+// connectionTokens returns the header names listed in the Connection header,
+// which RFC 7230 section 6.1 makes hop-by-hop for this message only.
+func connectionTokens(header http.Header) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, token := range headerTokens(header, "Connection") {
+		tokens[token] = true
+	}
+	return tokens
+}
+
+// endToEndHeaders returns a copy of header without hop-by-hop headers,
+// including any the client listed in its Connection header.
+func endToEndHeaders(header http.Header) http.Header {
+	connectionHeaders := connectionTokens(header)
+	result := make(http.Header, len(header))
+	for key, values := range header {
+		if shouldSkipHeader(key) || connectionHeaders[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		result[key] = append([]string(nil), values...)
+	}
+	return result
+}
+
+func shouldSkipHeader(header string) bool {
+	skipHeaders := map[string]bool{
+		"Connection":          true,
+		"Keep-Alive":          true,
+		"Proxy-Authenticate":  true,
+		"Proxy-Authorization": true,
+		"Proxy-Connection":    true,
+		"Te":                  true,
+		"Trailers":            true,
+		"Transfer-Encoding":   true,
+		"Upgrade":             true,
+	}
+
+	return skipHeaders[http.CanonicalHeaderKey(header)]
+}
+
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// getClientIP returns the address of the client that made the request.
+// Forwarding headers are only believed when the direct peer is one of the
+// trusted proxies; X-Forwarded-For is then walked right to left, skipping
+// trusted hops, so entries a client prepends itself are never used. With
+// trustedHops set the walk instead takes the entry that many positions from
+// the right, provided every entry after it is a trusted proxy; a shorter
+// chain yields its leftmost entry.
+func getClientIP(r *http.Request, trustedProxies []*net.IPNet, trustedHops int) string {
+	peer := remoteIP(r)
+	if !isTrustedProxy(trustedProxies, peer) {
+		return peer
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := peer
+		for i, taken := len(hops)-1, 0; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			client = hop
+			taken++
+			if taken == trustedHops || !isTrustedProxy(trustedProxies, hop) {
+				break
+			}
+		}
+		return client
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	return peer
+}
+
+func (p *Proxy) clientIP(r *http.Request) string {
+	return getClientIP(r, p.config.TrustedProxies, p.config.XFFTrustedHops)
+}
+
+// remoteIP returns the IP of the direct peer without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isTrustedProxy(trustedProxies []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && containsIP(trustedProxies, ip)
+}
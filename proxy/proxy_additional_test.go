@@ -0,0 +1,537 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type errorReader struct{}
+
+func (e *errorReader) Read(p []byte) (n int, err error) {
+	return 0, errors.New("read error")
+}
+
+func TestServeHTTPWithRequestBodyError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		Timeout:    1 * time.Second,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/test", &errorReader{})
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", resp.StatusCode)
+	}
+}
+
+func TestCopyHeadersWithMultipleValues(t *testing.T) {
+	targetURL := mustParseURL("https://target.example.com")
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  targetURL,
+	}
+	proxy, _ := New(config)
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.Header.Add("Accept", "text/html")
+	srcReq.Header.Add("Accept", "application/json")
+	srcReq.Header.Add("Custom", "value1")
+	srcReq.Header.Add("Custom", "value2")
+
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+
+	proxy.copyHeaders(srcReq, dstReq, nil)
+
+	acceptValues := dstReq.Header.Values("Accept")
+	if len(acceptValues) != 2 {
+		t.Errorf("expected 2 Accept values, got %d", len(acceptValues))
+	}
+
+	customValues := dstReq.Header.Values("Custom")
+	if len(customValues) != 2 {
+		t.Errorf("expected 2 Custom values, got %d", len(customValues))
+	}
+}
+
+func TestShouldSkipHeaderCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		header string
+		skip   bool
+	}{
+		{"connection", true},
+		{"CONNECTION", true},
+		{"CoNnEcTiOn", true},
+		{"keep-alive", true},
+		{"KEEP-ALIVE", true},
+		{"transfer-encoding", true},
+		{"TRANSFER-ENCODING", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			result := shouldSkipHeader(tt.header)
+			if result != tt.skip {
+				t.Errorf("shouldSkipHeader(%s) = %v, want %v", tt.header, result, tt.skip)
+			}
+		})
+	}
+}
+
+func TestAddForwardedHeadersWithExistingXFFSpaces(t *testing.T) {
+	targetURL := mustParseURL("https://target.example.com")
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  targetURL,
+	}
+	proxy, _ := New(config)
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.RemoteAddr = "192.168.1.100:12345"
+
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+	dstReq.Header.Set("X-Forwarded-For", "10.0.0.1,10.0.0.2")
+
+	proxy.addForwardedHeaders(srcReq, dstReq)
+
+	xff := dstReq.Header.Get("X-Forwarded-For")
+	expected := "10.0.0.1,10.0.0.2, 192.168.1.100"
+	if xff != expected {
+		t.Errorf("expected X-Forwarded-For to be '%s', got '%s'", expected, xff)
+	}
+}
+
+func TestBuildTargetURLWithComplexPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		targetURL string
+		reqPath   string
+		expected  string
+	}{
+		{
+			name:      "nested path with target base",
+			targetURL: "https://example.com/api/v1",
+			reqPath:   "/users/123/posts",
+			expected:  "https://example.com/api/v1/users/123/posts",
+		},
+		{
+			name:      "empty path",
+			targetURL: "https://example.com/base",
+			reqPath:   "",
+			expected:  "https://example.com/base",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL(tt.targetURL),
+			}
+			proxy, _ := New(config)
+
+			reqURL := &url.URL{Path: tt.reqPath}
+			req := &http.Request{URL: reqURL}
+
+			result := proxy.buildTargetURL(req, proxy.config.TargetURL)
+
+			if result.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, result.String())
+			}
+		})
+	}
+}
+
+func TestServeHTTPBackendTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(3 * time.Second)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		Timeout:    500 * time.Millisecond,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", resp.StatusCode)
+	}
+}
+
+func TestServeHTTPBackendUnreachable(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backendURL := mustParseURL(backend.URL)
+	backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		Timeout:    5 * time.Second,
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/test", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502 for a refused connection, got %d", w.Code)
+	}
+}
+
+func TestServeHTTPLoggingError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL("http://invalid-backend:9999")
+	var logBuf bytes.Buffer
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		Timeout:    1 * time.Second,
+		Logger:     log.New(&logBuf, "", 0),
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	logOutput := logBuf.String()
+	if !strings.Contains(logOutput, "Error proxying request") {
+		t.Error("log should contain error message")
+	}
+}
+
+func TestServeHTTPSlowRequestWarning(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(60 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	var slowBuf bytes.Buffer
+	proxy, _ := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		SlowThreshold: 30 * time.Millisecond,
+		Logger:        log.New(io.Discard, "", 0),
+		SlowLogger:    log.New(&slowBuf, "", 0),
+	})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/fast", nil)
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+	if slowBuf.Len() != 0 {
+		t.Errorf("expected no warning for a fast request, got %q", slowBuf.String())
+	}
+
+	req = httptest.NewRequest("POST", "http://localhost:8080/slow", nil)
+	req.RemoteAddr = "203.0.113.7:4321"
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	logOutput := slowBuf.String()
+	for _, want := range []string{"WARNING: slow request", "POST /slow", "203.0.113.7", "threshold 30ms"} {
+		if !strings.Contains(logOutput, want) {
+			t.Errorf("expected slow request warning to contain %q, got %q", want, logOutput)
+		}
+	}
+}
+
+func TestNewZeroTimeoutDisablesDeadline(t *testing.T) {
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("https://example.com"),
+		Timeout:    0,
+	}
+
+	proxy, err := New(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if proxy.config.Timeout != 0 {
+		t.Errorf("expected zero timeout to be preserved, got %v", proxy.config.Timeout)
+	}
+	if proxy.httpClient.Timeout != 0 {
+		t.Errorf("expected no client-wide timeout, got %v", proxy.httpClient.Timeout)
+	}
+}
+
+func TestServeHTTPWithEmptyResponse(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if len(body) != 0 {
+		t.Errorf("expected empty body, got %d bytes", len(body))
+	}
+}
+
+func TestGetClientIPWithSpacesInXFF(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com/path", nil)
+	req.RemoteAddr = "192.168.1.100:12345"
+	req.Header.Set("X-Forwarded-For", "  10.0.0.1  , 10.0.0.2 ")
+
+	trusted, _ := ParseCIDRs([]string{"192.168.0.0/16", "10.0.0.2"})
+	result := getClientIP(req, trusted, 0)
+	if result != "10.0.0.1" {
+		t.Errorf("expected 10.0.0.1, got %s", result)
+	}
+}
+
+func TestNewTransportPoolSizing(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          Config
+		expectIdle      int
+		expectIdleHost  int
+		expectConnsHost int
+		expectIdleTime  time.Duration
+		expectError     bool
+	}{
+		{
+			name: "defaults",
+			config: Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL("https://example.com"),
+			},
+			expectIdle:      DefaultMaxIdleConns,
+			expectIdleHost:  DefaultMaxIdleConnsPerHost,
+			expectConnsHost: 0,
+			expectIdleTime:  DefaultIdleConnTimeout,
+		},
+		{
+			name: "custom values",
+			config: Config{
+				ListenAddr:          ":8080",
+				TargetURL:           mustParseURL("https://example.com"),
+				MaxIdleConns:        500,
+				MaxIdleConnsPerHost: 50,
+				MaxConnsPerHost:     200,
+				IdleConnTimeout:     15 * time.Second,
+			},
+			expectIdle:      500,
+			expectIdleHost:  50,
+			expectConnsHost: 200,
+			expectIdleTime:  15 * time.Second,
+		},
+		{
+			name: "negative idle timeout",
+			config: Config{
+				ListenAddr:      ":8080",
+				TargetURL:       mustParseURL("https://example.com"),
+				IdleConnTimeout: -time.Second,
+			},
+			expectError: true,
+		},
+		{
+			name: "negative per-host limit",
+			config: Config{
+				ListenAddr:      ":8080",
+				TargetURL:       mustParseURL("https://example.com"),
+				MaxConnsPerHost: -1,
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, err := New(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			transport := proxy.httpClient.Transport.(*http.Transport)
+			if transport.MaxIdleConns != tt.expectIdle {
+				t.Errorf("expected MaxIdleConns %d, got %d", tt.expectIdle, transport.MaxIdleConns)
+			}
+			if transport.MaxIdleConnsPerHost != tt.expectIdleHost {
+				t.Errorf("expected MaxIdleConnsPerHost %d, got %d", tt.expectIdleHost, transport.MaxIdleConnsPerHost)
+			}
+			if transport.MaxConnsPerHost != tt.expectConnsHost {
+				t.Errorf("expected MaxConnsPerHost %d, got %d", tt.expectConnsHost, transport.MaxConnsPerHost)
+			}
+			if transport.IdleConnTimeout != tt.expectIdleTime {
+				t.Errorf("expected IdleConnTimeout %v, got %v", tt.expectIdleTime, transport.IdleConnTimeout)
+			}
+		})
+	}
+}
+
+func TestNewTransportTimeouts(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          Config
+		expectDial      time.Duration
+		expectKeepAlive time.Duration
+		expectTLS       time.Duration
+		expectError     bool
+	}{
+		{
+			name: "defaults",
+			config: Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL("https://example.com"),
+			},
+			expectDial:      10 * time.Second,
+			expectKeepAlive: 30 * time.Second,
+			expectTLS:       10 * time.Second,
+		},
+		{
+			name: "custom values",
+			config: Config{
+				ListenAddr:          ":8080",
+				TargetURL:           mustParseURL("https://example.com"),
+				DialTimeout:         45 * time.Second,
+				KeepAlive:           time.Minute,
+				TLSHandshakeTimeout: 20 * time.Second,
+			},
+			expectDial:      45 * time.Second,
+			expectKeepAlive: time.Minute,
+			expectTLS:       20 * time.Second,
+		},
+		{
+			name: "negative dial timeout",
+			config: Config{
+				ListenAddr:  ":8080",
+				TargetURL:   mustParseURL("https://example.com"),
+				DialTimeout: -time.Second,
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, err := New(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if proxy.config.DialTimeout != tt.expectDial {
+				t.Errorf("expected dial timeout %v, got %v", tt.expectDial, proxy.config.DialTimeout)
+			}
+			if proxy.config.KeepAlive != tt.expectKeepAlive {
+				t.Errorf("expected keep-alive %v, got %v", tt.expectKeepAlive, proxy.config.KeepAlive)
+			}
+
+			transport := proxy.httpClient.Transport.(*http.Transport)
+			if transport.TLSHandshakeTimeout != tt.expectTLS {
+				t.Errorf("expected TLS handshake timeout %v, got %v", tt.expectTLS, transport.TLSHandshakeTimeout)
+			}
+		})
+	}
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name         string
+		insecure     bool
+		expectStatus int
+	}{
+		{"verification enabled rejects self-signed", false, http.StatusBadGateway},
+		{"verification skipped accepts self-signed", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logBuf bytes.Buffer
+			config := Config{
+				ListenAddr:         ":8080",
+				TargetURL:          mustParseURL(backend.URL),
+				InsecureSkipVerify: tt.insecure,
+				Logger:             log.New(&logBuf, "", 0),
+			}
+			proxy, err := New(config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			transport := proxy.httpClient.Transport.(*http.Transport)
+			if transport.TLSClientConfig.InsecureSkipVerify != tt.insecure {
+				t.Errorf("expected InsecureSkipVerify %v", tt.insecure)
+			}
+			if tt.insecure && !strings.Contains(logBuf.String(), "WARNING") {
+				t.Error("expected a warning to be logged when verification is disabled")
+			}
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != tt.expectStatus {
+				t.Errorf("expected status %d, got %d", tt.expectStatus, w.Code)
+			}
+		})
+	}
+}
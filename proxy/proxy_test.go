@@ -0,0 +1,1236 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		expectError bool
+	}{
+		{
+			name: "valid configuration",
+			config: Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL("https://example.com"),
+				Timeout:    30 * time.Second,
+			},
+			expectError: false,
+		},
+		{
+			name: "nil target URL",
+			config: Config{
+				ListenAddr: ":8080",
+				TargetURL:  nil,
+			},
+			expectError: true,
+		},
+		{
+			name: "empty listen address",
+			config: Config{
+				ListenAddr: "",
+				TargetURL:  mustParseURL("https://example.com"),
+			},
+			expectError: true,
+		},
+		{
+			name: "zero timeout uses default",
+			config: Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL("https://example.com"),
+				Timeout:    0,
+			},
+			expectError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Logger = log.New(io.Discard, "", 0)
+			proxy, err := New(tt.config)
+
+			if tt.expectError && err == nil {
+				t.Error("expected error but got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.expectError && proxy == nil {
+				t.Error("expected proxy but got nil")
+			}
+		})
+	}
+}
+
+func TestNewWithNilLogger(t *testing.T) {
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("https://example.com"),
+		Timeout:    30 * time.Second,
+	}
+
+	proxy, err := New(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if proxy.logger == nil {
+		t.Error("expected default logger but got nil")
+	}
+}
+
+func TestBuildTargetURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		targetURL string
+		reqPath   string
+		reqQuery  string
+		expected  string
+	}{
+		{
+			name:      "simple path",
+			targetURL: "https://example.com",
+			reqPath:   "/api/v1/users",
+			reqQuery:  "",
+			expected:  "https://example.com/api/v1/users",
+		},
+		{
+			name:      "with query parameters",
+			targetURL: "https://example.com",
+			reqPath:   "/api/v1/users",
+			reqQuery:  "page=1&limit=10",
+			expected:  "https://example.com/api/v1/users?page=1&limit=10",
+		},
+		{
+			name:      "target with base path",
+			targetURL: "https://example.com/base",
+			reqPath:   "/api/users",
+			reqQuery:  "",
+			expected:  "https://example.com/base/api/users",
+		},
+		{
+			name:      "target with trailing slash",
+			targetURL: "https://example.com/base/",
+			reqPath:   "/api/users",
+			reqQuery:  "",
+			expected:  "https://example.com/base/api/users",
+		},
+		{
+			name:      "root path",
+			targetURL: "https://example.com",
+			reqPath:   "/",
+			reqQuery:  "",
+			expected:  "https://example.com/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := log.New(io.Discard, "", 0)
+			config := Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL(tt.targetURL),
+				Logger:     logger,
+			}
+			proxy, _ := New(config)
+
+			reqURL := &url.URL{
+				Path:     tt.reqPath,
+				RawQuery: tt.reqQuery,
+			}
+			req := &http.Request{URL: reqURL}
+
+			result := proxy.buildTargetURL(req, proxy.config.TargetURL)
+
+			if result.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, result.String())
+			}
+		})
+	}
+}
+
+func TestCopyHeaders(t *testing.T) {
+	targetURL := mustParseURL("https://target.example.com")
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  targetURL,
+		Logger:     logger,
+	}
+	proxy, _ := New(config)
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.Header.Set("User-Agent", "test-agent")
+	srcReq.Header.Set("Accept", "application/json")
+	srcReq.Header.Set("Connection", "keep-alive")
+	srcReq.Header.Set("Custom-Header", "custom-value")
+
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+
+	proxy.copyHeaders(srcReq, dstReq, nil)
+
+	if dstReq.Header.Get("User-Agent") != "test-agent" {
+		t.Error("User-Agent header not copied")
+	}
+	if dstReq.Header.Get("Accept") != "application/json" {
+		t.Error("Accept header not copied")
+	}
+	if dstReq.Header.Get("Custom-Header") != "custom-value" {
+		t.Error("Custom-Header not copied")
+	}
+	if dstReq.Header.Get("Connection") != "" {
+		t.Error("Connection header should be skipped")
+	}
+	if dstReq.Host != "target.example.com" {
+		t.Errorf("Host should be set to target host, got %s", dstReq.Host)
+	}
+}
+
+func TestCopyHeadersAddHeaders(t *testing.T) {
+	proxy, _ := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL("https://target.example.com"),
+		CustomHeaders: map[string]string{"X-Env": "prod", "X-Tags": "replaced"},
+		AddHeaders:    map[string][]string{"X-Tags": {"beta", "canary"}, "x-team": {"core"}},
+		Logger:        log.New(io.Discard, "", 0),
+	})
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.Header.Add("X-Team", "client")
+	srcReq.Header.Set("X-Env", "dev")
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+
+	proxy.copyHeaders(srcReq, dstReq, nil)
+
+	// -H replaces the client's value; -H-add keeps it
+	if got := dstReq.Header.Values("X-Env"); len(got) != 1 || got[0] != "prod" {
+		t.Errorf("expected X-Env to be replaced with prod, got %v", got)
+	}
+	if got := strings.Join(dstReq.Header.Values("X-Team"), ","); got != "client,core" {
+		t.Errorf("expected X-Team client,core, got %q", got)
+	}
+	if got := strings.Join(dstReq.Header.Values("X-Tags"), ","); got != "replaced,beta,canary" {
+		t.Errorf("expected added X-Tags after the -H value, got %q", got)
+	}
+}
+
+func TestAddForwardedHeadersViaAndPort(t *testing.T) {
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("https://target.example.com"),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.RemoteAddr = "192.168.1.100:12345"
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+
+	proxy.addForwardedHeaders(srcReq, dstReq)
+
+	if via := dstReq.Header.Get("Via"); via != "1.1 goreflector/"+Version {
+		t.Errorf("expected Via '1.1 goreflector/%s', got %q", Version, via)
+	}
+	if port := dstReq.Header.Get("X-Forwarded-Port"); port != "8080" {
+		t.Errorf("expected X-Forwarded-Port from listen address, got %q", port)
+	}
+	if proto := dstReq.Header.Get("X-Forwarded-Proto"); proto != "http" {
+		t.Errorf("expected X-Forwarded-Proto http, got %q", proto)
+	}
+}
+
+func TestAddForwardedHeadersViaAppends(t *testing.T) {
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("https://target.example.com"),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.Header.Set("Via", "1.1 edge-lb")
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+
+	proxy.copyHeaders(srcReq, dstReq, nil)
+	proxy.addForwardedHeaders(srcReq, dstReq)
+
+	expected := "1.1 edge-lb, 1.1 goreflector/" + Version
+	if via := dstReq.Header.Get("Via"); via != expected {
+		t.Errorf("expected Via %q, got %q", expected, via)
+	}
+}
+
+func TestServeHTTPForwardedPortAndProtoOverTLS(t *testing.T) {
+	var received http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	// The proxy terminating TLS itself is reported to the backend as https
+	// on the port the client actually connected to
+	server := httptest.NewTLSServer(proxy)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/secure")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if proto := received.Get("X-Forwarded-Proto"); proto != "https" {
+		t.Errorf("expected X-Forwarded-Proto https, got %q", proto)
+	}
+	if port := received.Get("X-Forwarded-Port"); port != mustParseURL(server.URL).Port() {
+		t.Errorf("expected X-Forwarded-Port %s, got %q", mustParseURL(server.URL).Port(), port)
+	}
+	if !strings.Contains(received.Get("Via"), "goreflector/") {
+		t.Errorf("expected Via header, got %q", received.Get("Via"))
+	}
+}
+
+func TestAddForwardedHeaders(t *testing.T) {
+	targetURL := mustParseURL("https://target.example.com")
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  targetURL,
+		Logger:     logger,
+	}
+	proxy, _ := New(config)
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.RemoteAddr = "192.168.1.100:12345"
+	srcReq.Host = "source.example.com"
+
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+
+	proxy.addForwardedHeaders(srcReq, dstReq)
+
+	if xff := dstReq.Header.Get("X-Forwarded-For"); xff != "192.168.1.100" {
+		t.Errorf("expected X-Forwarded-For to be 192.168.1.100, got %s", xff)
+	}
+	if xfh := dstReq.Header.Get("X-Forwarded-Host"); xfh != "source.example.com" {
+		t.Errorf("expected X-Forwarded-Host to be source.example.com, got %s", xfh)
+	}
+	if xfp := dstReq.Header.Get("X-Forwarded-Proto"); xfp != "http" {
+		t.Errorf("expected X-Forwarded-Proto to be http, got %s", xfp)
+	}
+}
+
+func TestServeHTTPDisableForwardedHeaders(t *testing.T) {
+	var got http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	all := []string{"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto", "X-Forwarded-Port", "Via"}
+	tests := []struct {
+		name     string
+		config   Config
+		expected []string
+	}{
+		{"default adds all", Config{}, all},
+		{"no forwarded headers", Config{NoForwardedHeaders: true}, nil},
+		{"no xff", Config{NoXForwardedFor: true}, []string{"X-Forwarded-Host", "X-Forwarded-Proto", "X-Forwarded-Port", "Via"}},
+		{"no xfh", Config{NoXForwardedHost: true}, []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Port", "Via"}},
+		{"no xfp", Config{NoXForwardedProto: true}, []string{"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Port", "Via"}},
+		{"no xff and xfh", Config{NoXForwardedFor: true, NoXForwardedHost: true}, []string{"X-Forwarded-Proto", "X-Forwarded-Port", "Via"}},
+		{"no xff and xfp", Config{NoXForwardedFor: true, NoXForwardedProto: true}, []string{"X-Forwarded-Host", "X-Forwarded-Port", "Via"}},
+		{"no xfh and xfp", Config{NoXForwardedHost: true, NoXForwardedProto: true}, []string{"X-Forwarded-For", "X-Forwarded-Port", "Via"}},
+		{"no xff, xfh and xfp", Config{NoXForwardedFor: true, NoXForwardedHost: true, NoXForwardedProto: true}, []string{"X-Forwarded-Port", "Via"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.ListenAddr = ":8080"
+			config.TargetURL = mustParseURL(backend.URL)
+			config.Logger = log.New(io.Discard, "", 0)
+			proxy, err := New(config)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			req := httptest.NewRequest("GET", "http://source.example.com/path", nil)
+			req.RemoteAddr = "192.168.1.100:12345"
+			proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+			for _, name := range all {
+				want := slices.Contains(tt.expected, name)
+				if has := got.Get(name) != ""; has != want {
+					t.Errorf("%s: expected present=%v, got %q", name, want, got.Get(name))
+				}
+			}
+		})
+	}
+}
+
+func TestAddForwardedHeadersRFC7239(t *testing.T) {
+	proxy, _ := New(Config{
+		ListenAddr:       ":8080",
+		TargetURL:        mustParseURL("https://target.example.com"),
+		ForwardedRFC7239: true,
+		Logger:           log.New(io.Discard, "", 0),
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		host       string
+		prior      string
+		expected   string
+	}{
+		{"ipv4", "192.0.2.60:12345", "example.com", "", "for=192.0.2.60;host=example.com;proto=http"},
+		{"ipv6 is bracketed and quoted", "[2001:db8:cafe::17]:4711", "example.com", "", `for="[2001:db8:cafe::17]";host=example.com;proto=http`},
+		{"host with port is quoted", "192.0.2.60:12345", "example.com:8080", "", `for=192.0.2.60;host="example.com:8080";proto=http`},
+		{"appends to existing", "192.0.2.60:12345", "example.com", "for=198.51.100.17;proto=https", "for=198.51.100.17;proto=https, for=192.0.2.60;host=example.com;proto=http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := httptest.NewRequest("GET", "http://example.com/path", nil)
+			src.RemoteAddr = tt.remoteAddr
+			src.Host = tt.host
+			dst, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+			if tt.prior != "" {
+				dst.Header.Set("Forwarded", tt.prior)
+			}
+
+			proxy.addForwardedHeaders(src, dst)
+
+			if got := dst.Header.Get("Forwarded"); got != tt.expected {
+				t.Errorf("expected Forwarded %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	// Off by default
+	proxy, _ = New(Config{ListenAddr: ":8080", TargetURL: mustParseURL("https://target.example.com")})
+	dst, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+	proxy.addForwardedHeaders(httptest.NewRequest("GET", "http://example.com/", nil), dst)
+	if got := dst.Header.Get("Forwarded"); got != "" {
+		t.Errorf("expected no Forwarded header by default, got %q", got)
+	}
+}
+
+func TestAddForwardedHeadersAppendXFF(t *testing.T) {
+	targetURL := mustParseURL("https://target.example.com")
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  targetURL,
+		Logger:     logger,
+	}
+	proxy, _ := New(config)
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.RemoteAddr = "192.168.1.100:12345"
+
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+	dstReq.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	proxy.addForwardedHeaders(srcReq, dstReq)
+
+	xff := dstReq.Header.Get("X-Forwarded-For")
+	if xff != "10.0.0.1, 192.168.1.100" {
+		t.Errorf("expected X-Forwarded-For to be '10.0.0.1, 192.168.1.100', got %s", xff)
+	}
+}
+
+func TestShouldSkipHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		skip   bool
+	}{
+		{"Connection", true},
+		{"Keep-Alive", true},
+		{"Proxy-Authenticate", true},
+		{"Proxy-Authorization", true},
+		{"Te", true},
+		{"Trailers", true},
+		{"Transfer-Encoding", true},
+		{"Upgrade", true},
+		{"Content-Type", false},
+		{"User-Agent", false},
+		{"Accept", false},
+		{"Authorization", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			result := shouldSkipHeader(tt.header)
+			if result != tt.skip {
+				t.Errorf("shouldSkipHeader(%s) = %v, want %v", tt.header, result, tt.skip)
+			}
+		})
+	}
+}
+
+func TestGetClientIP(t *testing.T) {
+	trusted, _ := ParseCIDRs([]string{"192.168.0.0/16", "172.16.0.1"})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		realIP     string
+		expected   string
+	}{
+		{
+			name:       "from RemoteAddr",
+			remoteAddr: "192.168.1.100:12345",
+			xff:        "",
+			realIP:     "",
+			expected:   "192.168.1.100",
+		},
+		{
+			name:       "from X-Forwarded-For",
+			remoteAddr: "192.168.1.100:12345",
+			xff:        "10.0.0.1, 10.0.0.2",
+			realIP:     "",
+			expected:   "10.0.0.2",
+		},
+		{
+			name:       "skips trusted hops right to left",
+			remoteAddr: "192.168.1.100:12345",
+			xff:        "10.0.0.1, 10.0.0.2, 172.16.0.1, 192.168.5.5",
+			realIP:     "",
+			expected:   "10.0.0.2",
+		},
+		{
+			name:       "all hops trusted",
+			remoteAddr: "192.168.1.100:12345",
+			xff:        "172.16.0.1, 192.168.5.5",
+			realIP:     "",
+			expected:   "172.16.0.1",
+		},
+		{
+			name:       "malformed hop stops the walk",
+			remoteAddr: "192.168.1.100:12345",
+			xff:        "10.0.0.1, not-an-ip, 192.168.5.5",
+			realIP:     "",
+			expected:   "192.168.5.5",
+		},
+		{
+			name:       "from X-Real-IP",
+			remoteAddr: "192.168.1.100:12345",
+			xff:        "",
+			realIP:     "10.0.0.5",
+			expected:   "10.0.0.5",
+		},
+		{
+			name:       "X-Forwarded-For takes precedence",
+			remoteAddr: "192.168.1.100:12345",
+			xff:        "10.0.0.1",
+			realIP:     "10.0.0.5",
+			expected:   "10.0.0.1",
+		},
+		{
+			name:       "spoofed X-Forwarded-For from untrusted peer",
+			remoteAddr: "203.0.113.9:12345",
+			xff:        "10.0.0.1",
+			realIP:     "",
+			expected:   "203.0.113.9",
+		},
+		{
+			name:       "spoofed X-Real-IP from untrusted peer",
+			remoteAddr: "203.0.113.9:12345",
+			xff:        "",
+			realIP:     "10.0.0.5",
+			expected:   "203.0.113.9",
+		},
+		{
+			name:       "RemoteAddr without port",
+			remoteAddr: "192.168.1.100",
+			xff:        "",
+			realIP:     "",
+			expected:   "192.168.1.100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://example.com/path", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			result := getClientIP(req, trusted, 0)
+			if result != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetClientIPWithoutTrustedProxies(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com/path", nil)
+	req.RemoteAddr = "192.168.1.100:12345"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("X-Real-IP", "10.0.0.5")
+
+	if result := getClientIP(req, nil, 0); result != "192.168.1.100" {
+		t.Errorf("expected forwarding headers to be ignored without trusted proxies, got %s", result)
+	}
+}
+
+func TestGetClientIPTrustedHops(t *testing.T) {
+	trusted, _ := ParseCIDRs([]string{"192.168.0.0/16", "10.0.0.0/8"})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		hops       int
+		expected   string
+	}{
+		{"one hop", "192.168.1.1:12345", "203.0.113.5", 1, "203.0.113.5"},
+		{"one hop ignores spoofed entries", "192.168.1.1:12345", "198.51.100.7, 203.0.113.5", 1, "203.0.113.5"},
+		{"two hops", "192.168.1.1:12345", "198.51.100.7, 203.0.113.5, 10.0.0.2", 2, "203.0.113.5"},
+		{"client inside a trusted range", "192.168.1.1:12345", "198.51.100.9, 10.1.2.3, 10.0.0.2", 2, "10.1.2.3"},
+		{"skipping trusted entries without hops", "192.168.1.1:12345", "198.51.100.9, 10.1.2.3, 10.0.0.2", 0, "198.51.100.9"},
+		{"untrusted nearer hop", "192.168.1.1:12345", "198.51.100.7, 203.0.113.5, 203.0.113.99, 10.0.0.2", 3, "203.0.113.99"},
+		{"chain shorter than hops", "192.168.1.1:12345", "203.0.113.5", 3, "203.0.113.5"},
+		{"invalid entry", "192.168.1.1:12345", "203.0.113.5, not-an-ip", 2, "192.168.1.1"},
+		{"untrusted peer", "203.0.113.9:12345", "198.51.100.7, 10.0.0.2", 2, "203.0.113.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://example.com/path", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.xff)
+
+			if result := getClientIP(req, trusted, tt.hops); result != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewRejectsXFFTrustedHopsWithoutTrustedProxies(t *testing.T) {
+	_, err := New(Config{ListenAddr: ":8080", TargetURL: mustParseURL("http://localhost:9000"), XFFTrustedHops: 2})
+	if err == nil {
+		t.Error("expected -xff-trusted-hops without trusted proxies to be rejected")
+	}
+}
+
+func TestServeHTTPSpoofedClientIPDoesNotBypassACL(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	allow, _ := ParseCIDRs([]string{"10.0.0.0/8"})
+	trusted, _ := ParseCIDRs([]string{"192.168.0.0/16"})
+	proxy, _ := New(Config{
+		ListenAddr:     ":8080",
+		TargetURL:      mustParseURL(backend.URL),
+		AllowCIDRs:     allow,
+		TrustedProxies: trusted,
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		expected   int
+	}{
+		{"untrusted peer", "203.0.113.9:12345", http.StatusForbidden},
+		{"trusted proxy", "192.168.1.1:12345", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "10.0.0.1")
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend-Response", "true")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("backend response"))
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		Timeout:    30 * time.Second,
+		Logger:     logger,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	req.Header.Set("User-Agent", "test-client")
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "backend response" {
+		t.Errorf("expected 'backend response', got %s", string(body))
+	}
+
+	if resp.Header.Get("X-Backend-Response") != "true" {
+		t.Error("backend response header not copied")
+	}
+}
+
+func TestServeHTTPWithDifferentMethods(t *testing.T) {
+	methods := []string{"GET", "POST", "PUT", "DELETE", "PATCH"}
+
+	for _, method := range methods {
+		t.Run(method, func(t *testing.T) {
+			var receivedMethod string
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedMethod = r.Method
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer backend.Close()
+
+			backendURL := mustParseURL(backend.URL)
+			logger := log.New(io.Discard, "", 0)
+			config := Config{
+				ListenAddr: ":8080",
+				TargetURL:  backendURL,
+				Logger:     logger,
+			}
+			proxy, _ := New(config)
+
+			var body io.Reader
+			if method == "POST" || method == "PUT" || method == "PATCH" {
+				body = strings.NewReader("test data")
+			}
+
+			req := httptest.NewRequest(method, "http://localhost:8080/test", body)
+			w := httptest.NewRecorder()
+
+			proxy.ServeHTTP(w, req)
+
+			if receivedMethod != method {
+				t.Errorf("expected method %s, got %s", method, receivedMethod)
+			}
+		})
+	}
+}
+
+func TestServeHTTPWithBody(t *testing.T) {
+	var receivedBody []byte
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		Logger:     logger,
+	}
+	proxy, _ := New(config)
+
+	testData := "test request body"
+	req := httptest.NewRequest("POST", "http://localhost:8080/test", strings.NewReader(testData))
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	if string(receivedBody) != testData {
+		t.Errorf("expected body %s, got %s", testData, string(receivedBody))
+	}
+}
+
+func TestServeHTTPBackendError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("backend error"))
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		Logger:     logger,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "backend error" {
+		t.Errorf("expected 'backend error', got %s", string(body))
+	}
+}
+
+func TestServeHTTPInvalidBackend(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("http://invalid-backend-that-does-not-exist.local:9999"),
+		Timeout:    1 * time.Second,
+		Logger:     logger,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", resp.StatusCode)
+	}
+}
+
+func TestServeHTTPPreservesQueryParams(t *testing.T) {
+	var receivedQuery string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedQuery = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		Logger:     logger,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test?foo=bar&baz=qux", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	if receivedQuery != "foo=bar&baz=qux" {
+		t.Errorf("expected query 'foo=bar&baz=qux', got %s", receivedQuery)
+	}
+}
+
+func TestServeHTTPLogging(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	var logBuf bytes.Buffer
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		Logger:     log.New(&logBuf, "", 0),
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	logOutput := logBuf.String()
+	if !strings.Contains(logOutput, "GET") {
+		t.Error("log should contain HTTP method")
+	}
+	if !strings.Contains(logOutput, "/test") {
+		t.Error("log should contain request path")
+	}
+}
+
+func TestCopyHeadersWithCustomHost(t *testing.T) {
+	var receivedHost string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHost = r.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		CustomHeaders: map[string]string{
+			"Host": "example.com",
+		},
+		Logger: logger,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	if receivedHost != "example.com" {
+		t.Errorf("expected Host header 'example.com', got '%s'", receivedHost)
+	}
+}
+
+func TestCopyHeadersWithCustomHeaders(t *testing.T) {
+	var receivedHeaders http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeaders = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		CustomHeaders: map[string]string{
+			"Authorization": "Bearer token123",
+			"X-API-Key":     "key456",
+			"X-Custom":      "custom-value",
+		},
+		Logger: logger,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	if receivedHeaders.Get("Authorization") != "Bearer token123" {
+		t.Errorf("expected Authorization 'Bearer token123', got '%s'", receivedHeaders.Get("Authorization"))
+	}
+	if receivedHeaders.Get("X-API-Key") != "key456" {
+		t.Errorf("expected X-API-Key 'key456', got '%s'", receivedHeaders.Get("X-API-Key"))
+	}
+	if receivedHeaders.Get("X-Custom") != "custom-value" {
+		t.Errorf("expected X-Custom 'custom-value', got '%s'", receivedHeaders.Get("X-Custom"))
+	}
+}
+
+func TestCopyHeadersWithHostAndCustomHeaders(t *testing.T) {
+	var receivedHost string
+	var receivedHeaders http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHost = r.Host
+		receivedHeaders = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		CustomHeaders: map[string]string{
+			"Host":          "example.com",
+			"Authorization": "Bearer token",
+			"X-Custom":      "value",
+		},
+		Logger: logger,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	if receivedHost != "example.com" {
+		t.Errorf("expected Host 'example.com', got '%s'", receivedHost)
+	}
+	if receivedHeaders.Get("Authorization") != "Bearer token" {
+		t.Errorf("expected Authorization 'Bearer token', got '%s'", receivedHeaders.Get("Authorization"))
+	}
+	if receivedHeaders.Get("X-Custom") != "value" {
+		t.Errorf("expected X-Custom 'value', got '%s'", receivedHeaders.Get("X-Custom"))
+	}
+}
+
+func TestCopyHeadersCustomOverridesOriginal(t *testing.T) {
+	var receivedHeaders http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeaders = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL := mustParseURL(backend.URL)
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  backendURL,
+		CustomHeaders: map[string]string{
+			"User-Agent": "CustomAgent/1.0",
+		},
+		Logger: logger,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	req.Header.Set("User-Agent", "OriginalAgent/1.0")
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	// Custom header should override the original
+	if receivedHeaders.Get("User-Agent") != "CustomAgent/1.0" {
+		t.Errorf("expected User-Agent 'CustomAgent/1.0', got '%s'", receivedHeaders.Get("User-Agent"))
+	}
+}
+
+func TestCopyHeadersHostCaseInsensitive(t *testing.T) {
+	// Test that "host", "Host", "HOST" all work correctly
+	testCases := []string{"host", "Host", "HOST", "HoSt"}
+
+	for _, headerName := range testCases {
+		t.Run(headerName, func(t *testing.T) {
+			var receivedHost string
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedHost = r.Host
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer backend.Close()
+
+			backendURL := mustParseURL(backend.URL)
+			logger := log.New(io.Discard, "", 0)
+			config := Config{
+				ListenAddr: ":8080",
+				TargetURL:  backendURL,
+				CustomHeaders: map[string]string{
+					headerName: "example.com",
+				},
+				Logger: logger,
+			}
+			proxy, _ := New(config)
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+			w := httptest.NewRecorder()
+
+			proxy.ServeHTTP(w, req)
+
+			if receivedHost != "example.com" {
+				t.Errorf("expected Host 'example.com', got '%s'", receivedHost)
+			}
+		})
+	}
+}
+
+func TestCopyHeadersStripsConnectionTokens(t *testing.T) {
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("https://target.example.com"),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.Header.Add("Connection", "x-session-token, close")
+	srcReq.Header.Add("Connection", " X-Hop-Only ")
+	srcReq.Header.Set("X-Session-Token", "secret")
+	srcReq.Header.Set("X-Hop-Only", "1")
+	srcReq.Header.Set("X-End-To-End", "kept")
+
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+	proxy.copyHeaders(srcReq, dstReq, nil)
+
+	for _, name := range []string{"Connection", "X-Session-Token", "X-Hop-Only"} {
+		if dstReq.Header.Get(name) != "" {
+			t.Errorf("expected %s to be stripped", name)
+		}
+	}
+	if dstReq.Header.Get("X-End-To-End") != "kept" {
+		t.Error("expected headers not named in Connection to be forwarded")
+	}
+}
+
+func TestCopyHeadersStripHeaders(t *testing.T) {
+	proxy, _ := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL("https://target.example.com"),
+		StripHeaders:  []string{"cookie", "X-DEBUG"},
+		CustomHeaders: map[string]string{"X-Debug": "from-proxy"},
+		Logger:        log.New(io.Discard, "", 0),
+	})
+
+	srcReq, _ := http.NewRequest("GET", "http://source.example.com/path", nil)
+	srcReq.Header.Set("Cookie", "session=abc")
+	srcReq.Header.Set("X-Debug", "from-client")
+	srcReq.Header.Set("Accept", "text/html")
+
+	dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+	proxy.copyHeaders(srcReq, dstReq, nil)
+
+	if dstReq.Header.Get("Cookie") != "" {
+		t.Error("expected Cookie to be stripped")
+	}
+	if got := dstReq.Header.Get("X-Debug"); got != "from-proxy" {
+		t.Errorf("expected custom header to still apply after stripping, got %q", got)
+	}
+	if dstReq.Header.Get("Accept") != "text/html" {
+		t.Error("expected other headers to be forwarded")
+	}
+}
+
+func TestCopyHeadersPreserveHost(t *testing.T) {
+	tests := []struct {
+		name          string
+		preserveHost  bool
+		customHeaders map[string]string
+		expectedHost  string
+	}{
+		{"default uses target host", false, nil, "target.example.com"},
+		{"preserve host keeps client host", true, nil, "client.example.com:8080"},
+		{"custom host wins over preserve host", true, map[string]string{"Host": "override.example.com"}, "override.example.com"},
+		{"custom host wins over target host", false, map[string]string{"Host": "override.example.com"}, "override.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, _ := New(Config{
+				ListenAddr:    ":8080",
+				TargetURL:     mustParseURL("https://target.example.com"),
+				PreserveHost:  tt.preserveHost,
+				CustomHeaders: tt.customHeaders,
+				Logger:        log.New(io.Discard, "", 0),
+			})
+
+			srcReq, _ := http.NewRequest("GET", "http://client.example.com:8080/path", nil)
+			dstReq, _ := http.NewRequest("GET", "https://target.example.com/path", nil)
+			proxy.copyHeaders(srcReq, dstReq, nil)
+
+			if dstReq.Host != tt.expectedHost {
+				t.Errorf("expected Host %q, got %q", tt.expectedHost, dstReq.Host)
+			}
+		})
+	}
+}
+
+func mustParseURL(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func TestServeHTTPResponseHeaderOverrides(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Untouched", "value")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	logger := log.New(io.Discard, "", 0)
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		ResponseHeaders: map[string]string{
+			"cache-control":   "no-store",
+			"Server":          "",
+			"X-Frame-Options": "DENY",
+		},
+		Logger: logger,
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("expected Cache-Control no-store, got %q", cc)
+	}
+	if _, ok := resp.Header["Server"]; ok {
+		t.Errorf("expected Server header to be removed, got %q", resp.Header.Get("Server"))
+	}
+	if xfo := resp.Header.Get("X-Frame-Options"); xfo != "DENY" {
+		t.Errorf("expected X-Frame-Options DENY, got %q", xfo)
+	}
+	if v := resp.Header.Get("X-Untouched"); v != "value" {
+		t.Errorf("expected X-Untouched to pass through, got %q", v)
+	}
+}
+
+func TestServeHTTPStripResponseHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25")
+		w.Header().Set("X-Powered-By", "PHP/8.3")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:           ":8080",
+		TargetURL:            mustParseURL(backend.URL),
+		StripResponseHeaders: []string{"server", "X-POWERED-BY"},
+		Logger:               log.New(io.Discard, "", 0),
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/test", nil))
+
+	resp := w.Result()
+	if _, ok := resp.Header["Server"]; ok {
+		t.Errorf("expected Server header to be stripped, got %q", resp.Header.Get("Server"))
+	}
+	if _, ok := resp.Header["X-Powered-By"]; ok {
+		t.Errorf("expected X-Powered-By header to be stripped, got %q", resp.Header.Get("X-Powered-By"))
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("expected Content-Type to pass through, got %q", ct)
+	}
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// proxyProtocolMaxHeader is the longest v1 header allowed by the spec,
+	// including the trailing CRLF
+	proxyProtocolMaxHeader = 107
+
+	proxyProtocolHeaderTimeout = 5 * time.Second
+)
+
+var errInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+
+// proxyProtocolListener wraps accepted connections so the HAProxy PROXY
+// protocol v1 preamble sent by an upstream load balancer is consumed and the
+// client address it carries is reported as the connection's remote address.
+type proxyProtocolListener struct {
+	net.Listener
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtocolConn{Conn: conn, reader: bufio.NewReaderSize(conn, proxyProtocolMaxHeader)}, nil
+}
+
+// proxyProtocolConn parses the header lazily on first use, so a slow client
+// only stalls its own connection goroutine rather than the accept loop.
+type proxyProtocolConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyProtocolConn) init() {
+	c.once.Do(func() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+		defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+
+		addr, err := readProxyHeader(c.reader)
+		if err != nil {
+			// Malformed preambles drop the connection outright
+			c.err = err
+			_ = c.Conn.Close()
+			return
+		}
+		c.remoteAddr = addr
+	})
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader consumes a PROXY protocol v1 header. It returns a nil
+// address for "PROXY UNKNOWN", in which case the real peer address is kept.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidProxyHeader, err)
+	}
+	if len(line) > proxyProtocolMaxHeader || !strings.HasSuffix(string(line), "\r\n") {
+		return nil, errInvalidProxyHeader
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, errInvalidProxyHeader
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, errInvalidProxyHeader
+	}
+
+	if len(fields) != 6 {
+		return nil, errInvalidProxyHeader
+	}
+
+	srcIP := net.ParseIP(fields[2])
+	dstIP := net.ParseIP(fields[3])
+	if srcIP == nil || dstIP == nil || (srcIP.To4() != nil) != (fields[1] == "TCP4") {
+		return nil, errInvalidProxyHeader
+	}
+
+	srcPort, err := parseProxyPort(fields[4])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parseProxyPort(fields[5]); err != nil {
+		return nil, err
+	}
+
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, nil
+}
+
+func parseProxyPort(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 || strconv.Itoa(port) != value {
+		return 0, errInvalidProxyHeader
+	}
+	return port, nil
+}
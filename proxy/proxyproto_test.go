@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadProxyHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		expectAddr  string
+		expectError bool
+	}{
+		{"tcp4", "PROXY TCP4 203.0.113.7 10.0.0.1 56324 8080\r\n", "203.0.113.7:56324", false},
+		{"tcp6", "PROXY TCP6 2001:db8::1 2001:db8::2 4000 443\r\n", "[2001:db8::1]:4000", false},
+		{"unknown keeps peer address", "PROXY UNKNOWN\r\n", "", false},
+		{"missing CRLF", "PROXY TCP4 203.0.113.7 10.0.0.1 56324 8080\n", "", true},
+		{"wrong signature", "GET / HTTP/1.1\r\n", "", true},
+		{"unsupported protocol", "PROXY UDP4 203.0.113.7 10.0.0.1 1 2\r\n", "", true},
+		{"missing fields", "PROXY TCP4 203.0.113.7 10.0.0.1 56324\r\n", "", true},
+		{"invalid address", "PROXY TCP4 not-an-ip 10.0.0.1 56324 8080\r\n", "", true},
+		{"family mismatch", "PROXY TCP4 2001:db8::1 10.0.0.1 56324 8080\r\n", "", true},
+		{"port out of range", "PROXY TCP4 203.0.113.7 10.0.0.1 70000 8080\r\n", "", true},
+		{"port with leading zero", "PROXY TCP4 203.0.113.7 10.0.0.1 080 8080\r\n", "", true},
+		{"too long", "PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReaderSize(strings.NewReader(tt.header+"GET / HTTP/1.1\r\n"), proxyProtocolMaxHeader)
+			addr, err := readProxyHeader(reader)
+			if tt.expectError {
+				if !errors.Is(err, errInvalidProxyHeader) {
+					t.Errorf("expected invalid header error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tt.expectAddr {
+				t.Errorf("expected address %q, got %q", tt.expectAddr, got)
+			}
+
+			rest, _ := reader.ReadString('\n')
+			if rest != "GET / HTTP/1.1\r\n" {
+				t.Errorf("expected the header to be consumed exactly, got remaining %q", rest)
+			}
+		})
+	}
+}
+
+func startProxyProtocolServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() { _ = server.Serve(&proxyProtocolListener{Listener: listener}) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return listener.Addr().String()
+}
+
+func TestProxyProtocolClientAddress(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Forwarded-For")))
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		ProxyProtocol: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	addr := startProxyProtocolServer(t, proxy)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	_, err = io.WriteString(conn, "PROXY TCP4 203.0.113.7 10.0.0.1 56324 8080\r\n"+
+		"GET / HTTP/1.1\r\nHost: proxy.local\r\nConnection: close\r\n\r\n")
+	if err != nil {
+		t.Fatalf("failed to write request: %v", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "203.0.113.7" {
+		t.Errorf("expected client address from PROXY header, got %q", body)
+	}
+}
+
+func TestProxyProtocolMalformedHeaderDropsConnection(t *testing.T) {
+	addr := startProxyProtocolServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be reached for a malformed PROXY header")
+	}))
+
+	for _, preamble := range []string{"PROXY BOGUS\r\n", ""} {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+
+		_, _ = io.WriteString(conn, preamble+"GET / HTTP/1.1\r\nHost: proxy.local\r\n\r\n")
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		data, err := io.ReadAll(conn)
+		var netErr net.Error
+		if len(data) != 0 || (errors.As(err, &netErr) && netErr.Timeout()) {
+			t.Errorf("expected connection to be closed without a response for %q, got %q (err %v)", preamble, data, err)
+		}
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// QueryParam is a query parameter set on every forwarded request, replacing
+// any values the client sent for the same key.
+type QueryParam struct {
+	Key   string
+	Value string
+}
+
+// ParseQueryParams parses -set-query values of the form "key=value". The
+// first "=" separates the two, so values may contain one.
+func ParseQueryParams(values []string) ([]QueryParam, error) {
+	params := make([]QueryParam, 0, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid query parameter %q (expected 'key=value')", value)
+		}
+		params = append(params, QueryParam{Key: key, Value: val})
+	}
+	return params, nil
+}
+
+// editQuery applies the configured query parameter changes to rawQuery. It
+// works on the raw string rather than url.Values so untouched parameters
+// keep their order and original encoding. A set parameter takes the place
+// of the first existing value for its key, or is appended when absent.
+func (p *Proxy) editQuery(rawQuery string) string {
+	if len(p.config.SetQuery) == 0 && len(p.config.RemoveQuery) == 0 {
+		return rawQuery
+	}
+
+	remove := make(map[string]bool, len(p.config.RemoveQuery))
+	for _, key := range p.config.RemoveQuery {
+		remove[key] = true
+	}
+	set := make(map[string]string, len(p.config.SetQuery))
+	for _, param := range p.config.SetQuery {
+		set[param.Key] = encodeQueryParam(param)
+	}
+
+	var parts []string
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(part, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+
+		if replacement, ok := set[key]; ok {
+			if replacement != "" {
+				parts = append(parts, replacement)
+				set[key] = ""
+			}
+			continue
+		}
+		if !remove[key] {
+			parts = append(parts, part)
+		}
+	}
+
+	for _, param := range p.config.SetQuery {
+		if replacement := set[param.Key]; replacement != "" {
+			parts = append(parts, replacement)
+			set[param.Key] = ""
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func encodeQueryParam(param QueryParam) string {
+	return url.QueryEscape(param.Key) + "=" + url.QueryEscape(param.Value)
+}
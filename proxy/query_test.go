@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseQueryParams(t *testing.T) {
+	params, err := ParseQueryParams([]string{"api_key=abc", "filter=a=b", "empty="})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(params) != 3 || params[1] != (QueryParam{Key: "filter", Value: "a=b"}) || params[2].Value != "" {
+		t.Errorf("unexpected params: %+v", params)
+	}
+
+	for _, value := range []string{"novalue", "=value"} {
+		if _, err := ParseQueryParams([]string{value}); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestBuildTargetURLEditsQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      []QueryParam
+		remove   []string
+		query    string
+		expected string
+	}{
+		{
+			name:     "injects a new parameter after existing ones",
+			set:      []QueryParam{{Key: "api_key", Value: "s3cret"}},
+			query:    "b=2&a=1",
+			expected: "b=2&a=1&api_key=s3cret",
+		},
+		{
+			name:     "overrides an existing parameter in place",
+			set:      []QueryParam{{Key: "page", Value: "1"}},
+			query:    "q=go&page=5&sort=asc&page=6",
+			expected: "q=go&page=1&sort=asc",
+		},
+		{
+			name:     "removes every value of a parameter",
+			remove:   []string{"debug"},
+			query:    "debug=1&q=go&debug=2",
+			expected: "q=go",
+		},
+		{
+			name:     "keeps the encoding of untouched parameters",
+			set:      []QueryParam{{Key: "tag", Value: "a b&c"}},
+			remove:   []string{"trace id"},
+			query:    "q=caf%C3%A9+au+lait&trace%20id=x&z=%2F",
+			expected: "q=caf%C3%A9+au+lait&z=%2F&tag=a+b%26c",
+		},
+		{
+			name:     "adds to an empty query",
+			set:      []QueryParam{{Key: "v", Value: "2"}},
+			query:    "",
+			expected: "v=2",
+		},
+		{
+			name:     "no changes configured",
+			query:    "a=1&&b=2",
+			expected: "a=1&&b=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, _ := New(Config{
+				ListenAddr:  ":8080",
+				TargetURL:   mustParseURL("https://api.example.com"),
+				SetQuery:    tt.set,
+				RemoveQuery: tt.remove,
+			})
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/search?"+tt.query, nil)
+			got := proxy.buildTargetURL(req, proxy.live.Load().backends[0].URL)
+			if got.RawQuery != tt.expected {
+				t.Errorf("expected query %q, got %q", tt.expected, got.RawQuery)
+			}
+		})
+	}
+}
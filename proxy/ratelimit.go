@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const rateLimitCleanupInterval = time.Minute
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter is a token-bucket limiter keyed on client IP. Buckets that have
+// been idle long enough to refill completely are swept periodically so the
+// map does not grow without bound.
+type rateLimiter struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+
+	return &rateLimiter{
+		rate:        rate,
+		burst:       float64(burst),
+		buckets:     make(map[string]*tokenBucket),
+		lastCleanup: time.Now(),
+		now:         time.Now,
+	}
+}
+
+// allow consumes a token for key. When the bucket is empty it returns false
+// along with how long the client should wait before retrying.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastCleanup) >= rateLimitCleanupInterval {
+		l.cleanup(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = bucket
+	} else {
+		elapsed := now.Sub(bucket.lastSeen).Seconds()
+		bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+		bucket.lastSeen = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *rateLimiter) cleanup(now time.Time) {
+	// A bucket idle for this long has refilled to burst and is
+	// indistinguishable from a fresh one
+	idle := time.Duration(l.burst / l.rate * float64(time.Second))
+	if idle < rateLimitCleanupInterval {
+		idle = rateLimitCleanupInterval
+	}
+
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > idle {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastCleanup = now
+}
+
+func (l *rateLimiter) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.allow("10.0.0.1"); !allowed {
+			t.Fatalf("request %d should be allowed within burst", i+1)
+		}
+	}
+
+	allowed, wait := limiter.allow("10.0.0.1")
+	if allowed {
+		t.Fatal("expected request beyond burst to be rejected")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("expected wait between 0 and 1s, got %v", wait)
+	}
+
+	if allowed, _ := limiter.allow("10.0.0.2"); !allowed {
+		t.Error("other clients should have their own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if allowed, _ := limiter.allow("10.0.0.1"); !allowed {
+		t.Error("expected a token to be refilled after 500ms at 2 req/s")
+	}
+}
+
+func TestRateLimiterDefaultBurst(t *testing.T) {
+	limiter := newRateLimiter(2.5, 0)
+	if limiter.burst != 3 {
+		t.Errorf("expected default burst 3, got %v", limiter.burst)
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(10, 10)
+	limiter.now = func() time.Time { return now }
+
+	_, _ = limiter.allow("10.0.0.1")
+	_, _ = limiter.allow("10.0.0.2")
+	if limiter.size() != 2 {
+		t.Fatalf("expected 2 buckets, got %d", limiter.size())
+	}
+
+	now = now.Add(2 * rateLimitCleanupInterval)
+	_, _ = limiter.allow("10.0.0.3")
+
+	if limiter.size() != 1 {
+		t.Errorf("expected stale buckets to be removed, got %d buckets", limiter.size())
+	}
+}
+
+func TestServeHTTPRateLimitExceeded(t *testing.T) {
+	backendCalls := 0
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendCalls++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		RateLimit:  1,
+		RateBurst:  2,
+	}
+	proxy, _ := New(config)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+		req.RemoteAddr = "192.168.1.10:12345"
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d", i+1, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	req.RemoteAddr = "192.168.1.10:12345"
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", w.Code)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 {
+		t.Errorf("expected positive Retry-After, got %q", w.Header().Get("Retry-After"))
+	}
+	if backendCalls != 2 {
+		t.Errorf("expected backend to be called 2 times, got %d", backendCalls)
+	}
+
+	req = httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	req.RemoteAddr = "192.168.1.20:12345"
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected other client to get 200, got %d", w.Code)
+	}
+}
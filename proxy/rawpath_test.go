@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPPreserveRawPath(t *testing.T) {
+	var requestURI string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name     string
+		config   Config
+		path     string
+		expected string
+	}{
+		{"decoded by default", Config{TargetURL: mustParseURL(backend.URL)}, "/bucket/a%2Fb.txt", "/bucket/a/b.txt"},
+		{"encoded slash kept", Config{TargetURL: mustParseURL(backend.URL), PreserveRawPath: true}, "/bucket/a%2Fb.txt?versionId=1", "/bucket/a%2Fb.txt?versionId=1"},
+		{"plain path unchanged", Config{TargetURL: mustParseURL(backend.URL), PreserveRawPath: true}, "/bucket/a%20b.txt", "/bucket/a%20b.txt"},
+		{"base path", Config{TargetURL: mustParseURL(backend.URL + "/s3"), PreserveRawPath: true}, "/bucket/a%2Fb.txt", "/s3/bucket/a%2Fb.txt"},
+		{
+			"stripped prefix",
+			Config{
+				PathRoutes:      []PathRoute{{Prefix: "/files", Target: mustParseURL(backend.URL), StripPrefix: true}},
+				PreserveRawPath: true,
+			},
+			"/files/a%2Fb.txt",
+			"/a%2Fb.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.ListenAddr = ":8080"
+			config.Logger = log.New(io.Discard, "", 0)
+			proxy, err := New(config)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			requestURI = ""
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080"+tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			if requestURI != tt.expected {
+				t.Errorf("expected the backend to see %s, got %s", tt.expected, requestURI)
+			}
+		})
+	}
+}
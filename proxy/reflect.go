@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// maxReflectBody caps how much of a request body reflect mode echoes back.
+const maxReflectBody = 1 << 20
+
+// reflectedRequest is the JSON document returned in reflect mode.
+type reflectedRequest struct {
+	Method        string              `json:"method"`
+	Host          string              `json:"host"`
+	Path          string              `json:"path"`
+	Query         map[string][]string `json:"query"`
+	Headers       http.Header         `json:"headers"`
+	ClientIP      string              `json:"client_ip"`
+	Body          string              `json:"body"`
+	BodyTruncated bool                `json:"body_truncated,omitempty"`
+}
+
+// serveReflect answers with a description of the request as the proxy
+// received it instead of forwarding it, which shows exactly what arrives
+// after load balancers and clients have had their say.
+func (p *Proxy) serveReflect(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReflectBody+1))
+	if err != nil {
+		p.logger.Printf("Error reading request body: %v", err)
+		p.writeError(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	reflected := reflectedRequest{
+		Method:   r.Method,
+		Host:     r.Host,
+		Path:     r.URL.Path,
+		Query:    r.URL.Query(),
+		Headers:  endToEndHeaders(r.Header),
+		ClientIP: p.clientIP(r),
+		Body:     string(body),
+	}
+	if len(body) > maxReflectBody {
+		reflected.Body = string(body[:maxReflectBody])
+		reflected.BodyTruncated = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(p.config.RequestIDHeader, r.Header.Get(p.config.RequestIDHeader))
+	p.applyResponseHeaders(w.Header())
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(reflected); err != nil {
+		p.logger.Printf("Error writing reflected request: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPReflect(t *testing.T) {
+	proxy, err := New(Config{ListenAddr: ":8080", Reflect: true})
+	if err != nil {
+		t.Fatalf("expected target URL to be optional in reflect mode, got %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/echo/me?a=1&a=2&b=x", strings.NewReader(`{"hello":"world"}`))
+	req.RemoteAddr = "203.0.113.9:51234"
+	req.Header.Set("X-Custom", "value")
+	req.Header.Set("Connection", "keep-alive, X-Hop")
+	req.Header.Set("X-Hop", "secret")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+
+	var reflected reflectedRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &reflected); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+
+	if reflected.Method != "POST" || reflected.Path != "/echo/me" {
+		t.Errorf("unexpected method/path: %s %s", reflected.Method, reflected.Path)
+	}
+	if got := reflected.Query["a"]; len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("expected repeated query values, got %v", got)
+	}
+	if reflected.ClientIP != "203.0.113.9" {
+		t.Errorf("expected client IP 203.0.113.9, got %q", reflected.ClientIP)
+	}
+	if reflected.Body != `{"hello":"world"}` {
+		t.Errorf("unexpected body: %q", reflected.Body)
+	}
+	if reflected.Headers.Get("X-Custom") != "value" {
+		t.Error("expected end-to-end header to be reflected")
+	}
+	for _, name := range []string{"Connection", "X-Hop", "Keep-Alive"} {
+		if reflected.Headers.Get(name) != "" {
+			t.Errorf("expected hop-by-hop header %s to be stripped", name)
+		}
+	}
+}
+
+func TestServeHTTPReflectTruncatesBody(t *testing.T) {
+	proxy, _ := New(Config{ListenAddr: ":8080", Reflect: true})
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", strings.NewReader(strings.Repeat("x", maxReflectBody+10)))
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	var reflected reflectedRequest
+	if err := json.Unmarshal(w.Body.Bytes(), &reflected); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if !reflected.BodyTruncated || len(reflected.Body) != maxReflectBody {
+		t.Errorf("expected body truncated to %d bytes, got %d (truncated=%v)", maxReflectBody, len(reflected.Body), reflected.BodyTruncated)
+	}
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// liveConfig holds the settings Reload can replace while the proxy is
+// serving: the routing table, the headers added to forwarded requests and
+// the timeout. Requests read it through Proxy.live, so a reload swaps it in
+// one step without blocking them.
+type liveConfig struct {
+	targetURL     *url.URL
+	configured    []Backend
+	routes        []Route
+	pathRoutes    []PathRoute
+	customHeaders map[string]string
+	addHeaders    map[string][]string
+	timeout       time.Duration
+	// discovered means backends come from SRV records, so an empty pool
+	// is an outage rather than a missing route
+	discovered bool
+
+	backends      []*Backend
+	routeBackends []*Backend
+	pathBackends  []*Backend
+}
+
+// validateLiveConfig checks the reloadable part of config.
+func validateLiveConfig(config Config) error {
+	if config.TargetURL == nil && config.SRVTarget == nil && len(config.Routes) == 0 && len(config.PathRoutes) == 0 && len(config.Backends) == 0 && !config.ForwardProxy && !config.Reflect {
+		return fmt.Errorf("target URL cannot be nil")
+	}
+
+	for i, backend := range config.Backends {
+		if backend.URL == nil || backend.Weight < 0 {
+			return fmt.Errorf("backend %d must have a URL and a non-negative weight", i)
+		}
+	}
+
+	for i, route := range config.Routes {
+		if route.Host == "" || route.Target == nil {
+			return fmt.Errorf("route %d must have a host and a target", i)
+		}
+		if route.Timeout < 0 {
+			return fmt.Errorf("route %d timeout cannot be negative", i)
+		}
+	}
+
+	for i, route := range config.PathRoutes {
+		if !strings.HasPrefix(route.Prefix, "/") || route.Target == nil {
+			return fmt.Errorf("path route %d must have a prefix starting with / and a target", i)
+		}
+		if route.Timeout < 0 {
+			return fmt.Errorf("path route %d timeout cannot be negative", i)
+		}
+	}
+
+	if config.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	return nil
+}
+
+func newLiveConfig(config Config) *liveConfig {
+	live := &liveConfig{
+		targetURL:     config.TargetURL,
+		configured:    config.Backends,
+		routes:        config.Routes,
+		pathRoutes:    config.PathRoutes,
+		customHeaders: config.CustomHeaders,
+		addHeaders:    config.AddHeaders,
+		timeout:       config.Timeout,
+		discovered:    config.SRVTarget != nil,
+	}
+
+	// A single target URL is simply a pool of one
+	if len(config.Backends) > 0 {
+		for _, backend := range config.Backends {
+			backend := backend
+			live.backends = append(live.backends, &backend)
+		}
+	} else if config.TargetURL != nil {
+		live.backends = []*Backend{{URL: config.TargetURL, Weight: 1}}
+	}
+
+	for _, route := range config.Routes {
+		live.routeBackends = append(live.routeBackends, &Backend{URL: route.Target, Weight: 1, Headers: route.Headers, timeout: route.Timeout})
+	}
+
+	for _, route := range config.PathRoutes {
+		backend := &Backend{URL: route.Target, Weight: 1, Headers: route.Headers, timeout: route.Timeout}
+		if route.StripPrefix {
+			backend.stripPrefix = normalizePrefix(route.Prefix)
+		}
+		live.pathBackends = append(live.pathBackends, backend)
+	}
+	return live
+}
+
+// withBackends returns a copy of l whose default pool is backends, as
+// resolved by SRV discovery.
+func (l *liveConfig) withBackends(backends []Backend) *liveConfig {
+	live := *l
+	live.backends = nil
+	for _, backend := range backends {
+		backend := backend
+		live.backends = append(live.backends, &backend)
+	}
+	return &live
+}
+
+// apply copies the live settings into config, for reporting.
+func (l *liveConfig) apply(config *Config) {
+	config.TargetURL = l.targetURL
+	config.Backends = l.configured
+	config.Routes = l.routes
+	config.PathRoutes = l.pathRoutes
+	config.CustomHeaders = l.customHeaders
+	config.AddHeaders = l.addHeaders
+	config.Timeout = l.timeout
+}
+
+// Reload replaces the routing table (TargetURL, Backends, Routes and
+// PathRoutes), CustomHeaders, AddHeaders and Timeout with those in config
+// while the proxy keeps serving. Listeners and open connections are not
+// touched, and requests already past backend selection finish with the old
+// settings. All other fields of config are ignored; changing them needs a
+// restart. An invalid config is rejected and the current one stays active.
+// With an SRVTarget the default pool keeps following the SRV records.
+func (p *Proxy) Reload(config Config) error {
+	// Whether a target is required depends on the mode, which is fixed, and
+	// SRV discovery keeps owning the default pool
+	config.ForwardProxy = p.config.ForwardProxy
+	config.Reflect = p.config.Reflect
+	config.SRVTarget = p.config.SRVTarget
+	if config.SRVTarget != nil {
+		config.TargetURL = nil
+		config.Backends = nil
+	}
+	if err := validateLiveConfig(config); err != nil {
+		return err
+	}
+
+	p.liveMu.Lock()
+	defer p.liveMu.Unlock()
+
+	live := newLiveConfig(config)
+	if p.discovery != nil {
+		live = live.withBackends(p.discovery.current())
+	}
+	p.live.Store(live)
+	if p.health != nil {
+		p.health.setBackends(p.backendURLs())
+	}
+	return nil
+}
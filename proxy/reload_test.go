@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReloadSwapsCustomHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("X-Env"))
+	}))
+	defer backend.Close()
+
+	config := Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		CustomHeaders: map[string]string{"X-Env": "one"},
+		Logger:        log.New(io.Discard, "", 0),
+	}
+	proxy, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+		return w.Body.String()
+	}
+	if got := get(); got != "one" {
+		t.Fatalf("expected X-Env one before reload, got %q", got)
+	}
+
+	config.CustomHeaders = map[string]string{"X-Env": "two"}
+	config.Timeout = 5 * time.Second
+	if err := proxy.Reload(config); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := get(); got != "two" {
+		t.Errorf("expected X-Env two after reload, got %q", got)
+	}
+	if got := proxy.effectiveConfig()["CustomHeaders"].(map[string]string)["X-Env"]; got != "two" {
+		t.Errorf("expected /config to show the reloaded header, got %q", got)
+	}
+
+	// An invalid config leaves the current one in place
+	config.CustomHeaders = map[string]string{"X-Env": "three"}
+	config.Timeout = -time.Second
+	if err := proxy.Reload(config); err == nil {
+		t.Error("expected error for a negative timeout")
+	}
+	if got := get(); got != "two" {
+		t.Errorf("expected X-Env two after a rejected reload, got %q", got)
+	}
+}
+
+func TestReloadBackendsKeepsWeightedStateBounded(t *testing.T) {
+	newBackends := func() []Backend {
+		return []Backend{
+			{URL: mustParseURL("http://10.0.0.1:8080"), Weight: 2},
+			{URL: mustParseURL("http://10.0.0.2:8080"), Weight: 1},
+		}
+	}
+	config := Config{
+		ListenAddr: ":8080",
+		Backends:   newBackends(),
+		Logger:     log.New(io.Discard, "", 0),
+	}
+	proxy, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	selector, ok := proxy.selector.(*weightedSelector)
+	if !ok {
+		t.Fatalf("expected the weighted selector, got %T", proxy.selector)
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	for range 20 {
+		config.Backends = newBackends()
+		if err := proxy.Reload(config); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		for range 3 {
+			if _, err := proxy.selectBackend(req); err != nil {
+				t.Fatalf("selectBackend failed: %v", err)
+			}
+		}
+	}
+
+	selector.mu.Lock()
+	defer selector.mu.Unlock()
+	if len(selector.current) != 2 {
+		t.Errorf("expected state for the 2 live backends only, got %d entries", len(selector.current))
+	}
+}
+
+func TestReloadRoutes(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, name)
+		}))
+	}
+	api := newBackend("api")
+	defer api.Close()
+	v2 := newBackend("v2")
+	defer v2.Close()
+
+	config := Config{
+		ListenAddr:     ":8080",
+		PathRoutes:     []PathRoute{{Prefix: "/api", Target: mustParseURL(api.URL)}},
+		HealthInterval: time.Hour,
+		Logger:         log.New(io.Discard, "", 0),
+	}
+	proxy, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080"+path, nil))
+		return w.Code, w.Body.String()
+	}
+	if code, _ := get("/v2/users"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 before the route exists, got %d", code)
+	}
+
+	config.PathRoutes = append(config.PathRoutes, PathRoute{Prefix: "/v2", Target: mustParseURL(v2.URL)})
+	if err := proxy.Reload(config); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	// The new backend is not marked down just because it was never checked
+	if code, body := get("/v2/users"); code != http.StatusOK || body != "v2" {
+		t.Errorf("expected 200 from v2 after reload, got %d %q", code, body)
+	}
+	if code, body := get("/api/users"); code != http.StatusOK || body != "api" {
+		t.Errorf("expected existing route to keep working, got %d %q", code, body)
+	}
+
+	if err := proxy.Reload(Config{}); err == nil {
+		t.Error("expected error for a reload without any target")
+	}
+}
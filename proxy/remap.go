@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseStatusRemaps parses -remap-status values, each "FROM=TO" or a
+// comma-separated list of them, into a map from backend status to the status
+// sent to the client. Only the status line changes, so a target must be able
+// to carry the backend's body: 204 and 304 are refused.
+func ParseStatusRemaps(values []string) (map[int]int, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	remaps := make(map[int]int)
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			rawFrom, rawTo, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid status remap %q (expected 'FROM=TO', e.g. 418=200)", field)
+			}
+			from, err := parseRemapStatus(rawFrom)
+			if err != nil {
+				return nil, err
+			}
+			to, err := parseRemapStatus(rawTo)
+			if err != nil {
+				return nil, err
+			}
+			if !bodyAllowedForStatus(to) {
+				return nil, fmt.Errorf("invalid status remap %q (%d cannot carry the response body)", field, to)
+			}
+			if previous, ok := remaps[from]; ok && previous != to {
+				return nil, fmt.Errorf("status %d is remapped twice (to %d and %d)", from, previous, to)
+			}
+			remaps[from] = to
+		}
+	}
+	return remaps, nil
+}
+
+func parseRemapStatus(value string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || code < 200 || code > 599 {
+		return 0, fmt.Errorf("invalid status code %q in status remap (must be 200-599)", value)
+	}
+	return code, nil
+}
+
+// responseStatus returns the status to send the client for a backend status,
+// applying Config.StatusRemaps.
+func (p *Proxy) responseStatus(status int) int {
+	if remapped, ok := p.config.StatusRemaps[status]; ok {
+		return remapped
+	}
+	return status
+}
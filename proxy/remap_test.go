@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseStatusRemaps(t *testing.T) {
+	remaps, err := ParseStatusRemaps([]string{"418=200", "404=200,503=502"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[int]int{418: 200, 404: 200, 503: 502}
+	if len(remaps) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, remaps)
+	}
+	for from, to := range expected {
+		if remaps[from] != to {
+			t.Errorf("expected %d=%d, got %d", from, to, remaps[from])
+		}
+	}
+
+	for _, value := range []string{"418", "418=", "abc=200", "418=99", "418=600", "100=200", "404=204", "500=304", "418=200,418=201"} {
+		if _, err := ParseStatusRemaps([]string{value}); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestServeHTTPRemapStatus(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", "yes")
+		switch r.URL.Path {
+		case "/teapot":
+			w.WriteHeader(http.StatusTeapot)
+			_, _ = io.WriteString(w, `{"error":"short and stout"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "not found")
+		}
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:   ":8080",
+		TargetURL:    mustParseURL(backend.URL),
+		StatusRemaps: map[int]int{http.StatusTeapot: http.StatusOK},
+		Logger:       log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{"4xx remapped to 2xx", "/teapot", http.StatusOK, `{"error":"short and stout"}`},
+		{"unmapped status unchanged", "/missing", http.StatusNotFound, "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080"+tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, w.Body.String())
+			}
+			if w.Header().Get("X-Backend") != "yes" {
+				t.Error("expected backend headers to be relayed")
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// DefaultRequestIDHeader carries request IDs unless Config.RequestIDHeader
+// names another header.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
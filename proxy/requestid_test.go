@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewRequestID(t *testing.T) {
+	first := newRequestID()
+	second := newRequestID()
+
+	if len(first) != 32 {
+		t.Errorf("expected 32 hex characters, got %d", len(first))
+	}
+	if first == second {
+		t.Error("expected unique request IDs")
+	}
+}
+
+func TestServeHTTPRequestID(t *testing.T) {
+	var receivedID string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedID = r.Header.Get("X-Request-ID")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	var logBuf bytes.Buffer
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(&logBuf, "", 0),
+	})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	responseID := w.Header().Get("X-Request-ID")
+	if responseID == "" {
+		t.Fatal("expected generated request ID in response")
+	}
+	if receivedID != responseID {
+		t.Errorf("expected backend to receive %q, got %q", responseID, receivedID)
+	}
+	if !strings.Contains(logBuf.String(), responseID) {
+		t.Errorf("expected request ID in log, got %q", logBuf.String())
+	}
+	if req.Header.Get("X-Request-ID") != "" {
+		t.Error("expected the incoming request to be left unmodified")
+	}
+}
+
+func TestServeHTTPPreservesUpstreamRequestID(t *testing.T) {
+	var receivedID string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedID = r.Header.Get("X-Trace")
+		w.Header().Set("X-Trace", "backend-generated")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:      ":8080",
+		TargetURL:       mustParseURL(backend.URL),
+		RequestIDHeader: "X-Trace",
+	})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	req.Header.Set("X-Trace", "upstream-123")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if receivedID != "upstream-123" {
+		t.Errorf("expected upstream ID to be forwarded, got %q", receivedID)
+	}
+	if values := w.Header().Values("X-Trace"); len(values) != 1 || values[0] != "upstream-123" {
+		t.Errorf("expected single echoed ID upstream-123, got %v", values)
+	}
+	if w.Header().Get("X-Request-ID") != "" {
+		t.Error("expected default header to be unused when a custom header is configured")
+	}
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriterImplicitStatus(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rw := newResponseWriter(recorder)
+
+	n, err := rw.Write([]byte("hello"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("expected 5 bytes written, got %d", n)
+	}
+	if rw.statusCode != http.StatusOK {
+		t.Errorf("expected implicit status 200, got %d", rw.statusCode)
+	}
+	if rw.bytesWritten != 5 {
+		t.Errorf("expected bytesWritten 5, got %d", rw.bytesWritten)
+	}
+}
+
+func TestResponseWriterExplicitStatus(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rw := newResponseWriter(recorder)
+
+	rw.WriteHeader(http.StatusNotFound)
+	rw.WriteHeader(http.StatusInternalServerError)
+	_, _ = rw.Write([]byte("not found"))
+	_, _ = rw.Write([]byte("!"))
+
+	if rw.statusCode != http.StatusNotFound {
+		t.Errorf("expected first status 404 to stick, got %d", rw.statusCode)
+	}
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected recorder status 404, got %d", recorder.Code)
+	}
+	if rw.bytesWritten != 10 {
+		t.Errorf("expected bytesWritten 10, got %d", rw.bytesWritten)
+	}
+}
+
+func TestResponseWriterFlushAndUnwrap(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rw := newResponseWriter(recorder)
+
+	rw.Flush()
+	if !recorder.Flushed {
+		t.Error("expected Flush to reach the underlying writer")
+	}
+	if rw.Unwrap() != recorder {
+		t.Error("expected Unwrap to return the underlying writer")
+	}
+}
+
+func TestServeHTTPLogsCompletion(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer backend.Close()
+
+	var logBuf bytes.Buffer
+	config := Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(&logBuf, "", 0),
+	}
+	proxy, _ := New(config)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+	w := httptest.NewRecorder()
+
+	proxy.ServeHTTP(w, req)
+
+	logOutput := logBuf.String()
+	if !strings.Contains(logOutput, "GET /test -> 202 (10 bytes)") {
+		t.Errorf("expected completion line with status and byte count, got %q", logOutput)
+	}
+	if !strings.Contains(logOutput, "ms") {
+		t.Errorf("expected completion line to include duration, got %q", logOutput)
+	}
+}
+
+func TestIsStreamingResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		resp     *http.Response
+		expected bool
+	}{
+		{"event stream", &http.Response{Header: http.Header{"Content-Type": {"text/event-stream; charset=utf-8"}}}, true},
+		{"chunked", &http.Response{Header: http.Header{}, TransferEncoding: []string{"chunked"}}, true},
+		{"fixed length json", &http.Response{Header: http.Header{"Content-Type": {"application/json"}}, ContentLength: 10}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStreamingResponse(tt.resp); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
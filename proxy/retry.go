@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry defaults: the delay before the first retry when the backend gives no
+// Retry-After, the cap on that delay as it doubles, and the longest
+// Retry-After the proxy is willing to wait.
+const (
+	DefaultRetryBackoff    = 100 * time.Millisecond
+	DefaultRetryMaxBackoff = 2 * time.Second
+	DefaultMaxRetryAfter   = 10 * time.Second
+)
+
+// backoff computes capped exponential delays between retries, optionally
+// with jitter so clients that failed together do not retry together.
+type backoff struct {
+	base       time.Duration
+	maxBackoff time.Duration
+	jitter     float64 // 0 is none, 1 is full jitter
+	random     func() float64
+}
+
+// delay returns how long to wait before the given retry, counting from 0:
+// min(maxBackoff, base * 2^retry), less a random share of up to jitter of it.
+func (b backoff) delay(retry int) time.Duration {
+	delay := b.base
+	for range retry {
+		if delay >= b.maxBackoff/2 {
+			delay = b.maxBackoff
+			break
+		}
+		delay *= 2
+	}
+	delay = min(delay, b.maxBackoff)
+
+	if b.jitter > 0 {
+		delay -= time.Duration(b.random() * b.jitter * float64(delay))
+	}
+	return delay
+}
+
+func newBackoff(config Config) backoff {
+	return backoff{
+		base:       config.RetryBackoff,
+		maxBackoff: config.RetryMaxBackoff,
+		jitter:     config.RetryJitter,
+		random:     rand.Float64,
+	}
+}
+
+// doWithRetries sends req to the backend, retrying connection errors and
+// 429/503 responses up to Config.Retries times with exponential backoff. A
+// Retry-After header on the response replaces the backoff, capped at
+// Config.MaxRetryAfter. A client that goes away cuts the wait short. When
+// retries run out the last response is returned untouched, so the client
+// sees the backend's status and Retry-After.
+func (p *Proxy) doWithRetries(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := p.httpClient.Do(req)
+		if attempt > p.config.Retries || !isRetryableRequest(req) {
+			return resp, err
+		}
+
+		delay := p.backoff.delay(attempt - 1)
+		if err != nil {
+			// A cancelled client or an expired deadline will not get better
+			if req.Context().Err() != nil {
+				return resp, err
+			}
+		} else {
+			if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+				return resp, nil
+			}
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				delay = min(retryAfter, p.config.MaxRetryAfter)
+			}
+		}
+
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			req.Body = body
+		}
+
+		status := "error"
+		if resp != nil {
+			status = strconv.Itoa(resp.StatusCode)
+			_ = resp.Body.Close()
+		}
+		p.logger.Printf("Retrying %s %s after %s in %v (attempt %d of %d)", req.Method, req.URL.Path, status, delay, attempt+1, p.config.Retries+1)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// isRetryableRequest reports whether req can safely be sent again: its
+// method must be idempotent and its body, if any, replayable.
+func isRetryableRequest(req *http.Request) bool {
+	if !isIdempotent(req.Method) {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After value in either delta-seconds or
+// HTTP-date form (RFC 9110 section 10.2.3). Dates in the past mean no wait.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
@@ -0,0 +1,220 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"delta seconds", "120", 2 * time.Minute, true},
+		{"zero seconds", "0", 0, true},
+		{"HTTP date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"HTTP date in the past", now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+		{"RFC 850 date", now.Add(30 * time.Second).Format("Monday, 02-Jan-06 15:04:05 GMT"), 30 * time.Second, true},
+		{"empty", "", 0, false},
+		{"negative", "-5", 0, false},
+		{"garbage", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestServeHTTPRetriesHonorRetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("recovered"))
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:   ":8080",
+		TargetURL:    mustParseURL(backend.URL),
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+		Logger:       log.New(io.Discard, "", 0),
+	})
+
+	start := time.Now()
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "recovered" {
+		t.Errorf("expected retried response, got %d %q", w.Code, w.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("expected to wait for Retry-After, retried after %v", elapsed)
+	}
+}
+
+func TestServeHTTPRetryAfterIsCapped(t *testing.T) {
+	var attempts atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		Retries:       1,
+		MaxRetryAfter: 20 * time.Millisecond,
+		Logger:        log.New(io.Discard, "", 0),
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 after retry, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected Retry-After to be capped, waited %v", elapsed)
+	}
+}
+
+func TestServeHTTPRetriesExhaustedPassThrough(t *testing.T) {
+	var attempts atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte("slow down"))
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Retries:    2,
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+
+	if attempts.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts.Load())
+	}
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "0" || w.Body.String() != "slow down" {
+		t.Errorf("expected backend 429 passed through, got %d Retry-After=%q body=%q", w.Code, w.Header().Get("Retry-After"), w.Body.String())
+	}
+}
+
+func TestServeHTTPDoesNotRetryNonIdempotent(t *testing.T) {
+	var attempts atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:   ":8080",
+		TargetURL:    mustParseURL(backend.URL),
+		Retries:      3,
+		RetryBackoff: time.Millisecond,
+		Logger:       log.New(io.Discard, "", 0),
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost:8080/", strings.NewReader("order")))
+
+	if attempts.Load() != 1 {
+		t.Errorf("expected POST not to be retried, got %d attempts", attempts.Load())
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	exact := backoff{base: 100 * time.Millisecond, maxBackoff: time.Second}
+	for retry, expected := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got := exact.delay(retry); got != expected*time.Millisecond {
+			t.Errorf("delay(%d) = %v, want %v", retry, got, expected*time.Millisecond)
+		}
+	}
+	if got := exact.delay(100); got != time.Second {
+		t.Errorf("expected a large retry count to stay capped, got %v", got)
+	}
+
+	tests := []struct {
+		name   string
+		jitter float64
+	}{
+		{"full jitter", 1},
+		{"half jitter", 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackoff(Config{RetryBackoff: 100 * time.Millisecond, RetryMaxBackoff: time.Second, RetryJitter: tt.jitter})
+			for retry := range 6 {
+				ceiling := exact.delay(retry)
+				floor := time.Duration(float64(ceiling) * (1 - tt.jitter))
+				for range 200 {
+					if got := b.delay(retry); got < floor || got > ceiling {
+						t.Fatalf("delay(%d) = %v, want within [%v, %v]", retry, got, floor, ceiling)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestServeHTTPClientDisconnectAbortsBackoff(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:   ":8080",
+		TargetURL:    mustParseURL(backend.URL),
+		Retries:      1,
+		RetryBackoff: time.Minute,
+		Logger:       log.New(io.Discard, "", 0),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil).WithContext(ctx)
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the disconnect to cut the backoff short, waited %v", elapsed)
+	}
+}
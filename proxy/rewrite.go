@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule rewrites request paths matching Pattern before they are
+// appended to the backend URL. Replacement may reference capture groups as
+// $1 or ${name}, as in regexp.Regexp.ReplaceAllString.
+type RewriteRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// ParseRewriteRules compiles -rewrite values of the form
+// "pattern=replacement". The first "=" separates the two, so patterns cannot
+// contain one.
+func ParseRewriteRules(values []string) ([]RewriteRule, error) {
+	rules := make([]RewriteRule, 0, len(values))
+	for _, value := range values {
+		pattern, replacement, ok := strings.Cut(value, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid rewrite rule %q (expected 'pattern=replacement')", value)
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, RewriteRule{Pattern: re, Replacement: replacement})
+	}
+	return rules, nil
+}
+
+// rewritePath applies every rewrite rule to path in order, each one seeing
+// the result of the previous.
+func (p *Proxy) rewritePath(path string) string {
+	for _, rule := range p.config.Rewrites {
+		path = rule.Pattern.ReplaceAllString(path, rule.Replacement)
+	}
+	return path
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseRewriteRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []string
+		expectError bool
+	}{
+		{"valid rule", []string{`^/api/(.*)$=/$1`}, false},
+		{"empty replacement", []string{`^/strip=`}, false},
+		{"missing separator", []string{`^/api/(.*)$`}, true},
+		{"empty pattern", []string{`=/v2`}, true},
+		{"invalid regex", []string{`^/api/(.*=/$1`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRewriteRules(tt.values)
+			if tt.expectError && err == nil {
+				t.Error("expected error but got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestBuildTargetURLWithRewrites(t *testing.T) {
+	rules, err := ParseRewriteRules([]string{
+		`^/users/([^/]+)/posts/([^/]+)$=/posts/$2/author/$1`,
+		`^/old/=/new/`,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("https://api.example.com/v1"),
+		Rewrites:   rules,
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"reorders segments", "/users/42/posts/7?draft=1", "https://api.example.com/v1/posts/7/author/42?draft=1"},
+		{"no match leaves path unchanged", "/health", "https://api.example.com/v1/health"},
+		{"rules apply in order", "/old/thing", "https://api.example.com/v1/new/thing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:8080"+tt.path, nil)
+			got := proxy.buildTargetURL(req, proxy.live.Load().backends[0].URL).String()
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Route sends requests for a virtual host to a dedicated backend. Host is
+// either an exact hostname or a wildcard such as "*.example.com", which
+// matches any subdomain but not the bare domain.
+type Route struct {
+	Host    string
+	Target  *url.URL
+	Headers map[string]string
+	// Timeout, when set, replaces Config.Timeout for this route
+	Timeout time.Duration
+}
+
+// PathRoute sends requests whose path starts with Prefix to a dedicated
+// backend. Prefixes match whole path segments, so "/api" matches "/api" and
+// "/api/users" but not "/apix", and the longest matching prefix wins. With
+// StripPrefix the matched prefix is removed before the path is forwarded.
+type PathRoute struct {
+	Prefix      string
+	Target      *url.URL
+	StripPrefix bool
+	Headers     map[string]string
+	// Timeout, when set, replaces Config.Timeout for this route
+	Timeout time.Duration
+}
+
+var (
+	errNoRoute          = errors.New("no route matches the request")
+	errNoHealthyBackend = errors.New("no healthy backend available")
+)
+
+// selectBackend picks the backend for a request: in forward-proxy mode an
+// absolute request URI names its own destination, then a matching host route
+// wins, then the longest matching path route, otherwise the default pool is
+// load balanced across its healthy members.
+func (p *Proxy) selectBackend(r *http.Request) (*Backend, error) {
+	if p.config.ForwardProxy {
+		if backend := forwardBackend(r); backend != nil {
+			return backend, nil
+		}
+	}
+
+	live := p.live.Load()
+	if i := matchHostRoute(live.routes, r.Host); i >= 0 {
+		backend := live.routeBackends[i]
+		if !p.isHealthy(backend) {
+			return nil, errNoHealthyBackend
+		}
+		return backend, nil
+	}
+
+	if i := matchPathRoute(live.pathRoutes, r.URL.Path); i >= 0 {
+		backend := live.pathBackends[i]
+		if !p.isHealthy(backend) {
+			return nil, errNoHealthyBackend
+		}
+		return backend, nil
+	}
+
+	if len(live.backends) == 0 {
+		if live.discovered {
+			return nil, errNoHealthyBackend
+		}
+		return nil, errNoRoute
+	}
+
+	candidates := make([]*Backend, 0, len(live.backends))
+	for _, backend := range live.backends {
+		if p.isHealthy(backend) {
+			candidates = append(candidates, backend)
+		}
+	}
+
+	backend := p.selector.Select(r, candidates)
+	if backend == nil {
+		return nil, errNoHealthyBackend
+	}
+	return backend, nil
+}
+
+func (p *Proxy) isHealthy(backend *Backend) bool {
+	return p.health == nil || p.health.isHealthy(backend.URL)
+}
+
+// matchHostRoute returns the index of the route for host, preferring an
+// exact hostname match and otherwise the most specific (longest) wildcard
+// pattern, or -1 when nothing matches.
+func matchHostRoute(routes []Route, host string) int {
+	host = normalizeHost(host)
+	if host == "" {
+		return -1
+	}
+
+	best, bestLen := -1, 0
+	for i, route := range routes {
+		pattern := normalizeHost(route.Host)
+
+		if pattern == host {
+			return i
+		}
+
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			if best < 0 || len(pattern) > bestLen {
+				best, bestLen = i, len(pattern)
+			}
+		}
+	}
+	return best
+}
+
+// matchPathRoute returns the index of the route with the longest prefix
+// matching path on a segment boundary, or -1 when nothing matches.
+func matchPathRoute(routes []PathRoute, path string) int {
+	best, bestLen := -1, -1
+	for i, route := range routes {
+		prefix := normalizePrefix(route.Prefix)
+		if !hasPathPrefix(path, prefix) {
+			continue
+		}
+		if len(prefix) > bestLen {
+			best, bestLen = i, len(prefix)
+		}
+	}
+	return best
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	if prefix == "/" {
+		return true
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// normalizePrefix drops a trailing slash so "/api/" and "/api" behave the
+// same; the root prefix "/" matches every path.
+func normalizePrefix(prefix string) string {
+	if prefix == "/" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/")
+}
+
+// stripPathPrefix returns r with prefix removed from its path, for building
+// the target URL of a path route with StripPrefix. The original request is
+// left untouched for logging, caching and mirroring.
+func stripPathPrefix(r *http.Request, prefix string) *http.Request {
+	if prefix == "" || prefix == "/" {
+		return r
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+	if path == "" {
+		path = "/"
+	}
+
+	// An encoded path loses the same prefix, as long as the prefix and the
+	// slash after it were not encoded
+	rawPath := ""
+	if rest, ok := strings.CutPrefix(r.URL.RawPath, prefix); ok && rest == "" {
+		rawPath = "/"
+	} else if ok && strings.HasPrefix(rest, "/") {
+		rawPath = rest
+	}
+
+	stripped := *r
+	u := *r.URL
+	u.Path, u.RawPath = path, rawPath
+	stripped.URL = &u
+	return &stripped
+}
+
+// originForm returns r with its URL reduced to the path and query of an
+// origin-form request line ("GET /path?query"). Requests in absolute form
+// ("GET http://host/path") or authority form ("CONNECT host:443") would
+// otherwise carry a scheme and host the reverse proxy must not act on. The
+// route is decided by r.Host alone, which Go's server already takes from an
+// absolute-form URL; only when it is empty is the URL's host used. There is
+// no duplicate Host header to choose between: Go's server answers such
+// requests with 400 before they reach the handler.
+func originForm(r *http.Request) *http.Request {
+	if !r.URL.IsAbs() && r.URL.Host == "" && r.URL.Opaque == "" && r.URL.User == nil && r.URL.Path != "" {
+		return r
+	}
+
+	normalized := *r
+	normalized.URL = &url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+	if normalized.URL.Path == "" {
+		normalized.URL.Path, normalized.URL.RawPath = "/", ""
+	}
+	if normalized.Host == "" {
+		normalized.Host = r.URL.Host
+	}
+	return &normalized
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// backendURLs lists every distinct backend the proxy may forward to.
+func (p *Proxy) backendURLs() []*url.URL {
+	seen := make(map[string]bool)
+	var backends []*url.URL
+
+	add := func(u *url.URL) {
+		if u == nil || seen[u.String()] {
+			return
+		}
+		seen[u.String()] = true
+		backends = append(backends, u)
+	}
+
+	live := p.live.Load()
+	for _, backend := range live.backends {
+		add(backend.URL)
+	}
+	for _, route := range live.routes {
+		add(route.Target)
+	}
+	for _, route := range live.pathRoutes {
+		add(route.Target)
+	}
+	return backends
+}
@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSRVRefresh is how often SRV records are looked up again when
+// Config.SRVRefresh is zero.
+const DefaultSRVRefresh = 30 * time.Second
+
+const srvLookupTimeout = 5 * time.Second
+
+// SRVResolver looks up DNS SRV records; *net.Resolver implements it.
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// ParseSRVTarget parses a target of the form srv://NAME[/base/path], where
+// NAME is the full SRV record name such as _http._tcp.api.service.consul.
+func ParseSRVTarget(rawURL string) (*url.URL, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRV target: %w", err)
+	}
+	if target.Scheme != "srv" || target.Hostname() == "" || target.Port() != "" {
+		return nil, fmt.Errorf("SRV target must look like srv://_http._tcp.example.com: %s", rawURL)
+	}
+	return target, nil
+}
+
+// srvDiscovery keeps the Backends pool in line with the SRV records of
+// Config.SRVTarget.
+type srvDiscovery struct {
+	target   *url.URL
+	resolver SRVResolver
+	interval time.Duration
+
+	mu       sync.Mutex
+	backends []Backend
+}
+
+func newSRVDiscovery(target *url.URL, resolver SRVResolver, interval time.Duration) *srvDiscovery {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if interval == 0 {
+		interval = DefaultSRVRefresh
+	}
+	return &srvDiscovery{target: target, resolver: resolver, interval: interval}
+}
+
+// resolve looks the records up and turns the targets of the lowest
+// priority, the ones RFC 2782 says to use while they are reachable, into
+// backends weighted by their SRV weight. A weight of 0 counts as 1, so such
+// targets still get a small share. The name must resolve to records; a name
+// that does not exist resolves to no backends.
+func (d *srvDiscovery) resolve(ctx context.Context) ([]Backend, error) {
+	ctx, cancel := context.WithTimeout(ctx, srvLookupTimeout)
+	defer cancel()
+
+	name := d.target.Hostname()
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", name)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Services named _https._tcp are reached over TLS
+	scheme := "http"
+	if strings.HasPrefix(name, "_https.") {
+		scheme = "https"
+	}
+
+	var backends []Backend
+	lowest := -1
+	for _, record := range records {
+		if lowest < 0 || int(record.Priority) < lowest {
+			lowest = int(record.Priority)
+		}
+	}
+	for _, record := range records {
+		if int(record.Priority) != lowest {
+			continue
+		}
+		host := strings.TrimSuffix(record.Target, ".")
+		backends = append(backends, Backend{
+			URL: &url.URL{
+				Scheme: scheme,
+				Host:   net.JoinHostPort(host, strconv.Itoa(int(record.Port))),
+				Path:   d.target.Path,
+			},
+			Weight: max(int(record.Weight), 1),
+		})
+	}
+
+	// A stable order means an unchanged answer is recognised as such
+	slices.SortFunc(backends, func(a, b Backend) int { return strings.Compare(a.URL.Host, b.URL.Host) })
+	return backends, nil
+}
+
+// update records a fresh resolution, reporting whether it differs from the
+// previous one.
+func (d *srvDiscovery) update(backends []Backend) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	same := slices.EqualFunc(backends, d.backends, func(a, b Backend) bool {
+		return a.URL.String() == b.URL.String() && a.Weight == b.Weight
+	})
+	d.backends = backends
+	return !same
+}
+
+func (d *srvDiscovery) current() []Backend {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.backends
+}
+
+// refreshSRV resolves the SRV target and, when the answer changed, swaps
+// the new pool in. A failed lookup keeps the last known backends.
+func (p *Proxy) refreshSRV(ctx context.Context) error {
+	backends, err := p.discovery.resolve(ctx)
+	if err != nil {
+		return fmt.Errorf("SRV lookup for %s failed: %w", p.discovery.target.Hostname(), err)
+	}
+
+	p.liveMu.Lock()
+	defer p.liveMu.Unlock()
+
+	if !p.discovery.update(backends) {
+		return nil
+	}
+	p.live.Store(p.live.Load().withBackends(backends))
+	if p.health != nil {
+		p.health.setBackends(p.backendURLs())
+	}
+
+	hosts := make([]string, len(backends))
+	for i, backend := range backends {
+		hosts[i] = fmt.Sprintf("%s (weight %d)", backend.URL.Host, backend.Weight)
+	}
+	p.logger.Printf("SRV %s resolved to %d backend(s): %s", p.discovery.target.Hostname(), len(backends), strings.Join(hosts, ", "))
+	return nil
+}
+
+// runSRVDiscovery refreshes the SRV backends until ctx is cancelled.
+func (p *Proxy) runSRVDiscovery(ctx context.Context) {
+	ticker := time.NewTicker(p.discovery.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.refreshSRV(ctx); err != nil {
+				p.logger.Printf("WARNING: %v; keeping %d known backend(s)", err, len(p.discovery.current()))
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// stubResolver answers every SRV lookup with records, which tests may swap.
+type stubResolver struct {
+	mu      sync.Mutex
+	records []*net.SRV
+	err     error
+}
+
+func (s *stubResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return name, s.records, s.err
+}
+
+func (s *stubResolver) set(records []*net.SRV, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records, s.err = records, err
+}
+
+// srvRecord points an SRV record at a test server.
+func srvRecord(t *testing.T, server *httptest.Server, priority, weight uint16) *net.SRV {
+	t.Helper()
+	host, port, err := net.SplitHostPort(mustParseURL(server.URL).Host)
+	if err != nil {
+		t.Fatalf("bad server address: %v", err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	return &net.SRV{Target: host + ".", Port: uint16(portNumber), Priority: priority, Weight: weight}
+}
+
+func newSRVBackend(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", name)
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func TestParseSRVTarget(t *testing.T) {
+	target, err := ParseSRVTarget("srv://_http._tcp.api.service.consul/v1")
+	if err != nil {
+		t.Fatalf("ParseSRVTarget failed: %v", err)
+	}
+	if target.Hostname() != "_http._tcp.api.service.consul" || target.Path != "/v1" {
+		t.Errorf("unexpected target %v", target)
+	}
+
+	for _, raw := range []string{"http://example.com", "srv://", "srv://_http._tcp.example.com:80"} {
+		if _, err := ParseSRVTarget(raw); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestServeHTTPSRVDiscovery(t *testing.T) {
+	one := newSRVBackend(t, "one")
+	two := newSRVBackend(t, "two")
+	standby := newSRVBackend(t, "standby")
+
+	resolver := &stubResolver{records: []*net.SRV{
+		srvRecord(t, one, 10, 1),
+		srvRecord(t, two, 10, 0),
+		srvRecord(t, standby, 20, 5),
+	}}
+	proxy, err := New(Config{
+		ListenAddr:  ":8080",
+		SRVTarget:   &url.URL{Scheme: "srv", Host: "_http._tcp.api.service.consul"},
+		SRVResolver: resolver,
+		Logger:      log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	serve := func() (int, string) {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+		return rec.Code, rec.Header().Get("X-Backend")
+	}
+
+	// Only the lowest priority is used, and weight 0 still gets a share
+	seen := make(map[string]int)
+	for range 4 {
+		code, backend := serve()
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		seen[backend]++
+	}
+	if seen["one"] != 2 || seen["two"] != 2 {
+		t.Errorf("expected requests split between one and two, got %v", seen)
+	}
+
+	// A refresh picks up the new answer
+	resolver.set([]*net.SRV{srvRecord(t, standby, 20, 5)}, nil)
+	if err := proxy.refreshSRV(context.Background()); err != nil {
+		t.Fatalf("refreshSRV failed: %v", err)
+	}
+	if _, backend := serve(); backend != "standby" {
+		t.Errorf("expected the refreshed backend, got %q", backend)
+	}
+
+	// A failed lookup keeps the last known backends
+	resolver.set(nil, &net.DNSError{Err: "server misbehaving", Name: "_http._tcp.api.service.consul"})
+	if err := proxy.refreshSRV(context.Background()); err == nil {
+		t.Error("expected the lookup failure to be reported")
+	}
+	if _, backend := serve(); backend != "standby" {
+		t.Errorf("expected the last known backend after a failed lookup, got %q", backend)
+	}
+
+	// No records at all is an outage
+	resolver.set(nil, &net.DNSError{Err: "no such host", Name: "_http._tcp.api.service.consul", IsNotFound: true})
+	if err := proxy.refreshSRV(context.Background()); err != nil {
+		t.Fatalf("refreshSRV failed: %v", err)
+	}
+	if code, _ := serve(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 with no SRV records, got %d", code)
+	}
+}
+
+func TestNewSRVTargetEmpty(t *testing.T) {
+	proxy, err := New(Config{
+		ListenAddr:  ":8080",
+		SRVTarget:   &url.URL{Scheme: "srv", Host: "_http._tcp.api.service.consul"},
+		SRVResolver: &stubResolver{},
+		Logger:      log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+}
+
+func TestNewRejectsSRVTargetWithBackends(t *testing.T) {
+	_, err := New(Config{
+		ListenAddr: ":8080",
+		SRVTarget:  &url.URL{Scheme: "srv", Host: "_http._tcp.api.service.consul"},
+		TargetURL:  mustParseURL("http://localhost:9000"),
+	})
+	if err == nil {
+		t.Error("expected an SRV target combined with a target URL to be rejected")
+	}
+}
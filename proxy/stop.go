@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Stop gracefully shuts down the servers started by Start or Serve: their
+// listeners close at once, then Stop waits for requests in flight to finish
+// or for ctx to end, whichever comes first. Start and Serve then return nil.
+// Stop does not release the access log or tracing; call Close for that. It
+// is safe to call more than once; when called before Start or Serve, they
+// return http.ErrServerClosed without serving anything.
+func (p *Proxy) Stop(ctx context.Context) error {
+	p.runningMu.Lock()
+	servers, quicServers := p.running, p.runningQUIC
+	p.running, p.runningQUIC = nil, nil
+	p.stopped = true
+	p.runningMu.Unlock()
+
+	var errs []error
+	for _, server := range quicServers {
+		if err := server.Close(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+		}
+	}
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// track records servers about to be started by Start or Serve so Stop can
+// shut them down. It reports false, recording nothing, once Stop has been
+// called, and the servers must not be started.
+func (p *Proxy) track(servers []*http.Server, quicServers []quicServer) bool {
+	p.runningMu.Lock()
+	defer p.runningMu.Unlock()
+	if p.stopped {
+		return false
+	}
+	p.running = append(p.running, servers...)
+	p.runningQUIC = append(p.runningQUIC, quicServers...)
+	return true
+}
@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStopShutsDownGracefully(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	}))
+	defer backend.Close()
+
+	addr := "127.0.0.1" + findFreePort(t)
+	proxy, err := New(Config{
+		ListenAddr: addr,
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	started := make(chan error, 1)
+	go func() { started <- proxy.Start() }()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("proxy never started listening: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	type result struct {
+		body string
+		err  error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		inFlight <- result{string(body), err}
+	}()
+	<-arrived
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- proxy.Stop(ctx)
+	}()
+
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("expected Start to return nil after Stop, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start did not return after Stop")
+	}
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned before the request in flight finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("expected new connections to be refused while stopping")
+	}
+
+	close(release)
+	if res := <-inFlight; res.err != nil || res.body != "done" {
+		t.Errorf("expected the request in flight to complete, got %q, %v", res.body, res.err)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("expected a clean Stop, got %v", err)
+	}
+	if err := proxy.Stop(context.Background()); err != nil {
+		t.Errorf("expected a second Stop to be a no-op, got %v", err)
+	}
+}
+
+func TestStopBeforeServe(t *testing.T) {
+	proxy, err := New(Config{
+		ListenAddr: ":0",
+		TargetURL:  mustParseURL("http://127.0.0.1:1"),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := proxy.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop before Serve failed: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- proxy.Serve(listener) }()
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Fatalf("expected http.ErrServerClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve kept running after Stop")
+	}
+
+	if conn, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		_ = conn.Close()
+		t.Fatal("expected Serve to close the listener")
+	}
+}
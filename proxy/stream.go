@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const streamBufferSize = 32 * 1024
+
+// isStreamingResponse reports whether the backend response should be relayed
+// to the client as data arrives rather than left to the writer's buffering.
+func isStreamingResponse(resp *http.Response) bool {
+	if isEventStream(resp) {
+		return true
+	}
+
+	for _, encoding := range resp.TransferEncoding {
+		if encoding == "chunked" {
+			return true
+		}
+	}
+	return false
+}
+
+// isEventStream reports whether resp is a Server-Sent Events stream, which
+// stays open for as long as the backend has events to send.
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// copyStreaming copies src to dst, flushing the client connection after every
+// chunk so events are delivered without waiting for the body to finish.
+func copyStreaming(w http.ResponseWriter, dst io.Writer, src io.Reader) error {
+	controller := http.NewResponseController(w)
+	gz, _ := dst.(*gzip.Writer)
+
+	buf := make([]byte, streamBufferSize)
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+			if gz != nil {
+				if err := gz.Flush(); err != nil {
+					return err
+				}
+			}
+			if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
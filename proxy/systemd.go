@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor systemd passes to a
+// socket-activated service (SD_LISTEN_FDS_START).
+const listenFDsStart = 3
+
+// systemdListeners returns the sockets passed by systemd socket activation,
+// or nil when the process was not socket activated. The environment
+// variables are cleared afterwards so child processes do not claim the
+// sockets as well.
+func systemdListeners() ([]net.Listener, error) {
+	listeners, err := inheritedListeners(os.Getenv, listenFDsStart)
+	if len(listeners) > 0 || err != nil {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}
+	return listeners, err
+}
+
+// inheritedListeners implements the sd_listen_fds protocol: LISTEN_PID must
+// name this process and LISTEN_FDS counts the descriptors starting at
+// firstFD.
+func inheritedListeners(getenv func(string) string, firstFD int) ([]net.Listener, error) {
+	pid, err := strconv.Atoi(getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+
+	listeners := make([]net.Listener, 0, count)
+	for fd := firstFD; fd < firstFD+count; fd++ {
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		listener, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("socket activation fd %d: %w", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,93 @@
+//go:build unix
+
+package proxy
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestInheritedListenersServeRequests(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("activated"))
+	}))
+	defer backend.Close()
+
+	// Stand in for systemd: open the socket, then hand its descriptor over
+	socket, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := socket.Addr().String()
+	file, err := socket.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("failed to get socket file: %v", err)
+	}
+	_ = socket.Close()
+	defer func() { _ = file.Close() }()
+
+	// inheritedListeners takes ownership of the descriptor it is given
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatalf("failed to duplicate socket: %v", err)
+	}
+
+	env := map[string]string{
+		"LISTEN_PID": strconv.Itoa(os.Getpid()),
+		"LISTEN_FDS": "1",
+	}
+	listeners, err := inheritedListeners(func(key string) string { return env[key] }, fd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(listeners) != 1 {
+		t.Fatalf("expected 1 inherited listener, got %d", len(listeners))
+	}
+	defer func() { _ = listeners[0].Close() }()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	go func() { _ = proxy.Serve(listeners...) }()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatalf("request through inherited socket failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "activated" {
+		t.Errorf("expected backend response, got %q", body)
+	}
+}
+
+func TestInheritedListenersIgnoredWithoutActivation(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"no variables", map[string]string{}},
+		{"other process", map[string]string{"LISTEN_PID": strconv.Itoa(os.Getpid() + 1), "LISTEN_FDS": "1"}},
+		{"no descriptors", map[string]string{"LISTEN_PID": strconv.Itoa(os.Getpid()), "LISTEN_FDS": "0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listeners, err := inheritedListeners(func(key string) string { return tt.env[key] }, listenFDsStart)
+			if err != nil || listeners != nil {
+				t.Errorf("expected no listeners and no error, got %v, %v", listeners, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestTimeoutHeader lets a client ask for a shorter backend deadline than
+// the configured timeout. It is consumed by the proxy and never forwarded.
+const requestTimeoutHeader = "X-Request-Timeout"
+
+// requestTimeout returns the deadline for the backend call: the route's own
+// timeout if it has one, otherwise the configured timeout, shortened by a
+// valid X-Request-Timeout header. A client can only lower the timeout, never
+// raise it past that maximum; with no timeout at all the header is taken as
+// is. Zero means no deadline.
+func (p *Proxy) requestTimeout(r *http.Request, backend *Backend) time.Duration {
+	timeout := p.live.Load().timeout
+	if backend != nil && backend.timeout > 0 {
+		timeout = backend.timeout
+	}
+
+	requested, ok := parseRequestTimeout(r.Header.Get(requestTimeoutHeader))
+	if !ok {
+		return timeout
+	}
+	if timeout == 0 || requested < timeout {
+		return requested
+	}
+	return timeout
+}
+
+// parseRequestTimeout accepts a Go duration such as "1.5s" or "250ms", or a
+// bare integer number of milliseconds. Zero and negative values are ignored.
+func parseRequestTimeout(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	var timeout time.Duration
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		timeout = time.Duration(ms) * time.Millisecond
+	} else if d, err := time.ParseDuration(value); err == nil {
+		timeout = d
+	}
+	return timeout, timeout > 0
+}
@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured time.Duration
+		header     string
+		expected   time.Duration
+	}{
+		{"no header uses configured timeout", 30 * time.Second, "", 30 * time.Second},
+		{"shorter duration applies", 30 * time.Second, "2s", 2 * time.Second},
+		{"milliseconds applies", 30 * time.Second, "250", 250 * time.Millisecond},
+		{"longer value is clamped", 30 * time.Second, "5m", 30 * time.Second},
+		{"longer milliseconds are clamped", 30 * time.Second, "60000", 30 * time.Second},
+		{"invalid value is ignored", 30 * time.Second, "soon", 30 * time.Second},
+		{"zero is ignored", 30 * time.Second, "0", 30 * time.Second},
+		{"negative is ignored", 30 * time.Second, "-5s", 30 * time.Second},
+		{"applies without configured timeout", 0, "1.5s", 1500 * time.Millisecond},
+		{"no header and no configured timeout", 0, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, _ := New(Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL("http://localhost:3000"),
+				Timeout:    tt.configured,
+			})
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Request-Timeout", tt.header)
+			}
+			if got := proxy.requestTimeout(req, nil); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("http://localhost:3000"),
+		Timeout:    30 * time.Second,
+	})
+	route := &Backend{timeout: 2 * time.Minute}
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	if got := proxy.requestTimeout(req, route); got != 2*time.Minute {
+		t.Errorf("expected route timeout 2m, got %v", got)
+	}
+	req.Header.Set("X-Request-Timeout", "5m")
+	if got := proxy.requestTimeout(req, route); got != 2*time.Minute {
+		t.Errorf("expected header to be clamped to the route timeout, got %v", got)
+	}
+}
+
+func TestServeHTTPHonorsRequestTimeoutHeader(t *testing.T) {
+	var mu sync.Mutex
+	var forwarded []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		forwarded = append(forwarded, r.Header.Get("X-Request-Timeout"))
+		mu.Unlock()
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Timeout:    10 * time.Second,
+		Logger:     log.New(io.Discard, "", 0),
+	})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/slow", nil)
+	req.Header.Set("X-Request-Timeout", "100ms")
+	w := httptest.NewRecorder()
+	start := time.Now()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the client's 100ms deadline to apply, took %v", elapsed)
+	}
+
+	req = httptest.NewRequest("GET", "http://localhost:8080/fast", nil)
+	req.Header.Set("X-Request-Timeout", "1s")
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 within the deadline, got %d", w.Code)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, value := range forwarded {
+		if value != "" {
+			t.Errorf("expected X-Request-Timeout to be stripped, backend saw %q", value)
+		}
+	}
+}
+
+func TestServeHTTPResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-headers" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		// Headers go out at once; the body keeps streaming past the
+		// header timeout
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for i := 0; i < 3; i++ {
+			time.Sleep(100 * time.Millisecond)
+			_, _ = io.WriteString(w, "tick\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	proxy, err := New(Config{
+		ListenAddr:            ":8080",
+		TargetURL:             mustParseURL(backend.URL),
+		Timeout:               5 * time.Second,
+		ResponseHeaderTimeout: 150 * time.Millisecond,
+		Logger:                log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/slow-headers", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the header timeout to fire well before -t, took %v", elapsed)
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/stream", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "tick\ntick\ntick\n" {
+		t.Errorf("expected the slow body to stream in full, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServeHTTPPerRouteTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+			_, _ = io.WriteString(w, "done")
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		Timeout:    100 * time.Millisecond,
+		PathRoutes: []PathRoute{
+			{Prefix: "/reports", Target: mustParseURL(backend.URL), Timeout: 2 * time.Second},
+			{Prefix: "/strict", Target: mustParseURL(backend.URL), Timeout: 100 * time.Millisecond},
+		},
+		Logger: log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/reports/monthly", http.StatusOK},
+		{"/strict/monthly", http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080"+tt.path, nil))
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+
+	if _, err := New(Config{
+		ListenAddr: ":8080",
+		PathRoutes: []PathRoute{{Prefix: "/api", Target: mustParseURL(backend.URL), Timeout: -time.Second}},
+	}); err == nil {
+		t.Error("expected error for negative route timeout")
+	}
+}
@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DurationHeader reports, in milliseconds, how long the backend took when
+// Config.TimingHeader is set.
+const DurationHeader = "X-Proxy-Duration-Ms"
+
+// backendTiming breaks down how long a backend took, for the access log:
+// until its response headers arrived and until the response was relayed.
+type backendTiming struct {
+	TTFB  time.Duration
+	Total time.Duration
+}
+
+// setDurationHeader records the time since start in header, in milliseconds
+// with microsecond precision.
+func setDurationHeader(header http.Header, start time.Time) {
+	elapsed := time.Since(start)
+	header.Set(DurationHeader, strconv.FormatFloat(float64(elapsed.Microseconds())/1000, 'f', -1, 64))
+}
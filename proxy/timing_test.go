@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestServeHTTPTimingHeader(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		http.NewResponseController(w).Flush()
+		time.Sleep(30 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name     string
+		config   Config
+		minimum  float64
+		expected bool
+	}{
+		{"off by default", Config{}, 0, false},
+		{"slow body included", Config{TimingHeader: true}, 50, true},
+		{"slow body included when buffering", Config{TimingHeader: true, BufferResponse: true}, 50, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.ListenAddr = ":8080"
+			config.TargetURL = mustParseURL(backend.URL)
+			config.Logger = log.New(io.Discard, "", 0)
+			proxy, err := New(config)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/", nil))
+
+			value := w.Header().Get(DurationHeader)
+			if !tt.expected {
+				if value != "" {
+					t.Errorf("expected no %s header, got %q", DurationHeader, value)
+				}
+				return
+			}
+			ms, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("expected a numeric %s header, got %q", DurationHeader, value)
+			}
+			if ms < tt.minimum {
+				t.Errorf("expected at least %vms, got %vms", tt.minimum, ms)
+			}
+			if w.Body.String() != "done" {
+				t.Errorf("expected the body to be relayed, got %q", w.Body.String())
+			}
+		})
+	}
+}
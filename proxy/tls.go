@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tlsVersions maps the -tls-min-version names to their versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCurves are the key exchanges ParseTLSCurves knows, by their Go name
+// ("CurveP256") and their common one ("P-256").
+var tlsCurves = map[string]tls.CurveID{
+	"x25519":         tls.X25519,
+	"x25519mlkem768": tls.X25519MLKEM768,
+	"curvep256":      tls.CurveP256,
+	"p-256":          tls.CurveP256,
+	"curvep384":      tls.CurveP384,
+	"p-384":          tls.CurveP384,
+	"curvep521":      tls.CurveP521,
+	"p-521":          tls.CurveP521,
+}
+
+// ParseTLSVersion turns "1.0" to "1.3" (optionally prefixed with "TLS")
+// into a TLS version for Config.TLSMinVersion.
+func ParseTLSVersion(value string) (uint16, error) {
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "TLS")
+	version, ok := tlsVersions[strings.TrimSpace(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q (expected 1.0, 1.1, 1.2 or 1.3)", value)
+	}
+	return version, nil
+}
+
+// ParseCipherSuites turns IANA cipher suite names such as
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256" into their IDs. Suites Go
+// considers insecure are rejected along with unknown names. No names means
+// Go's default set.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		id, ok := known[name]
+		if !ok {
+			if insecure[name] {
+				return nil, fmt.Errorf("cipher suite %s is insecure", name)
+			}
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ParseTLSCurves turns key exchange names such as "X25519" or "P-256" into
+// curve IDs, in order of preference.
+func ParseTLSCurves(names []string) ([]tls.CurveID, error) {
+	var curves []tls.CurveID
+	for _, name := range names {
+		curve, ok := tlsCurves[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS curve %q (expected X25519, X25519MLKEM768, P-256, P-384 or P-521)", name)
+		}
+		curves = append(curves, curve)
+	}
+	return curves, nil
+}
+
+// buildTLSConfig assembles the client TLS configuration used when talking to
+// HTTPS backends, including optional client certificates and custom roots.
+func buildTLSConfig(config Config) (*tls.Config, error) {
+	minVersion := config.TLSMinVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	if minVersion < tls.VersionTLS10 || minVersion > tls.VersionTLS13 {
+		return nil, fmt.Errorf("unsupported minimum TLS version %#04x", minVersion)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         minVersion, // #nosec G402 -- older versions are an explicit opt-in
+		CipherSuites:       config.TLSCipherSuites,
+		CurvePreferences:   config.TLSCurves,
+		InsecureSkipVerify: config.InsecureSkipVerify, // #nosec G402 -- explicit opt-in for development backends
+	}
+
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		return nil, fmt.Errorf("client certificate and key must be provided together")
+	}
+
+	if config.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.CACertFile != "" {
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse CA certificate: no PEM certificates found in %s", config.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
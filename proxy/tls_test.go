@@ -0,0 +1,347 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate generates a self-signed certificate usable for both
+// server and client authentication and writes it as PEM files in dir.
+func writeTestCertificate(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestBuildTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "client")
+	garbage := filepath.Join(dir, "garbage.pem")
+	_ = os.WriteFile(garbage, []byte("not a certificate"), 0600)
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"cert without key", Config{ClientCertFile: certFile}},
+		{"key without cert", Config{ClientKeyFile: keyFile}},
+		{"missing cert file", Config{ClientCertFile: filepath.Join(dir, "missing.crt"), ClientKeyFile: keyFile}},
+		{"unparseable key pair", Config{ClientCertFile: garbage, ClientKeyFile: garbage}},
+		{"missing CA file", Config{CACertFile: filepath.Join(dir, "missing.crt")}},
+		{"unparseable CA file", Config{CACertFile: garbage}},
+		{"unknown TLS version", Config{TLSMinVersion: 0x0200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := buildTLSConfig(tt.config); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestBuildTLSConfigLoadsCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir, "client")
+
+	tlsConfig, err := buildTLSConfig(Config{
+		ClientCertFile: certFile,
+		ClientKeyFile:  keyFile,
+		CACertFile:     certFile,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tlsConfig.Certificates) != 1 {
+		t.Errorf("expected 1 client certificate, got %d", len(tlsConfig.Certificates))
+	}
+	if tlsConfig.RootCAs == nil {
+		t.Error("expected custom root CA pool")
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected minimum TLS 1.2, got %x", tlsConfig.MinVersion)
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		value string
+		want  uint16
+	}{
+		{"1.0", tls.VersionTLS10},
+		{"1.2", tls.VersionTLS12},
+		{"1.3", tls.VersionTLS13},
+		{"TLS1.3", tls.VersionTLS13},
+		{"tls 1.1", tls.VersionTLS11},
+	}
+	for _, tt := range tests {
+		got, err := ParseTLSVersion(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTLSVersion(%q) = %x, %v; want %x", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "1.4", "SSLv3", "2"} {
+		if _, err := ParseTLSVersion(value); err == nil {
+			t.Errorf("ParseTLSVersion(%q) expected error", value)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	got, err := ParseCipherSuites([]string{"TLS_AES_128_GCM_SHA256", " tls_ecdhe_rsa_with_aes_256_gcm_sha384"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []uint16{tls.TLS_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+
+	if got, err := ParseCipherSuites(nil); err != nil || got != nil {
+		t.Errorf("expected nil for no names so Go's defaults apply, got %x, %v", got, err)
+	}
+
+	tests := []struct {
+		name  string
+		names []string
+	}{
+		{"unknown", []string{"TLS_AES_128_GCM_SHA256", "TLS_MADE_UP_SHA1"}},
+		{"insecure", []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		{"empty", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCipherSuites(tt.names); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestParseTLSCurves(t *testing.T) {
+	got, err := ParseTLSCurves([]string{"X25519", "P-256", "CurveP384", "x25519mlkem768"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.X25519MLKEM768}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("curve %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	if _, err := ParseTLSCurves([]string{"P-192"}); err == nil {
+		t.Error("expected error for unknown curve")
+	}
+}
+
+func TestBuildTLSConfigRestrictions(t *testing.T) {
+	tlsConfig, err := buildTLSConfig(Config{
+		TLSMinVersion:   tls.VersionTLS13,
+		TLSCipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		TLSCurves:       []tls.CurveID{tls.X25519},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected minimum TLS 1.3, got %x", tlsConfig.MinVersion)
+	}
+	if len(tlsConfig.CipherSuites) != 1 || len(tlsConfig.CurvePreferences) != 1 || tlsConfig.CurvePreferences[0] != tls.X25519 {
+		t.Errorf("expected configured suites and curves, got %x and %v", tlsConfig.CipherSuites, tlsConfig.CurvePreferences)
+	}
+}
+
+func TestServeHTTPMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, clientKey := writeTestCertificate(t, dir, "client")
+	serverCert, serverKey := writeTestCertificate(t, dir, "server")
+
+	clientPEM, _ := os.ReadFile(clientCert)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientPEM)
+
+	serverPair, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatalf("failed to load server certificate: %v", err)
+	}
+
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			t.Error("expected client certificate")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	backend.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}
+	backend.StartTLS()
+	defer backend.Close()
+
+	tests := []struct {
+		name         string
+		withClient   bool
+		expectStatus int
+	}{
+		{"with client certificate", true, http.StatusOK},
+		{"without client certificate", false, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL(backend.URL),
+				CACertFile: serverCert,
+			}
+			if tt.withClient {
+				config.ClientCertFile = clientCert
+				config.ClientKeyFile = clientKey
+			}
+
+			proxy, err := New(config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/test", nil)
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != tt.expectStatus {
+				t.Errorf("expected status %d, got %d", tt.expectStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestNewTLSCertWithoutKey(t *testing.T) {
+	_, err := New(Config{
+		ListenAddr:  ":8080",
+		TargetURL:   mustParseURL("http://example.com"),
+		TLSCertFile: "server.crt",
+	})
+	if err == nil {
+		t.Fatal("expected error for certificate without key")
+	}
+}
+
+func TestNewHTTP3RequiresTLS(t *testing.T) {
+	_, err := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL("http://example.com"),
+		HTTP3:      true,
+	})
+	if err == nil {
+		t.Fatal("expected error for HTTP/3 without a certificate")
+	}
+}
+
+func TestServeTerminatesTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := writeTestCertificate(t, dir, "server")
+
+	var forwardedProto string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedProto = r.Header.Get("X-Forwarded-Proto")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr:  "127.0.0.1:0",
+		TargetURL:   mustParseURL(backend.URL),
+		TLSCertFile: serverCert,
+		TLSKeyFile:  serverKey,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	go func() { _ = proxy.Serve(listener) }()
+
+	serverPEM, _ := os.ReadFile(serverCert)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(serverPEM)
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+		},
+	}
+
+	resp, err := client.Get("https://" + listener.Addr().String() + "/secure")
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+	if forwardedProto != "https" {
+		t.Errorf("expected X-Forwarded-Proto https, got %q", forwardedProto)
+	}
+}
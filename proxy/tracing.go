@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/gavinyap/goreflector"
+
+// tracing holds the OpenTelemetry state used when tracing is enabled. The
+// proxy keeps a nil *tracing otherwise so requests skip span creation
+// entirely.
+type tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	shutdown   func(context.Context) error
+}
+
+// newOTLPTracerProvider exports spans in batches to an OTLP/HTTP collector.
+// The endpoint is either a full URL or a bare host:port, which is contacted
+// over plain HTTP as collectors usually run alongside the proxy.
+func newOTLPTracerProvider(endpoint string) (*sdktrace.TracerProvider, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure()}
+	if strings.Contains(endpoint, "://") {
+		opts = []otlptracehttp.Option{otlptracehttp.WithEndpointURL(endpoint)}
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "goreflector"),
+		attribute.String("service.version", Version),
+	)
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
+
+func newTracing(provider trace.TracerProvider, shutdown func(context.Context) error) *tracing {
+	if shutdown == nil {
+		shutdown = func(context.Context) error { return nil }
+	}
+	return &tracing{
+		tracer:     provider.Tracer(tracerName),
+		propagator: propagation.TraceContext{},
+		shutdown:   shutdown,
+	}
+}
+
+// startServerSpan continues any trace the client sent and returns the
+// request carrying the new server span.
+func (t *tracing) startServerSpan(r *http.Request) (*http.Request, trace.Span) {
+	ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := t.tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("server.address", r.Host),
+		))
+	return r.WithContext(ctx), span
+}
+
+// startClientSpan wraps the backend call and injects traceparent so the
+// backend joins the same trace.
+func (t *tracing) startClientSpan(req *http.Request) (*http.Request, func(*http.Response, error)) {
+	ctx, span := t.tracer.Start(req.Context(), req.Method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.full", req.URL.String()),
+		))
+	req = req.WithContext(ctx)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return req, func(resp *http.Response, err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			setSpanStatus(span, resp.StatusCode)
+		}
+		span.End()
+	}
+}
+
+func setSpanStatus(span trace.Span, statusCode int) {
+	span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
+	if statusCode >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(statusCode))
+	}
+}
@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestServeHTTPTracing(t *testing.T) {
+	var traceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer backend.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	proxy, err := New(Config{
+		ListenAddr:     ":8080",
+		TargetURL:      mustParseURL(backend.URL),
+		TracerProvider: provider,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The incoming request continues an existing trace
+	const parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("POST", "http://proxy.local/items", nil)
+	req.Header.Set("Traceparent", "00-"+parentTraceID+"-00f067aa0ba902b7-01")
+	proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected server and client spans, got %d", len(spans))
+	}
+
+	var server, client sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		switch span.SpanKind() {
+		case trace.SpanKindServer:
+			server = span
+		case trace.SpanKindClient:
+			client = span
+		}
+	}
+	if server == nil || client == nil {
+		t.Fatalf("expected one server and one client span, got %v", spans)
+	}
+
+	if server.SpanContext().TraceID().String() != parentTraceID {
+		t.Errorf("expected server span to join trace %s, got %s", parentTraceID, server.SpanContext().TraceID())
+	}
+	if client.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("expected client span to be a child of the server span")
+	}
+
+	for _, span := range spans {
+		if got := spanAttribute(span, "http.request.method").AsString(); got != "POST" {
+			t.Errorf("%s span: expected method POST, got %q", span.SpanKind(), got)
+		}
+		if got := spanAttribute(span, "http.response.status_code").AsInt64(); got != http.StatusAccepted {
+			t.Errorf("%s span: expected status 202, got %d", span.SpanKind(), got)
+		}
+	}
+	if got := spanAttribute(client, "server.address").AsString(); got != mustParseURL(backend.URL).Host {
+		t.Errorf("expected target host attribute, got %q", got)
+	}
+
+	if !strings.Contains(traceparent, parentTraceID) || !strings.Contains(traceparent, client.SpanContext().SpanID().String()) {
+		t.Errorf("expected traceparent for the client span to reach the backend, got %q", traceparent)
+	}
+}
+
+func TestServeHTTPTracingBackendError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	proxy, err := New(Config{
+		ListenAddr:     ":8080",
+		TargetURL:      mustParseURL("http://127.0.0.1:1"),
+		TracerProvider: provider,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	for _, span := range recorder.Ended() {
+		if span.Status().Code != codes.Error {
+			t.Errorf("%s span: expected error status, got %s", span.SpanKind(), span.Status().Code)
+		}
+	}
+}
+
+func TestTracingDisabledByDefault(t *testing.T) {
+	var traceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{ListenAddr: ":8080", TargetURL: mustParseURL(backend.URL)})
+	if proxy.tracing != nil {
+		t.Fatal("expected tracing to be disabled without an endpoint")
+	}
+
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if traceparent != "" {
+		t.Errorf("expected no traceparent without tracing, got %q", traceparent)
+	}
+}
+
+func TestNewOTelEndpoint(t *testing.T) {
+	proxy, err := New(Config{
+		ListenAddr:   ":8080",
+		TargetURL:    mustParseURL("http://backend"),
+		OTelEndpoint: "localhost:4318",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if proxy.tracing == nil {
+		t.Fatal("expected tracing to be enabled")
+	}
+	if err := proxy.Close(); err != nil {
+		t.Errorf("unexpected error shutting down tracing: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTrailerBackend() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Header().Set("Content-Type", "application/grpc-web")
+		_, _ = w.Write([]byte("payload"))
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "OK")
+	}))
+}
+
+func TestServeHTTPForwardTrailers(t *testing.T) {
+	backend := newTrailerBackend()
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:      ":8080",
+		TargetURL:       mustParseURL(backend.URL),
+		ForwardTrailers: true,
+		Logger:          log.New(io.Discard, "", 0),
+	})
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/service/Method")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "payload" {
+		t.Errorf("expected body 'payload', got %q", body)
+	}
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Errorf("expected Grpc-Status trailer 0, got %q", got)
+	}
+	if got := resp.Trailer.Get("Grpc-Message"); got != "OK" {
+		t.Errorf("expected Grpc-Message trailer OK, got %q", got)
+	}
+}
+
+func TestServeHTTPTrailersDroppedByDefault(t *testing.T) {
+	backend := newTrailerBackend()
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/service/Method")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.ReadAll(resp.Body)
+
+	if len(resp.Trailer) != 0 {
+		t.Errorf("expected no trailers without -forward-trailers, got %v", resp.Trailer)
+	}
+}
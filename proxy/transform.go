@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BodyTransformer rewrites a complete message body. Transformers see the
+// whole body at once, so every transformed message is buffered in memory.
+type BodyTransformer func([]byte) ([]byte, error)
+
+// transformRequestBody buffers the outgoing request body, passes it through
+// RequestBodyTransformer and fixes up the length to match the new body.
+func (p *Proxy) transformRequestBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	body, err := transformBody(req.Body, 0, p.RequestBodyTransformer)
+	if err != nil {
+		return err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// transformResponseBody buffers the backend response body, passes it through
+// transform and replaces it with a fixed-length body. Gzip and
+// deflate bodies are decoded first so the transformer sees plain content,
+// then encoded again if the client accepts that coding or sent as is with
+// Content-Encoding removed otherwise. A decoded body larger than
+// Config.MaxResponseSize fails with errResponseTooLarge, so a small
+// compressed body cannot expand without bound in memory.
+func (p *Proxy) transformResponseBody(r *http.Request, resp *http.Response, transform BodyTransformer) error {
+	if r.Method == http.MethodHead || !bodyAllowedForStatus(resp.StatusCode) {
+		return nil
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "identity" {
+		encoding = ""
+	}
+
+	decoded, err := decodeBody(resp.Body, encoding)
+	if err != nil {
+		_ = resp.Body.Close()
+		return err
+	}
+	body, err := transformBody(decoded, p.config.MaxResponseSize, transform)
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if encoding != "" {
+		if acceptsEncoding(r.Header.Get("Accept-Encoding"), encoding) {
+			if body, err = encodeBody(body, encoding); err != nil {
+				return err
+			}
+		} else {
+			resp.Header.Del("Content-Encoding")
+		}
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// transformBody reads body and passes it through transform. With a positive
+// limit, a body longer than limit fails with errResponseTooLarge.
+func transformBody(body io.Reader, limit int64, transform BodyTransformer) ([]byte, error) {
+	if limit > 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+	original, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if limit > 0 && int64(len(original)) > limit {
+		return nil, errResponseTooLarge
+	}
+
+	transformed, err := transform(original)
+	if err != nil {
+		return nil, fmt.Errorf("body transformer failed: %w", err)
+	}
+	return transformed, nil
+}
+
+// decodeBody undoes a gzip or deflate Content-Encoding. Deflate is meant to
+// be zlib-wrapped (RFC 9110 section 8.4.1.2), but some servers send raw
+// deflate data, so that is accepted too.
+func decodeBody(body io.Reader, encoding string) (io.Reader, error) {
+	switch encoding {
+	case "":
+		return body, nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gzip body: %w", err)
+		}
+		return reader, nil
+	case "deflate":
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body: %w", err)
+		}
+		if reader, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+			return reader, nil
+		}
+		return flate.NewReader(bytes.NewReader(data)), nil
+	}
+	return nil, fmt.Errorf("cannot transform a body with Content-Encoding %q", encoding)
+}
+
+func encodeBody(body []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	if encoding == "deflate" {
+		writer = zlib.NewWriter(&buf)
+	} else {
+		writer = gzip.NewWriter(&buf)
+	}
+
+	if _, err := writer.Write(body); err != nil {
+		return nil, fmt.Errorf("failed to encode body: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// bodyAllowedForStatus reports whether a response with this status code may
+// carry a body (RFC 7230 section 3.3.3).
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status < 200:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
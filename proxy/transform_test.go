@@ -0,0 +1,271 @@
+package proxy
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// uppercaseName upper-cases the "name" field of a JSON object.
+func uppercaseName(body []byte) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if name, ok := doc["name"].(string); ok {
+		doc["name"] = strings.ToUpper(name)
+	}
+	return json.Marshal(doc)
+}
+
+func TestRequestBodyTransformer(t *testing.T) {
+	var received string
+	var receivedLength int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		receivedLength = r.ContentLength
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	proxy.RequestBodyTransformer = uppercaseName
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/users", strings.NewReader(`{"name":"alice"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if received != `{"name":"ALICE"}` {
+		t.Errorf("expected transformed body, got %q", received)
+	}
+	if receivedLength != int64(len(received)) {
+		t.Errorf("expected Content-Length %d, got %d", len(received), receivedLength)
+	}
+}
+
+func TestResponseBodyTransformer(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"bob","role":"admin"}`))
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	proxy.ResponseBodyTransformer = uppercaseName
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	expected := `{"name":"BOB","role":"admin"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected %q, got %q", expected, w.Body.String())
+	}
+	if w.Header().Get("Content-Length") != strconv.Itoa(len(expected)) {
+		t.Errorf("expected Content-Length %d, got %q", len(expected), w.Header().Get("Content-Length"))
+	}
+}
+
+func TestResponseBodyTransformerError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not json"))
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	proxy.ResponseBodyTransformer = func([]byte) ([]byte, error) {
+		return nil, errors.New("boom")
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", w.Code)
+	}
+}
+
+func TestRequestBodyTransformerSkipsEmptyBody(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	called := false
+	proxy.RequestBodyTransformer = func(body []byte) ([]byte, error) {
+		called = true
+		return body, nil
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if called {
+		t.Error("expected transformer not to run for a request without a body")
+	}
+}
+
+func TestResponseBodyTransformerDecodesCompressedBodies(t *testing.T) {
+	const original = `{"name":"gopher"}`
+
+	encoders := map[string]func(io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"raw-deflate": func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		},
+	}
+
+	tests := []struct {
+		name           string
+		backendEncoder string
+		contentCoding  string
+		acceptEncoding string
+		expectEncoding string
+	}{
+		{"gzip re-encoded for client", "gzip", "gzip", "gzip, deflate", "gzip"},
+		{"deflate re-encoded for client", "deflate", "deflate", "deflate", "deflate"},
+		{"raw deflate decoded", "raw-deflate", "deflate", "deflate", "deflate"},
+		{"encoding dropped when client refuses it", "gzip", "gzip", "deflate", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", tt.contentCoding)
+				encoder := encoders[tt.backendEncoder](w)
+				_, _ = encoder.Write([]byte(original))
+				_ = encoder.Close()
+			}))
+			defer backend.Close()
+
+			proxy, _ := New(Config{
+				ListenAddr: ":8080",
+				TargetURL:  mustParseURL(backend.URL),
+				Logger:     log.New(io.Discard, "", 0),
+			})
+			proxy.ResponseBodyTransformer = uppercaseName
+
+			req := httptest.NewRequest("GET", "http://localhost:8080/user", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.expectEncoding {
+				t.Errorf("expected Content-Encoding %q, got %q", tt.expectEncoding, got)
+			}
+			if got := w.Header().Get("Content-Length"); got != strconv.Itoa(w.Body.Len()) {
+				t.Errorf("expected Content-Length %d, got %s", w.Body.Len(), got)
+			}
+
+			var body io.Reader = w.Body
+			switch tt.expectEncoding {
+			case "gzip":
+				body, _ = gzip.NewReader(body)
+			case "deflate":
+				body, _ = zlib.NewReader(body)
+			}
+			decoded, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if string(decoded) != `{"name":"GOPHER"}` {
+				t.Errorf("expected transformed body, got %q", decoded)
+			}
+		})
+	}
+}
+
+func TestResponseBodyTransformerMaxResponseSize(t *testing.T) {
+	// A megabyte of zeros compresses to about a kilobyte
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		_, _ = gz.Write(make([]byte, 1<<20))
+		_ = gz.Close()
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr:      ":8080",
+		TargetURL:       mustParseURL(backend.URL),
+		MaxResponseSize: 64 << 10,
+		Logger:          log.New(io.Discard, "", 0),
+	})
+	called := false
+	proxy.ResponseBodyTransformer = func(body []byte) ([]byte, error) {
+		called = true
+		return body, nil
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/bomb", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected status 502, got %d", w.Code)
+	}
+	if called {
+		t.Error("expected the transformer not to see an oversized body")
+	}
+}
+
+func TestResponseBodyTransformerUnsupportedEncoding(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		_, _ = w.Write([]byte{0x0b, 0x02, 0x80})
+	}))
+	defer backend.Close()
+
+	proxy, _ := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	proxy.ResponseBodyTransformer = uppercaseName
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected status 502 for an encoding that cannot be decoded, got %d", w.Code)
+	}
+}
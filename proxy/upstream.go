@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	netproxy "golang.org/x/net/proxy"
+)
+
+// ParseUpstreamProxy validates an -upstream-proxy URL. SOCKS5 proxies use
+// the socks5:// (or socks5h://) scheme, HTTP proxies http:// or https://.
+func ParseUpstreamProxy(rawURL string) (*url.URL, error) {
+	upstream, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream proxy URL: %w", err)
+	}
+
+	switch upstream.Scheme {
+	case "socks5", "socks5h", "http", "https":
+	default:
+		return nil, fmt.Errorf("upstream proxy must use socks5, socks5h, http or https scheme: %s", upstream.Redacted())
+	}
+
+	if upstream.Host == "" {
+		return nil, fmt.Errorf("upstream proxy URL must include a host: %s", upstream.Redacted())
+	}
+	return upstream, nil
+}
+
+// configureUpstreamProxy routes backend connections through upstream. SOCKS5
+// proxies replace the dialer; HTTP proxies are handled by the transport
+// itself, tunneling TLS backends with CONNECT.
+func configureUpstreamProxy(transport *http.Transport, dialer *net.Dialer, upstream *url.URL) error {
+	switch upstream.Scheme {
+	case "socks5", "socks5h":
+		socks, err := netproxy.FromURL(upstream, dialer)
+		if err != nil {
+			return fmt.Errorf("failed to configure SOCKS5 proxy: %w", err)
+		}
+		contextDialer, ok := socks.(netproxy.ContextDialer)
+		if !ok {
+			return fmt.Errorf("SOCKS5 proxy dialer does not support contexts")
+		}
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return contextDialer.DialContext(ctx, network, addr)
+		}
+	default:
+		transport.Proxy = http.ProxyURL(upstream)
+	}
+	return nil
+}
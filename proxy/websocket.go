@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// webSocketStats describes a finished WebSocket tunnel for the access log.
+type webSocketStats struct {
+	RequestedProtocol string
+	Protocol          string
+	BytesIn           int64 // client to backend
+	BytesOut          int64 // backend to client
+	Duration          time.Duration
+}
+
+// isWebSocketUpgrade reports whether r asks to switch the connection to the
+// WebSocket protocol.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return httpguts.HeaderValuesContainsToken(r.Header.Values("Connection"), "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// serveWebSocket completes a WebSocket handshake the backend accepted with
+// 101 Switching Protocols, then relays frames in both directions until either
+// side closes. It returns once the tunnel is gone, so the request is logged
+// with the tunnel's lifetime and byte counts.
+func (p *Proxy) serveWebSocket(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	upstream, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		p.logger.Printf("Backend switched protocols without a writable body for %s", r.URL.Path)
+		p.writeError(w, "Failed to proxy request", http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+
+	conn, buffered, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		p.logger.Printf("Error hijacking connection for WebSocket: %v", err)
+		p.writeError(w, "WebSocket not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The server's read and write deadlines would otherwise cut the tunnel
+	_ = conn.SetDeadline(time.Time{})
+
+	header := resp.Header.Clone()
+	header.Set(p.config.RequestIDHeader, r.Header.Get(p.config.RequestIDHeader))
+	if _, err := fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\n", resp.StatusCode, http.StatusText(resp.StatusCode)); err == nil {
+		err = header.Write(conn)
+		if err == nil {
+			_, err = io.WriteString(conn, "\r\n")
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	stats := &webSocketStats{
+		RequestedProtocol: r.Header.Get("Sec-WebSocket-Protocol"),
+		Protocol:          resp.Header.Get("Sec-WebSocket-Protocol"),
+	}
+	if rw, ok := w.(*responseWriter); ok {
+		rw.statusCode = resp.StatusCode
+		rw.wroteHeader = true
+		rw.webSocket = stats
+	}
+
+	start := time.Now()
+
+	// Frames the client sent right after the handshake may already sit in
+	// the server's read buffer
+	if n := buffered.Reader.Buffered(); n > 0 {
+		data, _ := buffered.Reader.Peek(n)
+		written, err := upstream.Write(data)
+		stats.BytesIn += int64(written)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+	}
+
+	in, out := tunnel(conn, upstream)
+	stats.BytesIn += in
+	stats.BytesOut = out
+	stats.Duration = time.Since(start)
+
+	p.logger.Printf("WebSocket tunnel for %s closed after %v: %d bytes in, %d bytes out, subprotocol requested=%q chosen=%q",
+		r.URL.Path, stats.Duration.Round(time.Millisecond), stats.BytesIn, stats.BytesOut, stats.RequestedProtocol, stats.Protocol)
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newWebSocketBackend accepts any upgrade with the first offered subprotocol,
+// greets the client and then echoes whatever it receives.
+func newWebSocketBackend(t *testing.T, greeting string) *httptest.Server {
+	t.Helper()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, buffered, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		protocol, _, _ := strings.Cut(r.Header.Get("Sec-WebSocket-Protocol"), ",")
+		fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Protocol: %s\r\n\r\n%s", protocol, greeting)
+		_, _ = io.Copy(conn, buffered)
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func dialWebSocket(t *testing.T, addr, protocols string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET /chat HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Protocol: %s\r\n\r\n", addr, protocols)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("failed to read handshake response: %v", err)
+	}
+	return conn, reader, resp
+}
+
+func TestServeHTTPWebSocketAccessLog(t *testing.T) {
+	const greeting = "welcome!"
+	backend := newWebSocketBackend(t, greeting)
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	proxy, err := New(Config{
+		ListenAddr:    ":8080",
+		TargetURL:     mustParseURL(backend.URL),
+		Timeout:       100 * time.Millisecond,
+		AccessLogPath: path,
+		Logger:        log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	conn, reader, resp := dialWebSocket(t, server.Listener.Addr().String(), "chat.v2, chat.v1")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "chat.v2" {
+		t.Errorf("expected chosen subprotocol chat.v2, got %q", got)
+	}
+
+	// Outlive -t to show the deadline only covers the handshake
+	time.Sleep(200 * time.Millisecond)
+
+	const message = "ping"
+	if _, err := io.WriteString(conn, message); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	reply := make([]byte, len(greeting)+len(message))
+	if _, err := io.ReadFull(reader, reply); err != nil {
+		t.Fatalf("failed to read through tunnel: %v", err)
+	}
+	if string(reply) != greeting+message {
+		t.Errorf("expected %q through the tunnel, got %q", greeting+message, reply)
+	}
+	conn.Close()
+
+	// The entry is written when the tunnel closes
+	var entries []accessLogEntry
+	deadline := time.Now().Add(2 * time.Second)
+	for len(entries) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		entries = readAccessLog(t, path)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 access log line, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Status != http.StatusSwitchingProtocols {
+		t.Errorf("expected status 101, got %d", entry.Status)
+	}
+	if entry.WebSocketRequestedProtocol != "chat.v2, chat.v1" || entry.WebSocketProtocol != "chat.v2" {
+		t.Errorf("unexpected subprotocols: requested %q, chosen %q", entry.WebSocketRequestedProtocol, entry.WebSocketProtocol)
+	}
+	if entry.WebSocketBytesIn != int64(len(message)) {
+		t.Errorf("expected %d bytes in, got %d", len(message), entry.WebSocketBytesIn)
+	}
+	if entry.WebSocketBytesOut != int64(len(greeting)+len(message)) {
+		t.Errorf("expected %d bytes out, got %d", len(greeting)+len(message), entry.WebSocketBytesOut)
+	}
+	if entry.DurationMs < 200 {
+		t.Errorf("expected the duration to cover the tunnel, got %dms", entry.DurationMs)
+	}
+}
+
+func TestServeHTTPWebSocketRejected(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such channel", http.StatusNotFound)
+	}))
+	defer backend.Close()
+
+	proxy, err := New(Config{
+		ListenAddr: ":8080",
+		TargetURL:  mustParseURL(backend.URL),
+		Logger:     log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	_, _, resp := dialWebSocket(t, server.Listener.Addr().String(), "chat")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the backend's 404, got %d", resp.StatusCode)
+	}
+}